@@ -0,0 +1,100 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// approvalPatterns recognise OEM specifications and approvals in data sheets
+// and product pages. Each pattern captures the identifier and label turns it
+// into one canonical spelling, so "CES20086" and "Cummins CES 20086" match.
+var approvalPatterns = []struct {
+	pattern *regexp.Regexp
+	label   func(match []string) string
+}{
+	{regexp.MustCompile(`(?i)\bdexos\s?(1|2|d|r)\b(?:\W{0,3}gen(?:eration)?\s?(\d))?`), func(m []string) string {
+		if m[2] != "" {
+			return "GM dexos" + strings.ToUpper(m[1]) + " Gen " + m[2]
+		}
+		return "GM dexos" + strings.ToUpper(m[1])
+	}},
+	{regexp.MustCompile(`(?i)\bdexron[\s-]?(vi|iii|ii|hp|ulv)\b`), func(m []string) string { return "GM DEXRON-" + strings.ToUpper(m[1]) }},
+	{regexp.MustCompile(`(?i)\bmercon[\s-]?(lv|sp|ulv|v)\b`), func(m []string) string { return "Ford MERCON " + strings.ToUpper(m[1]) }},
+	{regexp.MustCompile(`(?i)\bwss[\s-]?(m2c\d{3}[\s-][a-z]\d?)\b`), func(m []string) string {
+		return "Ford WSS-" + strings.ToUpper(strings.ReplaceAll(m[1], " ", "-"))
+	}},
+	// Four digits, or five starting with 9, so the Vicksburg, MS zip code is not
+	// a spec; MS-1xxx are Case tractor fluid specs, the rest are Chrysler's
+	{regexp.MustCompile(`(?i)\bms[\s-]?(\d{4}|9\d{4})\b`), func(m []string) string {
+		if strings.HasPrefix(m[1], "1") {
+			return "Case MS-" + m[1]
+		}
+		return "Chrysler MS-" + m[1]
+	}},
+	{regexp.MustCompile(`(?i)\bces\s?(200\d\d)\b`), func(m []string) string { return "Cummins CES " + m[1] }},
+	{regexp.MustCompile(`(?i)\bdfs\s?(93k\d{3})\b`), func(m []string) string { return "Detroit DFS " + strings.ToUpper(m[1]) }},
+	{regexp.MustCompile(`(?i)\bmack\s+(eo-[a-z]|eos-\d(?:\.\d)?|go-[a-z])(\s+premium)?(\s+plus)?`), func(m []string) string {
+		label := "Mack " + strings.ToUpper(m[1])
+		if m[2] != "" {
+			label += " Premium"
+		}
+		if m[3] != "" {
+			label += " Plus"
+		}
+		return label
+	}},
+	{regexp.MustCompile(`(?i)\bvds[\s-]?(\d(?:\.\d)?)\b`), func(m []string) string { return "Volvo VDS-" + m[1] }},
+	{regexp.MustCompile(`(?i)\b(?:cat(?:erpillar)?\s+)?ecf[\s-]?(\d[a-z]?)\b`), func(m []string) string { return "Caterpillar ECF-" + m[1] }},
+	{regexp.MustCompile(`(?i)\b(?:cat(?:erpillar)?\s+)?to[\s-]4\b`), func(m []string) string { return "Caterpillar TO-4" }},
+	{regexp.MustCompile(`(?i)\ballison\s+(c[\s-]?\d)\b`), func(m []string) string {
+		return "Allison C-" + m[1][len(m[1])-1:]
+	}},
+	{regexp.MustCompile(`(?i)\btes[\s-]?(\d{3})\b`), func(m []string) string { return "Allison TES-" + m[1] }},
+	{regexp.MustCompile(`(?i)\bmb[\s-]?(\d{3}\.\d{1,2})\b`), func(m []string) string { return "MB " + m[1] }},
+	{regexp.MustCompile(`(?i)\bman\s?(3\d{3})\b`), func(m []string) string { return "MAN " + m[1] }},
+	{regexp.MustCompile(`(?i)\brld-(\d)\b`), func(m []string) string { return "Renault RLD-" + m[1] }},
+	{regexp.MustCompile(`(?i)\bdeutz\s+dqc[\s-]?(i{1,3}v?|iv)?\b`), func(m []string) string {
+		return strings.TrimSpace("Deutz DQC " + strings.ToUpper(m[1]))
+	}},
+	{regexp.MustCompile(`(?i)\bjaso\s+(ma2|ma1|ma|mb|fb|fc|fd|dh-2|dl-1|m315)\b`), func(m []string) string { return "JASO " + strings.ToUpper(m[1]) }},
+	{regexp.MustCompile(`(?i)\b(?:nmma\s+)?(tc-?w3|fc-w)\b`), func(m []string) string {
+		if id := strings.ToUpper(m[1]); id != "TCW3" {
+			return "NMMA " + id
+		}
+		return "NMMA TC-W3"
+	}},
+	{regexp.MustCompile(`(?i)\bdin\s?(515\d\d)(?:[\s-]+(?:part\s+)?(\d))?\b`), func(m []string) string {
+		if m[2] != "" {
+			return "DIN " + m[1] + "-" + m[2]
+		}
+		return "DIN " + m[1]
+	}},
+	{regexp.MustCompile(`(?i)\bdenison\s+hf[\s-]?([0-6o])\b`), func(m []string) string {
+		return "Denison HF-" + strings.Replace(strings.ToUpper(m[1]), "O", "0", 1)
+	}},
+	{regexp.MustCompile(`(?i)\bvickers\s+(m-?2950-?s|i-?286-?s|35vq25)\b`), func(m []string) string {
+		switch id := strings.ToUpper(strings.ReplaceAll(m[1], "-", "")); id {
+		case "M2950S":
+			return "Vickers M-2950-S"
+		case "I286S":
+			return "Vickers I-286-S"
+		default:
+			return "Vickers " + id
+		}
+	}},
+	{regexp.MustCompile(`(?i)\bagma\s+(9005-[a-z]\d\d)\b`), func(m []string) string { return "AGMA " + strings.ToUpper(m[1]) }},
+}
+
+// parseApprovals returns the OEM specifications and approvals named in text,
+// sorted and without duplicates
+func parseApprovals(text string) []string {
+	var approvals []string
+	for _, candidate := range approvalPatterns {
+		for _, match := range candidate.pattern.FindAllStringSubmatch(text, -1) {
+			approvals = appendUnique(approvals, candidate.label(match))
+		}
+	}
+	sort.Strings(approvals)
+	return approvals
+}
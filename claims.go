@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+)
+
+// ClaimMismatch is a specification claimed in one place but not the other
+type ClaimMismatch struct {
+	Product     string   `json:"product"`
+	Claim       string   `json:"claim"`
+	ClaimedOn   string   `json:"claimed_on"`   // "product page" or "TDS"
+	MissingFrom string   `json:"missing_from"` // The other one
+	Documents   []string `json:"documents,omitempty"`
+}
+
+// canonicalClaims turns claims into the labels of parseGrades and
+// parseApprovals, so "API CK-4 / SN" and "API CK-4, API SN" compare equal.
+// Claims with nothing recognisable in them are returned as unchecked.
+func canonicalClaims(texts ...string) (claims, unchecked []string) {
+	for _, text := range texts {
+		grades := parseGrades(text, false)
+		labels := append(Grades{API: grades.API, ILSAC: grades.ILSAC}.labels(), parseApprovals(text)...)
+		if len(labels) == 0 {
+			unchecked = appendUnique(unchecked, text)
+		}
+		for _, label := range labels {
+			claims = appendUnique(claims, label)
+		}
+	}
+	sort.Strings(claims)
+	return claims, unchecked
+}
+
+// pageClaims returns the claims a product page makes, as recorded by
+// parseProductPage
+func pageClaims(product *Product) (claims, unchecked []string) {
+	return canonicalClaims(append(append([]string{}, product.Specifications...), product.Approvals...)...)
+}
+
+// sheetClaims returns the claims made in a product's technical data sheets
+// and the sheets that were read
+func sheetClaims(product *Product, manifest *Manifest, dir string) (claims, sheets []string) {
+	var texts []string
+	for _, filename := range product.documentsOfKind(manifest, "tds") {
+		text, err := pdfText(filepath.Join(dir, filename))
+		if err != nil {
+			log.Printf("Failed to read %s: %v", filename, err)
+			continue
+		}
+		texts = append(texts, text)
+		sheets = append(sheets, filename)
+	}
+	claims, _ = canonicalClaims(texts...)
+	return claims, sheets
+}
+
+// checkClaims compares the claims of a product page with those of its data
+// sheets; a product without either has nothing to check
+func checkClaims(product *Product, manifest *Manifest, dir string) (mismatches []ClaimMismatch, unchecked []string, checked bool) {
+	onPage, unchecked := pageClaims(product)
+	inSheets, sheets := sheetClaims(product, manifest, dir)
+	if len(onPage)+len(unchecked) == 0 || len(sheets) == 0 {
+		return nil, unchecked, false
+	}
+	for _, claim := range onPage {
+		if !containsString(inSheets, claim) {
+			mismatches = append(mismatches, ClaimMismatch{product.Slug, claim, "product page", "TDS", sheets})
+		}
+	}
+	for _, claim := range inSheets {
+		if !containsString(onPage, claim) {
+			mismatches = append(mismatches, ClaimMismatch{product.Slug, claim, "TDS", "product page", sheets})
+		}
+	}
+	return mismatches, unchecked, true
+}
+
+// claimsCommand implements "claims": specifications claimed on a product
+// page but not in its TDS, or the other way round
+func claimsCommand(args []string) {
+	flags := flag.NewFlagSet("claims", flag.ExitOnError)
+	fetch := flags.Bool("fetch", false, "fetch the product pages again instead of using the claims recorded in the manifest")
+	asJSON := flags.Bool("json", false, "print the mismatches as JSON")
+	dir := flags.String("dir", "PDFs/", "directory of downloaded PDFs")
+	manifestFile := flags.String("manifest", manifestPath, "manifest written by the crawler")
+	flags.Parse(args)
+
+	manifest := loadManifest(*manifestFile)
+	catalog := loadCatalog(manifest, *dir)
+	products := catalog
+	if flags.NArg() > 0 {
+		products = nil
+		for _, key := range flags.Args() {
+			product := findProduct(catalog, manifest, *dir, key)
+			if product == nil {
+				fmt.Fprintf(os.Stderr, "no product or document matches %q\n", key)
+				os.Exit(1)
+			}
+			products = append(products, product)
+		}
+	}
+
+	var mismatches []ClaimMismatch
+	unchecked := map[string][]string{}
+	checked := 0
+	for _, product := range products {
+		if *fetch && product.URL != "" {
+			if htmlContent := getDataFromURL(product.URL); htmlContent != "" {
+				page := parseProductPage(product.URL, htmlContent)
+				product.Specifications, product.Approvals = page.Specifications, page.Approvals
+			}
+		}
+		found, notRecognised, ok := checkClaims(product, manifest, *dir)
+		if !ok {
+			continue
+		}
+		checked++
+		mismatches = append(mismatches, found...)
+		if len(notRecognised) > 0 {
+			unchecked[product.Slug] = notRecognised
+		}
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(mismatches)
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "PRODUCT\tCLAIM\tCLAIMED ON\tMISSING FROM")
+		for _, mismatch := range mismatches {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", mismatch.Product, mismatch.Claim, mismatch.ClaimedOn, mismatch.MissingFrom)
+		}
+		writer.Flush()
+		var slugs []string
+		for slug := range unchecked {
+			slugs = append(slugs, slug)
+		}
+		sort.Strings(slugs)
+		for _, slug := range slugs {
+			for _, claim := range unchecked[slug] {
+				fmt.Printf("%s: could not check %q\n", slug, claim)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d mismatches in %d products with both product page claims and a TDS\n", len(mismatches), checked)
+	if checked == 0 && !*fetch {
+		fmt.Fprintln(os.Stderr, "the manifest has no product page claims yet; run the crawler or use -fetch")
+	}
+	if len(mismatches) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command is a subcommand of the program; running without a subcommand
+// crawls cam2.com as before
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+// commands lists the subcommands in the order they are shown in the usage
+var commands = []command{
+	{"viscosity", "viscosity-temperature and viscosity index calculator", viscosityCommand},
+	{"find", "search products by SAE, ISO VG and NLGI grade or API/ILSAC category", findCommand},
+	{"categories", "product catalog by category, brand line and application", categoriesCommand},
+	{"product-page", "structured data from a product page or a saved copy of one", productPageCommand},
+	{"claims", "specifications claimed on a product page but not in its TDS, or the reverse", claimsCommand},
+	{"search", "full-text search of the downloaded PDFs, with phrases and SDS section filters", searchCommand},
+	{"serve", "local JSON API and PDF server over the downloaded documents", serveCommand},
+	{"site", "static HTML site of the products, their data sheets and hazards", siteCommand},
+	{"diff", "section-by-section changes between two revisions of a safety data sheet", diffCommand},
+	{"notify", "send a test change to every notification sink in notifications.json", notifyCommand},
+	{"withdrawn", "products and documents CAM2 no longer publishes", withdrawnCommand},
+	{"check-seeds", "status, redirects, canonical URLs and PDF links of every seed page", checkSeedsCommand},
+	{"media", "PDFs in the WordPress media library and whether a product page links them", mediaCommand},
+	{"store", "products in the WooCommerce Store API and whether their pages are seeds", storeCommand},
+	{"documents", "downloaded documents with upload and PDF dates, sorted and filtered by age", documentsCommand},
+	{"stale", "safety data sheets revised too long ago or without a revision date", staleCommand},
+	{"replay", "rerun the crawl against the responses archived in WARC files", replayCommand},
+	{"compare", "side-by-side comparison of products by slug or part number", compareCommand},
+	{"substitute", "rank the closest alternatives to a product by TDS properties and specs", substituteCommand},
+}
+
+// runCommand dispatches to the named subcommand. Commands that reach the
+// network keep to robots.txt like the crawl does.
+func runCommand(name string, args []string) {
+	for _, c := range commands {
+		if c.name == name {
+			defer useRobots().report()
+			c.run(args)
+			return
+		}
+	}
+	if name != "help" && name != "-h" && name != "--help" {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	}
+	printUsage()
+	os.Exit(2)
+}
+
+// printUsage lists the available subcommands
+func printUsage() {
+	fmt.Fprintln(os.Stderr, "usage: go run . [-user-agent agent] [command] [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Without a command the CAM2 data sheets are downloaded into PDFs/.")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", c.name, c.summary)
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// comparisonRow is one line of a side-by-side comparison; each cell is a list
+// of values, e.g. several hazard statements
+type comparisonRow struct {
+	Label   string
+	Cells   [][]string
+	Differs bool
+}
+
+// comparison is a side-by-side table with one column per product
+type comparison struct {
+	Products []string
+	Rows     []comparisonRow
+}
+
+// add appends a row, marking it when the products do not all agree
+func (c *comparison) add(label string, cells [][]string) {
+	row := comparisonRow{Label: label, Cells: cells}
+	for _, cell := range cells[1:] {
+		if strings.Join(cell, "\n") != strings.Join(cells[0], "\n") {
+			row.Differs = true
+		}
+	}
+	c.Rows = append(c.Rows, row)
+}
+
+// productDetails is what the comparison shows about one product
+type productDetails struct {
+	product      *Product
+	partNumbers  []string
+	approvals    []string
+	column       *TDSColumn
+	tdsRevisions []string
+	sheets       []SafetyDataSheet
+}
+
+// selectTDSColumn picks the column of a data sheet table that describes the
+// product, since one sheet often covers a whole product line
+func selectTDSColumn(columns []TDSColumn, product *Product, partNumbers []string) *TDSColumn {
+	for i, column := range columns {
+		for _, number := range partNumbers {
+			if column.ProductCode != "" && strings.HasPrefix(number, column.ProductCode) {
+				return &columns[i]
+			}
+		}
+	}
+	for i, column := range columns {
+		grade := parseGrades(column.Grade, true)
+		if grade.hasViscosityGrade() && product.Grades.contains(grade) {
+			return &columns[i]
+		}
+	}
+	if len(columns) == 1 {
+		return &columns[0]
+	}
+	return nil
+}
+
+// loadProductDetails reads the data sheets of a product
+func loadProductDetails(product *Product, manifest *Manifest, dir string) productDetails {
+	details := productDetails{product: product, partNumbers: product.partNumbers(manifest)}
+	for _, filename := range product.documentsOfKind(manifest, "tds") {
+		text, err := pdfText(filepath.Join(dir, filename))
+		if err != nil {
+			log.Printf("Failed to read %s: %v", filename, err)
+			continue
+		}
+		for _, approval := range parseApprovals(text) {
+			details.approvals = appendUnique(details.approvals, approval)
+		}
+		if details.column == nil {
+			details.column = selectTDSColumn(parseTDSText(text), product, details.partNumbers)
+		}
+		if date, ok := documentRevisionDate(text); ok {
+			details.tdsRevisions = appendUnique(details.tdsRevisions, date.Format("2006-01-02"))
+		}
+	}
+	for _, filename := range product.documentsOfKind(manifest, "sds") {
+		sheet, err := loadSafetyDataSheet(filepath.Join(dir, filename))
+		if err != nil {
+			log.Printf("Failed to read %s: %v", filename, err)
+			continue
+		}
+		details.sheets = append(details.sheets, sheet)
+	}
+	return details
+}
+
+// formatProperty prints a data sheet value, or nothing when it is missing
+func formatProperty(column *TDSColumn, key string) []string {
+	if column == nil {
+		return nil
+	}
+	value, ok := column.Properties[key]
+	if !ok {
+		return nil
+	}
+	if key == propertyFlashPoint || key == propertyPourPoint {
+		return []string{strconv.FormatFloat(value, 'f', 0, 64)}
+	}
+	return []string{strconv.FormatFloat(value, 'f', -1, 64)}
+}
+
+// buildComparison lays out the products side by side
+func buildComparison(products []*Product, manifest *Manifest, dir string) comparison {
+	var details []productDetails
+	var report comparison
+	for _, product := range products {
+		details = append(details, loadProductDetails(product, manifest, dir))
+		report.Products = append(report.Products, product.name())
+	}
+	row := func(label string, cell func(d productDetails) []string) {
+		var cells [][]string
+		for _, d := range details {
+			cells = append(cells, cell(d))
+		}
+		report.add(label, cells)
+	}
+	row("Category", func(d productDetails) []string { return []string{d.product.Category} })
+	row("Brand line", func(d productDetails) []string { return []string{d.product.BrandLine} })
+	row("Application", func(d productDetails) []string { return []string{d.product.Application} })
+	row("Part numbers", func(d productDetails) []string { return d.partNumbers })
+	row("Viscosity grade", func(d productDetails) []string {
+		g := d.product.Grades
+		return Grades{SAEJ300: g.SAEJ300, SAEJ306: g.SAEJ306, ISOVG: g.ISOVG, NLGI: g.NLGI}.labels()
+	})
+	row("API / ILSAC", func(d productDetails) []string {
+		return Grades{API: d.product.Grades.API, ILSAC: d.product.Grades.ILSAC}.labels()
+	})
+	row("OEM specifications", func(d productDetails) []string { return d.approvals })
+	row("Product code", func(d productDetails) []string {
+		if d.column == nil || d.column.ProductCode == "" {
+			return nil
+		}
+		return []string{d.column.ProductCode}
+	})
+	row("Viscosity at 40 °C, cSt", func(d productDetails) []string { return formatProperty(d.column, propertyKV40) })
+	row("Viscosity at 100 °C, cSt", func(d productDetails) []string { return formatProperty(d.column, propertyKV100) })
+	row("Viscosity index", func(d productDetails) []string { return formatProperty(d.column, propertyVI) })
+	row("Flash point, °C", func(d productDetails) []string { return formatProperty(d.column, propertyFlashPoint) })
+	row("Pour point, °C", func(d productDetails) []string { return formatProperty(d.column, propertyPourPoint) })
+	row("Signal word", func(d productDetails) []string {
+		var words []string
+		for _, sheet := range d.sheets {
+			word := sheet.Hazards.SignalWord
+			if word == "" {
+				word = "None"
+			}
+			words = appendUnique(words, word)
+		}
+		return words
+	})
+	row("Hazard classes", func(d productDetails) []string {
+		var classes []string
+		for _, sheet := range d.sheets {
+			for _, class := range sheet.Hazards.Classes {
+				classes = appendUnique(classes, class)
+			}
+		}
+		return classes
+	})
+	row("Hazard statements", func(d productDetails) []string {
+		var statements []string
+		for _, sheet := range d.sheets {
+			for _, code := range sheet.Hazards.Statements {
+				statements = appendUnique(statements, describeHazardStatement(code))
+			}
+		}
+		return statements
+	})
+	row("SDS revision", func(d productDetails) []string {
+		var revisions []string
+		for _, sheet := range d.sheets {
+			revision := sheet.RevisionDate
+			if revision == "" {
+				revision = "unknown"
+			}
+			revisions = appendUnique(revisions, revision)
+		}
+		return revisions
+	})
+	row("TDS revision", func(d productDetails) []string { return d.tdsRevisions })
+	row("Data sheets", func(d productDetails) []string { return d.product.Documents })
+	return report
+}
+
+// escapeMarkdownCell keeps a value from breaking a Markdown table
+func escapeMarkdownCell(value string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(value)
+}
+
+// writeComparisonMarkdown prints the comparison as a Markdown table; values
+// that differ between products are in bold and their row is marked with ≠
+func writeComparisonMarkdown(w io.Writer, report comparison) {
+	fmt.Fprintln(w, "# Product comparison")
+	fmt.Fprintln(w)
+	fmt.Fprint(w, "| |")
+	for _, name := range report.Products {
+		fmt.Fprintf(w, " %s |", escapeMarkdownCell(name))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprint(w, "|---|")
+	fmt.Fprintln(w, strings.Repeat("---|", len(report.Products)))
+	for _, row := range report.Rows {
+		label := row.Label
+		if row.Differs {
+			label += " ≠"
+		}
+		fmt.Fprintf(w, "| %s |", escapeMarkdownCell(label))
+		for _, cell := range row.Cells {
+			var values []string
+			for _, value := range cell {
+				value = escapeMarkdownCell(value)
+				if row.Differs {
+					value = "**" + value + "**"
+				}
+				values = append(values, value)
+			}
+			if len(values) == 0 {
+				values = []string{"–"}
+			}
+			fmt.Fprintf(w, " %s |", strings.Join(values, "<br>"))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Rows marked ≠ differ between the products.")
+}
+
+// comparisonTemplate renders the comparison as a standalone HTML page
+var comparisonTemplate = template.Must(template.New("comparison").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Product comparison</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.4em 0.6em; text-align: left; vertical-align: top; }
+tr.differs td { background: #fff3cd; font-weight: bold; }
+tr.differs th::after { content: " ≠"; color: #b45309; }
+td.missing { color: #999; }
+</style>
+</head>
+<body>
+<h1>Product comparison</h1>
+<table>
+<thead><tr><th></th>{{range .Products}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr{{if .Differs}} class="differs"{{end}}><th>{{.Label}}</th>{{range .Cells}}{{if .}}<td>{{range $i, $v := .}}{{if $i}}<br>{{end}}{{$v}}{{end}}</td>{{else}}<td class="missing">–</td>{{end}}{{end}}</tr>
+{{end}}</tbody>
+</table>
+<p>Highlighted rows differ between the products.</p>
+</body>
+</html>
+`))
+
+// compareCommand implements "compare": a side-by-side table of two or more
+// products given by slug or part number
+func compareCommand(args []string) {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	format := flags.String("format", "markdown", "output format: markdown or html")
+	output := flags.String("o", "", "write the report to this file instead of standard output")
+	dir := flags.String("dir", "PDFs/", "directory of downloaded PDFs")
+	manifestFile := flags.String("manifest", manifestPath, "manifest written by the crawler")
+	flags.Parse(args)
+	if flags.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "usage: go run . compare [flags] <slug or part number> <slug or part number>...")
+		os.Exit(2)
+	}
+	// Checked before -o creates or truncates the output file
+	if *format != "markdown" && *format != "md" && *format != "html" {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		os.Exit(2)
+	}
+
+	manifest := loadManifest(*manifestFile)
+	catalog := loadCatalog(manifest, *dir)
+	var products []*Product
+	for _, key := range flags.Args() {
+		product := findProduct(catalog, manifest, *dir, key)
+		if product == nil {
+			fmt.Fprintf(os.Stderr, "no product or document matches %q\n", key)
+			os.Exit(1)
+		}
+		products = append(products, product)
+	}
+	report := buildComparison(products, manifest, *dir)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer file.Close()
+		w = file
+	}
+	switch *format {
+	case "markdown", "md":
+		writeComparisonMarkdown(w, report)
+	case "html":
+		if err := comparisonTemplate.Execute(w, report); err != nil {
+			log.Println(err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// pdfMagic starts every PDF file. Readers accept it anywhere in the first
+// kilobyte, after junk some servers prepend.
+var pdfMagic = []byte("%PDF-")
+
+// pdfMediaTypes are the Content-Types servers send PDFs with
+var pdfMediaTypes = map[string]bool{
+	"application/pdf":     true,
+	"application/x-pdf":   true,
+	"application/acrobat": true,
+	"text/pdf":            true,
+	"text/x-pdf":          true,
+}
+
+// declaredContentType returns the media type of a Content-Type header in
+// lower case and without parameters such as charset
+func declaredContentType(header string) string {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		mediaType, _, _ = strings.Cut(header, ";")
+	}
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// detectContentType works out the type of a response from its first bytes
+func detectContentType(body []byte) string {
+	head := body
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	if bytes.Contains(head, pdfMagic) {
+		return "application/pdf"
+	}
+	mediaType, _, _ := strings.Cut(http.DetectContentType(body), ";")
+	return mediaType
+}
+
+// typesAgree reports whether a declared type says what was detected; the
+// aliases of application/pdf count as saying it
+func typesAgree(declared, detected string) bool {
+	if detected == "application/pdf" {
+		return pdfMediaTypes[declared]
+	}
+	return declared == detected
+}
+
+// recordContentType notes the declared and detected type of a download
+// when they disagree, and forgets an earlier disagreement when they do not
+func (m *Manifest) recordContentType(sourceURL, declared, detected string) {
+	if detected == "" {
+		return // Nothing was downloaded
+	}
+	document := m.Documents[strings.ToLower(urlToFilename(sourceURL))]
+	if document == nil {
+		return
+	}
+	if typesAgree(declared, detected) {
+		document.DeclaredType, document.DetectedType = "", ""
+		return
+	}
+	document.DeclaredType, document.DetectedType = declared, detected
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	samplePDF       = "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n1 0 obj\n<< /Type /Catalog >>\nendobj\n"
+	sampleErrorPage = "<!DOCTYPE html>\n<html><head><title>Page not found – CAM2</title></head><body><h1>Oops! That page can’t be found.</h1></body></html>\n"
+)
+
+func TestContentTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		body     string
+		declared string
+		detected string
+		agree    bool
+	}{
+		{"pdf", "application/pdf", samplePDF, "application/pdf", "application/pdf", true},
+		{"pdf as octet-stream", "application/octet-stream", samplePDF, "application/octet-stream", "application/pdf", false},
+		{"pdf as x-pdf", "application/x-pdf", samplePDF, "application/x-pdf", "application/pdf", true},
+		{"parameters and case", `Application/PDF; charset=binary; name="80565_082_sds.pdf"`, samplePDF, "application/pdf", "application/pdf", true},
+		{"junk before the header", "application/pdf", "\r\n\xef\xbb\xbf" + samplePDF, "application/pdf", "application/pdf", true},
+		{"html error page as octet-stream", "application/octet-stream", sampleErrorPage, "application/octet-stream", "text/html", false},
+		{"html error page as pdf", "application/pdf", sampleErrorPage, "application/pdf", "text/html", false},
+		{"html with charset", "text/html; charset=UTF-8", sampleErrorPage, "text/html", "text/html", true},
+		{"no content type", "", samplePDF, "", "application/pdf", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			declared, detected := declaredContentType(test.header), detectContentType([]byte(test.body))
+			if declared != test.declared || detected != test.detected {
+				t.Errorf("declared %q, detected %q; want %q, %q", declared, detected, test.declared, test.detected)
+			}
+			if agree := typesAgree(declared, detected); agree != test.agree {
+				t.Errorf("typesAgree = %v, want %v", agree, test.agree)
+			}
+		})
+	}
+}
+
+func TestRecordContentType(t *testing.T) {
+	const sourceURL = "https://cam2.com/wp-content/uploads/2024/05/80565_215_SDS.pdf"
+	document := &Document{Filename: "80565_215_sds.pdf"}
+	manifest := &Manifest{Documents: map[string]*Document{document.Filename: document}}
+
+	manifest.recordContentType(sourceURL, "application/octet-stream", "application/pdf")
+	if document.DeclaredType != "application/octet-stream" || document.DetectedType != "application/pdf" {
+		t.Errorf("disagreement not recorded: %+v", document)
+	}
+	// Nothing downloaded says nothing about the types
+	manifest.recordContentType(sourceURL, "", "")
+	if document.DeclaredType == "" {
+		t.Error("a skipped download cleared the disagreement")
+	}
+	manifest.recordContentType(sourceURL, "application/x-pdf", "application/pdf")
+	if document.DeclaredType != "" || document.DetectedType != "" {
+		t.Errorf("agreement kept the old disagreement: %+v", document)
+	}
+}
+
+func TestDownloadPDFSniffsContent(t *testing.T) {
+	t.Chdir(t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if filepath.Base(r.URL.Path) == "missing_sds.pdf" {
+			io.WriteString(w, sampleErrorPage) // A soft 404
+			return
+		}
+		io.WriteString(w, samplePDF)
+	}))
+	defer server.Close()
+	dir := "PDFs"
+	os.Mkdir(dir, 0o755)
+	manifest := &Manifest{Documents: map[string]*Document{
+		"80565_215_sds.pdf": {Filename: "80565_215_sds.pdf"},
+		"missing_sds.pdf":   {Filename: "missing_sds.pdf"},
+	}}
+
+	for _, test := range []struct {
+		filename string
+		written  bool
+		detected string
+	}{
+		{"80565_215_sds.pdf", true, "application/pdf"},
+		{"missing_sds.pdf", false, "text/html"},
+	} {
+		sourceURL := server.URL + "/wp-content/uploads/2024/05/" + test.filename
+		written, declared, detected := downloadPDF(sourceURL, dir, manifest.Documents[test.filename])
+		manifest.recordContentType(sourceURL, declared, detected)
+		if written != test.written || fileExists(filepath.Join(dir, test.filename)) != test.written {
+			t.Errorf("%s: written %v, want %v", test.filename, written, test.written)
+		}
+		document := manifest.Documents[test.filename]
+		if document.DeclaredType != "application/octet-stream" || document.DetectedType != test.detected {
+			t.Errorf("%s: recorded %q/%q, want application/octet-stream/%s", test.filename, document.DeclaredType, document.DetectedType, test.detected)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+)
+
+// Paths of the change history and the Atom feed rendered from it
+const (
+	feedHistoryPath = "feed.json"
+	feedPath        = "feed.atom"
+)
+
+// feedHistoryLimit is how many changes the history and the feed keep
+const feedHistoryLimit = 200
+
+// atomFeed and the types below are the parts of RFC 4287 the feed uses
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// documentTypeNames name the kinds of document in feed entries
+var documentTypeNames = map[string]string{"sds": "SDS", "tds": "TDS", "other": "Document"}
+
+// loadFeedHistory reads the changes of earlier runs, newest first
+func loadFeedHistory(path string) []ChangeEvent {
+	var history []ChangeEvent
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Println(err)
+		}
+		return nil
+	}
+	if err := json.Unmarshal(content, &history); err != nil {
+		log.Printf("Failed to parse %s: %v", path, err)
+	}
+	return history
+}
+
+// feedEntry turns one change into an Atom entry with links to the original
+// and the local copy of the PDF
+func feedEntry(event ChangeEvent) atomEntry {
+	name := strings.Join(event.Titles, ", ")
+	if name == "" {
+		name = event.PartNumber
+	}
+	if name == "" {
+		name = event.Filename
+	}
+	documentType := documentTypeNames[event.Kind]
+	if documentType == "" {
+		documentType = "Document"
+	}
+	entry := atomEntry{
+		Title:      fmt.Sprintf("%s %s: %s (%s)", documentType, event.Type, name, event.Filename),
+		ID:         fmt.Sprintf("urn:cam2-com-documentation:%s:%s:%s", event.Type, event.Filename, event.Time),
+		Updated:    event.Time,
+		Categories: []atomCategory{{Term: event.Type}, {Term: event.Kind}},
+	}
+	summary := []string{fmt.Sprintf("%s %s %s", documentType, event.Filename, event.Type)}
+	if name != event.Filename {
+		summary = append(summary, "Product: "+name)
+	}
+	if event.Revision != "" {
+		summary = append(summary, "Revision date: "+event.Revision)
+	}
+	if event.Replaces != "" {
+		summary = append(summary, "Replaces: "+event.Replaces)
+	}
+	entry.Summary = strings.Join(summary, ". ") + "."
+	if event.SourceURL != "" {
+		entry.Links = append(entry.Links, atomLink{Rel: "alternate", Href: event.SourceURL, Type: "application/pdf", Title: "Original PDF"})
+	}
+	entry.Links = append(entry.Links, atomLink{Rel: "related", Href: localDocumentPath(event), Type: "application/pdf", Title: "Local copy"})
+	return entry
+}
+
+// localDocumentPath is where the mirror keeps a changed document, relative
+// to the feed
+func localDocumentPath(event ChangeEvent) string {
+	if event.Type == "withdrawn" {
+		return withdrawnDir + event.Filename
+	}
+	return "PDFs/" + event.Filename
+}
+
+// writeFeed adds this run's changes to the history and renders the Atom
+// feed from it. The feed's updated time is that of its newest entry, so a
+// run without changes leaves it as it was.
+func writeFeed(events []ChangeEvent) {
+	history := loadFeedHistory(feedHistoryPath)
+	for i := len(events) - 1; i >= 0; i-- {
+		history = append([]ChangeEvent{events[i]}, history...)
+	}
+	if len(history) > feedHistoryLimit {
+		history = history[:feedHistoryLimit]
+	}
+	if len(events) > 0 {
+		content, err := json.MarshalIndent(history, "", "  ")
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if err := os.WriteFile(feedHistoryPath, append(content, '\n'), 0o644); err != nil {
+			log.Println(err)
+		}
+	}
+
+	feed := atomFeed{
+		Title:   "CAM2 data sheet changes",
+		ID:      "urn:cam2-com-documentation:changes",
+		Updated: "1970-01-01T00:00:00Z",
+		Author:  atomAuthor{Name: "cam2-com-documentation"},
+		Links:   []atomLink{{Rel: "self", Href: feedPath}, {Rel: "alternate", Href: "https://cam2.com/"}},
+	}
+	if len(history) > 0 {
+		feed.Updated = history[0].Time
+	}
+	for _, event := range history {
+		feed.Entries = append(feed.Entries, feedEntry(event))
+	}
+	content, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	writeFileIfChanged(feedPath, append([]byte(xml.Header), append(content, '\n')...))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// findStopWords are query words that carry no meaning of their own
+var findStopWords = map[string]bool{
+	"all": true, "and": true, "the": true, "with": true, "for": true, "of": true,
+	"sae": true, "iso": true, "vg": true, "api": true, "ilsac": true, "nlgi": true, "grade": true,
+	"gl": true, "gf": true, "ck": true, "ci": true, "ch": true, "cj": true, "fa": true, "plus": true,
+}
+
+// queryKeywords returns the words of a query that are not grades, so that
+// "ISO VG 46 hydraulic" also requires "hydraulic" in the product name
+func queryKeywords(query string) []string {
+	var keywords []string
+	for _, word := range strings.Fields(slugToText(query)) {
+		if findStopWords[word] || strings.ContainsAny(word, "0123456789") {
+			continue
+		}
+		if _, isCategory := lenientAPITokens[word]; isCategory {
+			continue
+		}
+		keywords = append(keywords, word)
+	}
+	return keywords
+}
+
+// matchesQuery reports whether a product has every grade and keyword asked for
+func matchesQuery(product *Product, want Grades, keywords []string) bool {
+	if !product.Grades.contains(want) {
+		return false
+	}
+	haystack := slugToText(product.Slug) + " " + strings.ToLower(product.Title)
+	for _, keyword := range keywords {
+		if !strings.Contains(haystack, keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// findCommand implements "find": products matching grade and spec queries
+// such as "all CK-4 15W-40" or "ISO VG 46 hydraulic"
+func findCommand(args []string) {
+	flags := flag.NewFlagSet("find", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print matches as JSON")
+	dir := flags.String("dir", "PDFs/", "directory of downloaded PDFs")
+	manifestFile := flags.String("manifest", manifestPath, "manifest written by the crawler")
+	var filter Taxonomy
+	flags.StringVar(&filter.Category, "category", "", "only products in this category, e.g. \"gear oil\"")
+	flags.StringVar(&filter.BrandLine, "brand", "", "only products of this brand line, e.g. Synavex")
+	flags.StringVar(&filter.Application, "application", "", "only products for this application, e.g. \"HD diesel\"")
+	withdrawn := flags.Bool("withdrawn", false, "also list products CAM2 has withdrawn")
+	// Flags may follow the query, as in "find CK-4 15W-40 -brand Synavex"
+	query := strings.Join(parseInterspersed(flags, args), " ")
+	want := parseGrades(query, true)
+	keywords := queryKeywords(query)
+	if !want.hasViscosityGrade() && !want.hasSpecifications() && len(keywords) == 0 && filter == (Taxonomy{}) {
+		fmt.Fprintln(os.Stderr, "usage: go run . find [flags] <query>, e.g. \"CK-4 15W-40\" or \"ISO VG 46 hydraulic\"")
+		os.Exit(2)
+	}
+
+	manifest := loadManifest(*manifestFile)
+	var matches []*Product
+	for _, product := range loadCatalog(manifest, *dir) {
+		if product.Withdrawn != "" && !*withdrawn {
+			continue
+		}
+		if matchesQuery(product, want, keywords) && product.matchesTaxonomy(filter) {
+			matches = append(matches, product)
+		}
+	}
+
+	if *asJSON {
+		type match struct {
+			*Product
+			Grades Grades `json:"grades"`
+			Taxonomy
+		}
+		var output []match
+		for _, product := range matches {
+			output = append(output, match{Product: product, Grades: product.Grades, Taxonomy: product.taxonomy()})
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(output)
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "PRODUCT\tCATEGORY\tGRADES\tDOCUMENTS")
+	for _, product := range matches {
+		name := product.name()
+		if product.Withdrawn != "" {
+			name += " (withdrawn " + product.Withdrawn + ")"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", name, product.Category, strings.Join(product.Grades.labels(), ", "), strings.Join(product.Documents, " "))
+	}
+	writer.Flush()
+	fmt.Fprintf(os.Stderr, "%d matching products\n", len(matches))
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Grades holds the viscosity grades and service categories found in a
+// product slug, title or data sheet, normalized to their official spelling
+type Grades struct {
+	SAEJ300 []string `json:"sae_j300,omitempty"` // Engine oil grades, e.g. "5W-30", "30"
+	SAEJ306 []string `json:"sae_j306,omitempty"` // Gear oil grades, e.g. "75W-90", "140"
+	ISOVG   []string `json:"iso_vg,omitempty"`   // ISO 3448 viscosity grades, e.g. "46"
+	NLGI    []string `json:"nlgi,omitempty"`     // Grease consistency, e.g. "2"
+	API     []string `json:"api,omitempty"`      // API service categories, e.g. "CK-4", "GL-5"
+	ILSAC   []string `json:"ilsac,omitempty"`    // ILSAC standards, e.g. "GF-6A"
+}
+
+// Official grade sets used to tell the standards apart
+var (
+	j300Monogrades = map[string]bool{"8": true, "12": true, "16": true, "20": true, "30": true, "40": true, "50": true, "60": true}
+	j300Winter     = map[string]bool{"0W": true, "5W": true, "10W": true, "15W": true, "20W": true, "25W": true}
+	j306Monogrades = map[string]bool{"80": true, "85": true, "90": true, "110": true, "140": true, "190": true, "250": true}
+	j306Winter     = map[string]bool{"70W": true, "75W": true, "80W": true, "85W": true}
+	isoGrades      = map[string]bool{"2": true, "3": true, "5": true, "7": true, "10": true, "15": true, "22": true, "32": true,
+		"46": true, "68": true, "100": true, "150": true, "220": true, "320": true, "460": true, "680": true,
+		"1000": true, "1500": true, "2200": true, "3200": true}
+	nlgiGrades = map[string]bool{"000": true, "00": true, "0": true, "1": true, "2": true, "3": true, "4": true, "5": true, "6": true}
+)
+
+// Patterns for grades written out in titles, data sheets and queries; slugs
+// and queries are lowercased with hyphens turned into spaces first
+var (
+	multigradePattern   = regexp.MustCompile(`(?i)\b(\d{1,2})\s*W\s*[-/ ]?\s*(\d{2,3})\b`)
+	winterGradePattern  = regexp.MustCompile(`(?i)\bSAE\s*(\d{1,2})\s*W\b`)
+	monogradePattern    = regexp.MustCompile(`(?i)\bSAE\s*(\d{1,3})\b(\s*W\b)?`)
+	gearNumberPattern   = regexp.MustCompile(`(?i)\bgear\s+(?:oil|lube)\s+(\d{2,3})\b`)
+	isoExplicitPattern  = regexp.MustCompile(`(?i)\bISO\s*(?:VG|grade|viscosity\s+grade)?\s*[:#]?\s*(\d{1,4})\b`)
+	isoShorthandPattern = regexp.MustCompile(`(?i)\b(?:aw|ep|hvi|vg)\s*(\d{2,4})\b`)
+	isoContextPattern   = regexp.MustCompile(`(?i)hydraulic|turbine|compressor|way\s+lube|rock\s+drill|saw\s+guide|heat\s+transfer|industrial|cherry\s+picker|circulating`)
+	isoTrailingPattern  = regexp.MustCompile(`(?i)\b(?:oil|fluid|lube|hydraulic)\s+(\d{2,4})\b`)
+	oddMonogradePattern = regexp.MustCompile(`\b(\d{2})w\b`)
+	nlgiExplicitPattern = regexp.MustCompile(`(?i)\bNLGI\s*(?:grade|no\.?)?\s*#?\s*(000|00|[0-6])\b`)
+	nlgiGreasePattern   = regexp.MustCompile(`(?i)\bep\s*([0-3])\b`)
+	ilsacPattern        = regexp.MustCompile(`(?i)\bGF\s*-?\s*([1-7][AB]?)\b`)
+	apiLinePattern      = regexp.MustCompile(`(?i)\bAPI\b[^\n]{0,120}`)
+	apiCategoryPattern  = regexp.MustCompile(`(?i)\b(S[A-HJ-NPQ](?:\s*PLUS)?|C[A-F](?:\s*-\s*[24])?|C[G-J]\s*-\s*4(?:\s*PLUS)?|CK\s*-\s*4|FA\s*-\s*4|GL\s*-\s*[1-6]|MT\s*-\s*1)\b`)
+)
+
+// lenientAPITokens are service categories accepted without an "API" prefix
+// in slugs and queries, where they cannot be confused with ordinary words
+var lenientAPITokens = map[string]string{
+	"sg": "SG", "sh": "SH", "sj": "SJ", "sl": "SL", "sm": "SM", "sn": "SN", "sp": "SP", "sq": "SQ",
+	"cf": "CF", "cf 2": "CF-2", "cf 4": "CF-4", "cg 4": "CG-4", "ch 4": "CH-4", "ci 4": "CI-4",
+	"ci 4 plus": "CI-4 PLUS", "cj 4": "CJ-4", "ck 4": "CK-4", "fa 4": "FA-4",
+	"gl 1": "GL-1", "gl 4": "GL-4", "gl 5": "GL-5", "gl 6": "GL-6", "mt 1": "MT-1",
+}
+
+// slugToText turns a slug or query into space separated lowercase words
+func slugToText(slug string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.NewReplacer("-", " ", "_", " ", "/", " ").Replace(slug))), " ")
+}
+
+// appendUnique adds value to list unless it is already present
+func appendUnique(list []string, value string) []string {
+	for _, existing := range list {
+		if existing == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+// normalizeAPICategory spells a service category the way API does
+func normalizeAPICategory(raw string) string {
+	category := strings.ToUpper(strings.Join(strings.Fields(raw), ""))
+	category = strings.ReplaceAll(category, "PLUS", " PLUS")
+	return category
+}
+
+// parseGrades finds grades and service categories in free text such as a
+// title or a data sheet. With lenient set the text is treated as a slug or
+// search query, where API categories may appear without the "API" prefix.
+func parseGrades(text string, lenient bool) Grades {
+	var grades Grades
+	if lenient {
+		text = slugToText(text)
+	}
+	addSAE := func(low, high string) {
+		winter := strings.ToUpper(low) + "W"
+		switch {
+		case j306Winter[winter] && (high == "" || j306Monogrades[high]):
+			if high == "" {
+				grades.SAEJ306 = appendUnique(grades.SAEJ306, winter)
+			} else {
+				grades.SAEJ306 = appendUnique(grades.SAEJ306, winter+"-"+high)
+			}
+		case j300Winter[winter] && (high == "" || j300Monogrades[high]):
+			if high == "" {
+				grades.SAEJ300 = appendUnique(grades.SAEJ300, winter)
+			} else {
+				grades.SAEJ300 = appendUnique(grades.SAEJ300, winter+"-"+high)
+			}
+		}
+	}
+	consumed := text
+	for _, match := range multigradePattern.FindAllStringSubmatch(text, -1) {
+		addSAE(match[1], match[2])
+		consumed = strings.Replace(consumed, match[0], " ", 1)
+	}
+	for _, match := range winterGradePattern.FindAllStringSubmatch(consumed, -1) {
+		addSAE(match[1], "")
+	}
+	for _, match := range monogradePattern.FindAllStringSubmatch(consumed, -1) {
+		if match[2] != "" {
+			continue // A winter grade, handled above
+		}
+		switch {
+		case j300Monogrades[match[1]]:
+			grades.SAEJ300 = appendUnique(grades.SAEJ300, match[1])
+		case j306Monogrades[match[1]]:
+			grades.SAEJ306 = appendUnique(grades.SAEJ306, match[1])
+		}
+	}
+	if lenient { // Slugs such as "superpro-max-30w" mean SAE 30
+		for _, match := range oddMonogradePattern.FindAllStringSubmatch(consumed, -1) {
+			if j300Monogrades[match[1]] && !j300Winter[match[1]+"W"] {
+				grades.SAEJ300 = appendUnique(grades.SAEJ300, match[1])
+			}
+		}
+	}
+	industrial := isoContextPattern.MatchString(text)
+	for _, match := range gearNumberPattern.FindAllStringSubmatch(consumed, -1) {
+		if j306Monogrades[match[1]] && !industrial {
+			grades.SAEJ306 = appendUnique(grades.SAEJ306, match[1])
+		}
+	}
+	for _, match := range isoExplicitPattern.FindAllStringSubmatch(consumed, -1) {
+		if isoGrades[match[1]] {
+			grades.ISOVG = appendUnique(grades.ISOVG, match[1])
+		}
+	}
+	if industrial {
+		candidates := isoShorthandPattern.FindAllStringSubmatch(consumed, -1)
+		candidates = append(candidates, isoTrailingPattern.FindAllStringSubmatch(consumed, -1)...)
+		for _, match := range candidates {
+			if value, _ := strconv.Atoi(match[1]); value >= 10 && isoGrades[match[1]] {
+				grades.ISOVG = appendUnique(grades.ISOVG, match[1])
+			}
+		}
+	}
+	for _, match := range nlgiExplicitPattern.FindAllStringSubmatch(text, -1) {
+		grades.NLGI = appendUnique(grades.NLGI, match[1])
+	}
+	if strings.Contains(strings.ToLower(text), "grease") {
+		for _, match := range nlgiGreasePattern.FindAllStringSubmatch(text, -1) {
+			if nlgiGrades[match[1]] {
+				grades.NLGI = appendUnique(grades.NLGI, match[1])
+			}
+		}
+	}
+	for _, match := range ilsacPattern.FindAllStringSubmatch(text, -1) {
+		grades.ILSAC = appendUnique(grades.ILSAC, "GF-"+strings.ToUpper(match[1]))
+	}
+	for _, line := range apiLinePattern.FindAllString(text, -1) {
+		for _, match := range apiCategoryPattern.FindAllString(line, -1) {
+			grades.API = appendUnique(grades.API, normalizeAPICategory(match))
+		}
+	}
+	if lenient {
+		words := strings.Fields(text)
+		for i := range words {
+			// Try the longest phrase first so "ci 4 plus" wins over "ci 4"
+			for length := 3; length >= 1; length-- {
+				if i+length > len(words) {
+					continue
+				}
+				if category, ok := lenientAPITokens[strings.Join(words[i:i+length], " ")]; ok {
+					grades.API = appendUnique(grades.API, category)
+					break
+				}
+			}
+		}
+	}
+	grades.sort()
+	return grades
+}
+
+// sort orders every list so output and comparisons are stable
+func (g *Grades) sort() {
+	for _, list := range []*[]string{&g.SAEJ300, &g.SAEJ306, &g.ISOVG, &g.NLGI, &g.API, &g.ILSAC} {
+		sort.Strings(*list)
+	}
+}
+
+// merge adds everything from other that g does not have yet
+func (g *Grades) merge(other Grades) {
+	pairs := [][2]*[]string{
+		{&g.SAEJ300, &other.SAEJ300}, {&g.SAEJ306, &other.SAEJ306}, {&g.ISOVG, &other.ISOVG},
+		{&g.NLGI, &other.NLGI}, {&g.API, &other.API}, {&g.ILSAC, &other.ILSAC},
+	}
+	for _, pair := range pairs {
+		for _, value := range *pair[1] {
+			*pair[0] = appendUnique(*pair[0], value)
+		}
+	}
+	g.sort()
+}
+
+// hasViscosityGrade reports whether any viscosity or consistency grade is set
+func (g Grades) hasViscosityGrade() bool {
+	return len(g.SAEJ300)+len(g.SAEJ306)+len(g.ISOVG)+len(g.NLGI) > 0
+}
+
+// hasSpecifications reports whether any API or ILSAC category is set
+func (g Grades) hasSpecifications() bool {
+	return len(g.API)+len(g.ILSAC) > 0
+}
+
+// labels returns every grade with its standard, e.g. "SAE 5W-30", "ISO VG 46"
+func (g Grades) labels() []string {
+	var labels []string
+	for _, value := range g.SAEJ300 {
+		labels = append(labels, "SAE "+value)
+	}
+	for _, value := range g.SAEJ306 {
+		labels = append(labels, "SAE "+value+" (gear)")
+	}
+	for _, value := range g.ISOVG {
+		labels = append(labels, "ISO VG "+value)
+	}
+	for _, value := range g.NLGI {
+		labels = append(labels, "NLGI "+value)
+	}
+	for _, value := range g.API {
+		labels = append(labels, "API "+value)
+	}
+	for _, value := range g.ILSAC {
+		labels = append(labels, "ILSAC "+value)
+	}
+	return labels
+}
+
+// contains reports whether g has every grade that want has
+func (g Grades) contains(want Grades) bool {
+	pairs := [][2][]string{
+		{g.SAEJ300, want.SAEJ300}, {g.SAEJ306, want.SAEJ306}, {g.ISOVG, want.ISOVG},
+		{g.NLGI, want.NLGI}, {g.API, want.API}, {g.ILSAC, want.ILSAC},
+	}
+	for _, pair := range pairs {
+		for _, value := range pair[1] {
+			found := false
+			for _, have := range pair[0] {
+				if have == value {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
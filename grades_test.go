@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGrades(t *testing.T) {
+	tests := []struct {
+		text    string
+		lenient bool
+		want    Grades
+	}{
+		// Slugs
+		{"cam2-synavex-5w-30-sp-gf-6a-full-synthetic-engine-oil", true, Grades{SAEJ300: []string{"5W-30"}, API: []string{"SP"}, ILSAC: []string{"GF-6A"}}},
+		{"cam2-synavex-0w-16-sp-gf-6b-full-synthetic-engine-oil", true, Grades{SAEJ300: []string{"0W-16"}, API: []string{"SP"}, ILSAC: []string{"GF-6B"}}},
+		{"cam2-aw-hydraulic-oil-iso-46", true, Grades{ISOVG: []string{"46"}}},
+		{"cam2-aw-46-hydraulic-oil", true, Grades{ISOVG: []string{"46"}}},
+		{"cam2-ep-220-synthetic-industrial-gear-oil", true, Grades{ISOVG: []string{"220"}}},
+		{"cam2-lithium-ep-2-grease", true, Grades{NLGI: []string{"2"}}},
+		{"cam2-super-hd-10w-40-ck-4-heavy-duty-engine-oil", true, Grades{SAEJ300: []string{"10W-40"}, API: []string{"CK-4"}}},
+		{"cam2-super-hd-15w-40-performance-driven-ck-4-sn-synthetic-blend-engine-oil", true, Grades{SAEJ300: []string{"15W-40"}, API: []string{"CK-4", "SN"}}},
+		{"cam2-magnum-gear-oil-sae-80w-90-gl-5", true, Grades{SAEJ306: []string{"80W-90"}, API: []string{"GL-5"}}},
+		{"cam2-85w-140-high-performance-ep-gear-oil-gl-5", true, Grades{SAEJ306: []string{"85W-140"}, API: []string{"GL-5"}}},
+		{"cam2-superpro-max-30w", true, Grades{SAEJ300: []string{"30"}}},
+		// Queries
+		{"CK-4 15W-40", true, Grades{SAEJ300: []string{"15W-40"}, API: []string{"CK-4"}}},
+		{"ISO VG 46 hydraulic", true, Grades{ISOVG: []string{"46"}}},
+		// Data sheet text, where categories need the "API" prefix
+		{"SAE 15W-40\nAPI Service CK-4, CJ-4, CI-4 PLUS, SN\n", false, Grades{SAEJ300: []string{"15W-40"}, API: []string{"CI-4 PLUS", "CJ-4", "CK-4", "SN"}}},
+		{"Meets API SP, ILSAC GF-6A\nSAE Viscosity Grade 5W-30", false, Grades{SAEJ300: []string{"5W-30"}, API: []string{"SP"}, ILSAC: []string{"GF-6A"}}},
+		{"API GL-5, MT-1\nSAE 75W-90", false, Grades{SAEJ306: []string{"75W-90"}, API: []string{"GL-5", "MT-1"}}},
+		{"ISO VG 68 anti-wear hydraulic fluid", false, Grades{ISOVG: []string{"68"}}},
+		{"NLGI Grade 2 lithium complex grease", false, Grades{NLGI: []string{"2"}}},
+		{"SAE 30 heavy duty motor oil", false, Grades{SAEJ300: []string{"30"}}},
+		// Words that look like categories outside an API line are left alone
+		{"Flash point (COC) SP 230 °C", false, Grades{}},
+	}
+	for _, test := range tests {
+		if got := parseGrades(test.text, test.lenient); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseGrades(%q, %v) = %+v, want %+v", test.text, test.lenient, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// harLog and the types below are the parts of HAR 1.2 the crawler writes
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+
+	mu sync.Mutex
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"` // Total of the timings, ms
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
+	Error           string      `json:"_error,omitempty"` // Why no response arrived
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harTimings are in milliseconds, -1 when a phase did not happen, such as
+// DNS and connect on a reused connection. Connect includes SSL.
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// newHARLog starts an empty HAR log
+func newHARLog() *harLog {
+	return &harLog{Version: "1.2", Creator: harCreator{Name: "cam2-com-documentation", Version: "1.0"}, Entries: []harEntry{}}
+}
+
+// add appends a finished entry
+func (l *harLog) add(entry harEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Entries = append(l.Entries, entry)
+}
+
+// save writes the log as a .har file, entries in the order they started
+func (l *harLog) save(path string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	sort.SliceStable(l.Entries, func(i, j int) bool { return l.Entries[i].StartedDateTime < l.Entries[j].StartedDateTime })
+	content, err := json.MarshalIndent(map[string]*harLog{"log": l}, "", "  ")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		log.Println(err)
+		return
+	}
+	log.Printf("Wrote %d requests to %s", len(l.Entries), path)
+}
+
+// harHeaders converts headers to HAR name/value pairs, sorted by name
+func harHeaders(header http.Header) []harNameValue {
+	pairs := []harNameValue{}
+	for name, values := range header {
+		for _, value := range values {
+			pairs = append(pairs, harNameValue{name, value})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	return pairs
+}
+
+// harClock collects the httptrace events of one request
+type harClock struct {
+	mu                           sync.Mutex
+	start, gotConn, wroteRequest time.Time
+	dnsStart, dnsDone            time.Time
+	connectStart, connectDone    time.Time
+	tlsStart, tlsDone            time.Time
+	firstByte, bodyDone          time.Time
+	serverIP, connection         string
+}
+
+// trace returns the httptrace hooks that fill in the clock
+func (c *harClock) trace() *httptrace.ClientTrace {
+	mark := func(at *time.Time) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if at.IsZero() {
+			*at = time.Now()
+		}
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { mark(&c.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { mark(&c.dnsDone) },
+		ConnectStart:      func(string, string) { mark(&c.connectStart) },
+		ConnectDone:       func(string, string, error) { mark(&c.connectDone) },
+		TLSHandshakeStart: func() { mark(&c.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { mark(&c.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			mark(&c.gotConn)
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if info.Conn != nil {
+				if host, _, err := net.SplitHostPort(info.Conn.RemoteAddr().String()); err == nil {
+					c.serverIP = host
+				}
+				// The local port tells connections apart
+				if _, port, err := net.SplitHostPort(info.Conn.LocalAddr().String()); err == nil {
+					c.connection = port
+				}
+			}
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { mark(&c.wroteRequest) },
+		GotFirstResponseByte: func() { mark(&c.firstByte) },
+	}
+}
+
+// milliseconds returns the time between two events, or -1 when either is
+// missing
+func milliseconds(from, to time.Time) float64 {
+	if from.IsZero() || to.IsZero() {
+		return -1
+	}
+	return float64(to.Sub(from).Microseconds()) / 1000
+}
+
+// timings works out the HAR timings from the events
+func (c *harClock) timings() harTimings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timings := harTimings{
+		DNS:     milliseconds(c.dnsStart, c.dnsDone),
+		Connect: -1,
+		SSL:     milliseconds(c.tlsStart, c.tlsDone),
+		Send:    milliseconds(c.gotConn, c.wroteRequest),
+		Wait:    milliseconds(c.wroteRequest, c.firstByte),
+		Receive: milliseconds(c.firstByte, c.bodyDone),
+	}
+	if !c.connectStart.IsZero() {
+		connected := c.connectDone
+		if !c.tlsDone.IsZero() {
+			connected = c.tlsDone
+		}
+		timings.Connect = milliseconds(c.connectStart, connected)
+	}
+	// Blocked is the time waiting for a connection that DNS and connecting
+	// do not account for
+	timings.Blocked = milliseconds(c.start, c.gotConn)
+	for _, phase := range []float64{timings.DNS, timings.Connect} {
+		if phase > 0 && timings.Blocked >= 0 {
+			timings.Blocked -= phase
+		}
+	}
+	if timings.Blocked < 0 && !c.gotConn.IsZero() {
+		timings.Blocked = 0
+	}
+	timings.Blocked = math.Round(timings.Blocked*1000) / 1000
+	return timings
+}
+
+// total adds up the timings that happened, as HAR's entry time
+func (t harTimings) total() float64 {
+	sum := 0.0
+	for _, phase := range []float64{t.Blocked, t.DNS, t.Connect, t.Send, t.Wait, t.Receive} {
+		if phase > 0 {
+			sum += phase
+		}
+	}
+	return math.Round(sum*1000) / 1000
+}
+
+// harTransport records each request that passes through it in a HAR log.
+// An entry is added when the response body has been read and closed.
+type harTransport struct {
+	next http.RoundTripper
+	log  *harLog
+}
+
+func (t *harTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	clock := &harClock{start: time.Now()}
+	request = request.WithContext(httptrace.WithClientTrace(request.Context(), clock.trace()))
+	entry := harEntry{
+		StartedDateTime: clock.start.UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		Request: harRequest{
+			Method:      request.Method,
+			URL:         request.URL.String(),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(request.Header),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+	}
+	for name, values := range request.URL.Query() {
+		for _, value := range values {
+			entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{name, value})
+		}
+	}
+	response, err := t.next.RoundTrip(request)
+	if err != nil {
+		entry.Error = err.Error()
+		entry.Response = harResponse{Cookies: []harNameValue{}, Headers: []harNameValue{}, HeadersSize: -1, BodySize: -1, HTTPVersion: "unknown"}
+		entry.Timings = clock.timings()
+		entry.Time = entry.Timings.total()
+		t.log.add(entry)
+		return nil, err
+	}
+	entry.Response = harResponse{
+		Status:      response.StatusCode,
+		HTTPVersion: response.Proto,
+		Cookies:     []harNameValue{},
+		Headers:     harHeaders(response.Header),
+		Content:     harContent{MimeType: response.Header.Get("Content-Type")},
+		RedirectURL: response.Header.Get("Location"),
+		HeadersSize: -1,
+	}
+	if _, text, found := strings.Cut(response.Status, " "); found {
+		entry.Response.StatusText = text
+	}
+	response.Body = &harBody{ReadCloser: response.Body, finish: func(size int64) {
+		clock.mu.Lock()
+		clock.bodyDone = time.Now()
+		entry.ServerIPAddress, entry.Connection = clock.serverIP, clock.connection
+		clock.mu.Unlock()
+		entry.Response.BodySize, entry.Response.Content.Size = size, size
+		entry.Timings = clock.timings()
+		entry.Time = entry.Timings.total()
+		t.log.add(entry)
+	}}
+	return response, nil
+}
+
+// harBody counts the bytes of a response body and reports once it is closed
+type harBody struct {
+	io.ReadCloser
+	size   int64
+	once   sync.Once
+	finish func(size int64)
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	return n, err
+}
+
+func (b *harBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.finish(b.size) })
+	return err
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHARTransport(t *testing.T) {
+	const page = "<html><body>CAM2 Synavex 5W-30</body></html>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		io.WriteString(w, page)
+	}))
+	defer server.Close()
+
+	archive := newHARLog()
+	// A transport of its own, so the first request opens a connection
+	client := &http.Client{Transport: &harTransport{next: &http.Transport{}, log: archive}}
+	for _, path := range []string{"/product/cam2-synavex-5w-30/?attribute_pa_size=6-1-quart", "/missing/"} {
+		response, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.ReadAll(response.Body)
+		response.Body.Close()
+	}
+	// A request that gets no response is kept with its error
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	if _, err := client.Get(closed.URL + "/"); err == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+
+	path := filepath.Join(t.TempDir(), "crawl.har")
+	archive.save(path)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved struct {
+		Log struct {
+			Version string     `json:"version"`
+			Entries []harEntry `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(content, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Log.Version != "1.2" || len(saved.Log.Entries) != 3 {
+		t.Fatalf("version %q with %d entries", saved.Log.Version, len(saved.Log.Entries))
+	}
+
+	tests := []struct {
+		status     int
+		statusText string
+		size       int64
+		mimeType   string
+		connected  bool // Opened a connection rather than reusing one
+	}{
+		{http.StatusOK, "OK", int64(len(page)), "text/html; charset=UTF-8", true},
+		{http.StatusNotFound, "Not Found", int64(len("404 page not found\n")), "text/plain; charset=utf-8", false},
+	}
+	for i, test := range tests {
+		entry := saved.Log.Entries[i]
+		response := entry.Response
+		if response.Status != test.status || response.StatusText != test.statusText || response.HTTPVersion != "HTTP/1.1" {
+			t.Errorf("entry %d: %d %q %s", i, response.Status, response.StatusText, response.HTTPVersion)
+		}
+		if response.BodySize != test.size || response.Content.Size != test.size || response.Content.MimeType != test.mimeType {
+			t.Errorf("entry %d: body %d, content %+v; want %d bytes of %s", i, response.BodySize, response.Content, test.size, test.mimeType)
+		}
+		if entry.ServerIPAddress != "127.0.0.1" || entry.Connection == "" {
+			t.Errorf("entry %d: server %q, connection %q", i, entry.ServerIPAddress, entry.Connection)
+		}
+		timings := entry.Timings
+		for name, phase := range map[string]float64{"blocked": timings.Blocked, "send": timings.Send, "wait": timings.Wait, "receive": timings.Receive} {
+			if phase < 0 {
+				t.Errorf("entry %d: %s %v, want a duration", i, name, phase)
+			}
+		}
+		if connected := timings.Connect >= 0; connected != test.connected {
+			t.Errorf("entry %d: connect %v", i, timings.Connect)
+		}
+		// An IP address needs no lookup, and plain HTTP no handshake
+		if timings.DNS != -1 || timings.SSL != -1 {
+			t.Errorf("entry %d: dns %v, ssl %v, want -1", i, timings.DNS, timings.SSL)
+		}
+		if entry.Time < 0 || entry.Time != timings.total() {
+			t.Errorf("entry %d: time %v, timings add up to %v", i, entry.Time, timings.total())
+		}
+	}
+	if first, second := saved.Log.Entries[0], saved.Log.Entries[1]; first.Connection != second.Connection {
+		t.Errorf("connections %s and %s, want the first one reused", first.Connection, second.Connection)
+	}
+	if query := saved.Log.Entries[0].Request.QueryString; len(query) != 1 || query[0] != (harNameValue{"attribute_pa_size", "6-1-quart"}) {
+		t.Errorf("query string %+v", query)
+	}
+
+	failed := saved.Log.Entries[2]
+	if failed.Error == "" || failed.Response.Status != 0 || failed.Response.BodySize != -1 || failed.Time < 0 {
+		t.Errorf("failed request: %+v", failed)
+	}
+}
@@ -2,6 +2,10 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -146,97 +150,185 @@ func urlToFilename(rawURL string) string {
 	return safe // Return sanitized filename
 }
 
-// Downloads a PDF from given URL and saves it in the specified directory
-func downloadPDF(finalURL, outputDir string) bool {
+// Downloads a PDF from given URL and saves it in the specified directory. A
+// copy that is already there is revalidated with the validators recorded in
+// document, or fetched again when it came from another URL, and replaced
+// when the server has different bytes; the replaced copy is kept in
+// withdrawn/. It returns whether the file was written, the Content-Type the
+// server declared and the type detected from the bytes, the latter empty
+// when nothing was downloaded.
+func downloadPDF(finalURL, outputDir string, document *Document) (bool, string, string) {
 	filename := strings.ToLower(urlToFilename(finalURL)) // Sanitize the filename
 	filePath := filepath.Join(outputDir, filename)       // Construct full path for output file
 
-	if fileExists(filePath) { // Skip if file already exists
+	exists := fileExists(filePath)
+	if exists && document == nil { // Nothing to revalidate it with
 		log.Printf("File already exists, skipping: %s", filePath)
-		return false
+		return false, "", ""
 	}
 
-	client := &http.Client{Timeout: 15 * time.Minute} // Create HTTP client with timeout
+	client := &http.Client{Timeout: 15 * time.Minute, Transport: httpTransport} // Create HTTP client with timeout
 
 	// Create a new request so we can set headers
 	req, err := http.NewRequest("GET", finalURL, nil)
 	if err != nil {
 		log.Printf("Failed to create request for %s: %v", finalURL, err)
-		return false
+		return false, "", ""
 	}
 
 	// Set a User-Agent header
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/139.0.0.0 Safari/537.36")
+	req.Header.Set("User-Agent", userAgent)
+	if exists && document.FetchedURL == finalURL {
+		if document.ETag != "" {
+			req.Header.Set("If-None-Match", document.ETag)
+		}
+		if document.LastModified != "" {
+			req.Header.Set("If-Modified-Since", document.LastModified)
+		}
+	}
 
 	// Send the request
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Printf("Failed to download %s: %v", finalURL, err)
-		return false
+		return false, "", ""
 	}
 	defer resp.Body.Close() // Ensure response body is closed
 
+	if resp.StatusCode == http.StatusNotModified && exists {
+		log.Printf("Not modified: %s", finalURL)
+		return false, "", ""
+	}
 	if resp.StatusCode != http.StatusOK { // Check if response is 200 OK
 		log.Printf("Download failed for %s: %s", finalURL, resp.Status)
-		return false
-	}
-
-	contentType := resp.Header.Get("Content-Type") // Get content type of response
-	if !strings.Contains(contentType, "binary/octet-stream") &&
-		!strings.Contains(contentType, "application/pdf") {
-		log.Printf("Invalid content type for %s: %s (expected PDF)", finalURL, contentType)
-		return false
+		return false, "", ""
 	}
 
 	var buf bytes.Buffer                     // Create a buffer to hold response data
 	written, err := io.Copy(&buf, resp.Body) // Copy data into buffer
 	if err != nil {
 		log.Printf("Failed to read PDF data from %s: %v", finalURL, err)
-		return false
+		return false, "", ""
 	}
 	if written == 0 { // Skip empty files
 		log.Printf("Downloaded 0 bytes for %s; not creating file", finalURL)
-		return false
+		return false, "", ""
 	}
 
-	out, err := os.Create(filePath) // Create output file
+	// The bytes decide whether it is a PDF; the Content-Type is only a hint
+	declared := declaredContentType(resp.Header.Get("Content-Type"))
+	detected := detectContentType(buf.Bytes())
+	if detected != "application/pdf" {
+		log.Printf("Not a PDF: %s is %s (served as %q)", finalURL, detected, declared)
+		return false, declared, detected
+	}
+	if !typesAgree(declared, detected) {
+		log.Printf("%s is a PDF served as %q", finalURL, declared)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	digest := hex.EncodeToString(sum[:])
+	// Remember how to revalidate the copy once it is in place
+	remember := func() {
+		if document != nil {
+			document.FetchedURL, document.SHA256 = finalURL, digest
+			document.ETag, document.LastModified = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		}
+	}
+	if exists {
+		if current, err := os.ReadFile(filePath); err == nil && sha256.Sum256(current) == sum {
+			log.Printf("Unchanged: %s", finalURL)
+			remember()
+			return false, declared, detected
+		}
+	}
+
+	// Write next to the file and rename, so an interrupted download never
+	// leaves half a PDF in its place
+	out, err := os.CreateTemp(outputDir, ".download-*.pdf")
 	if err != nil {
 		log.Printf("Failed to create file for %s: %v", finalURL, err)
-		return false
+		return false, declared, detected
 	}
-	defer out.Close() // Ensure file is closed after writing
+	defer os.Remove(out.Name()) // Gone already once renamed
 
 	if _, err := buf.WriteTo(out); err != nil { // Write buffer contents to file
+		out.Close()
 		log.Printf("Failed to write PDF to file for %s: %v", finalURL, err)
-		return false
+		return false, declared, detected
+	}
+	if err := out.Close(); err != nil {
+		log.Printf("Failed to write PDF to file for %s: %v", finalURL, err)
+		return false, declared, detected
+	}
+	if exists {
+		// Earlier revisions are never deleted
+		name, err := supersede(filename, outputDir, time.Now().Format("2006-01-02"))
+		if err != nil {
+			log.Printf("Not replacing %s: %v", filePath, err)
+			return false, declared, detected
+		}
+		document.Superseded = append(document.Superseded, name)
+	}
+	if err := os.Rename(out.Name(), filePath); err != nil {
+		log.Printf("Failed to write PDF to file for %s: %v", finalURL, err)
+		return false, declared, detected
+	}
+	os.Chmod(filePath, 0o644) // CreateTemp makes it private
+	remember()
+	if document != nil {
+		// Read the dates of the new bytes again
+		document.PDFInfo, document.RevisionDate = nil, ""
 	}
 
-	log.Printf("Successfully downloaded %d bytes: %s → %s", written, finalURL, filePath) // Log success
-	return true
+	if exists {
+		log.Printf("Replaced with %d changed bytes: %s → %s", written, finalURL, filePath)
+	} else {
+		log.Printf("Successfully downloaded %d bytes: %s → %s", written, finalURL, filePath) // Log success
+	}
+	return true, declared, detected
 }
 
 // Performs HTTP GET request with a custom User-Agent and returns response body as string
 func getDataFromURL(uri string) string {
+	body, _ := fetchURL(uri)
+	return body
+}
+
+// fetchURL performs the request for getDataFromURL and also returns the
+// status code, 0 when no response arrived. The body is empty unless the
+// status is 2xx, so error pages are never parsed as product pages.
+func fetchURL(uri string) (string, int) {
+	body, status, _ := requestURL(uri, nil)
+	return body, status
+}
+
+// requestURL is fetchURL with extra request headers, also returning the
+// response headers
+func requestURL(uri string, header http.Header) (string, int, http.Header) {
 	log.Println("Scraping", uri) // Log which URL is being scraped
 
 	// Create a new HTTP client
-	client := &http.Client{}
+	client := &http.Client{Transport: httpTransport}
 
 	// Create a new request
 	request, err := http.NewRequest("GET", uri, nil)
 	if err != nil {
 		log.Println("Error creating request:", err)
-		return ""
+		return "", 0, nil
 	}
 
 	// Set a User-Agent header
-	request.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/139.0.0.0 Safari/537.36")
+	request.Header.Set("User-Agent", userAgent)
+	for name, values := range header {
+		request.Header[name] = values
+	}
 
 	// Send the request
 	response, err := client.Do(request)
 	if err != nil {
 		log.Println("Request error:", err)
-		return ""
+		return "", 0, nil
 	}
 	defer func() {
 		if cerr := response.Body.Close(); cerr != nil {
@@ -244,30 +336,22 @@ func getDataFromURL(uri string) string {
 		}
 	}()
 
+	// Check the status before reading the body
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		if response.StatusCode != http.StatusNotModified {
+			log.Printf("Scraping %s failed: %s", uri, response.Status)
+		}
+		return "", response.StatusCode, response.Header
+	}
+
 	// Read the response body
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
 		log.Println("Error reading body:", err)
-		return ""
+		return "", response.StatusCode, response.Header
 	}
 
-	return string(body)
-}
-
-// Append and write to file
-func appendAndWriteToFile(path string, content string) {
-	filePath, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Println(err)
-	}
-	_, err = filePath.WriteString(content + "\n")
-	if err != nil {
-		log.Println(err)
-	}
-	err = filePath.Close()
-	if err != nil {
-		log.Println(err)
-	}
+	return string(body), response.StatusCode, response.Header
 }
 
 // Read a file and return the contents
@@ -279,38 +363,321 @@ func readAFileAsString(path string) string {
 	return string(content)
 }
 
-// extractBaseDomain takes a URL string and returns only the bare domain name
-// without any subdomains or suffixes (e.g., ".com", ".org", ".co.uk").
-func extractBaseDomain(inputUrl string) string {
-	// Parse the input string into a structured URL object
-	parsedUrl, parseError := url.Parse(inputUrl)
-
-	// If parsing fails, log the error and return an empty string
-	if parseError != nil {
-		log.Println("Error parsing URL:", parseError)
-		return ""
-	}
+// The pages on cam2.com that link to data sheets, mostly product pages
+var remoteURL = []string{
+	"https://cam2.com/data-sheets/",
+	"https://cam2.com/product/cam2-premium-synthetic-blend-tc-w3-2-cycle-outboard-oil/",
+	"https://cam2.com/product/cam2-magnum-economy-2-cycle-engine-oil/",
+	"https://cam2.com/product/cam2-2-cycle-engine-oil-air-cooled/",
+	"https://cam2.com/product/cam2-blue-blood-12-2-6-ounce-2-cycle-synthetic-engine-oil/",
+	"https://cam2.com/product/cam2-blue-blood-marine-2-cycle-oil-tc-w3/",
+	"https://cam2.com/product/cam2-nitrile-gloves-8mil-black-medium/",
+	"https://cam2.com/product/cam2-promax-r-o-hydraulic-oil/",
+	"https://cam2.com/product/cam2-full-synthetic-global-low-vis-atf/",
+	"https://cam2.com/product/cam2-synavex-dexos1-gen-3-sae-5w-30-sp-gf-6a-full-synthetic-motor-oil/",
+	"https://cam2.com/product/cam2-synavex-dexos1-gen-3-sae-0w-20-sp-gf-6a-full-synthetic-motor-oil/",
+	"https://cam2.com/product/cam2-magnum-gear-oil-sae-80w-90-gl-5/",
+	"https://cam2.com/product/cam2-magnum-gear-oil-sae-75w-140-gl-5/",
+	"https://cam2.com/product/cam2-magnum-gear-oil-sae-75w-90-gl-5/",
+	"https://cam2.com/product/cam2-k-1-kerosene/",
+	"https://cam2.com/product/hand-sanitizer/",
+	"https://cam2.com/product/cam2-blue-blood-elite-hd-5w-40-ck-4-w-detox-technology/",
+	"https://cam2.com/product/cam2-ngeo-sae-15w-40-ces-20074-engine-oil/",
+	"https://cam2.com/product/cam2-geo-sae-30-ashless-engine-oil/",
+	"https://cam2.com/product/cam2-ngeo-low-ash-engine-oil-sae-30/",
+	"https://cam2.com/product/cam2-ngeo-low-ash-engine-oil-sae-40/",
+	"https://cam2.com/product/cam2-super-hd-15w-40-performance-driven-ck-4-sn-synthetic-blend-engine-oil/",
+	"https://cam2.com/product/cam-2-super-hd-10w-30-performance-driven-ck-4-synthetic-blend-engine-oil/",
+	"https://cam2.com/product/cam2-super-hd-sae-50-api-cf-cf-2-sl-engine-oil/",
+	"https://cam2.com/product/cam2-super-hd-sae-40-api-cf-cf-2-sl-engine-oil/",
+	"https://cam2.com/product/cam2-super-hd-sae-30-api-cf-cf-2-sl-engine-oil/",
+	"https://cam2.com/product/cam2-super-hd-10w-40-ck-4-heavy-duty-engine-oil/",
+	"https://cam2.com/product/cam2-super-hd-sae-10w-engine-oil/",
+	"https://cam2.com/product/cam2-magnum-turbo-d-25w-60-ch-4-sg-green-with-tackifier-engine-oil/",
+	"https://cam2.com/product/cam2-magnum-turbo-d-25w-50-ch-4-sg-engine-oil/",
+	"https://cam2.com/product/cam2-magnum-turbo-d-20w-50-ci-4-plus-sl-engine-oil/",
+	"https://cam2.com/product/cam2-magnum-turbo-d-20w-50-ch-4-sg-engine-oil/",
+	"https://cam2.com/product/cam2-magnum-turbo-d-15w-40-ci-4-plus-sl-engine-oil/",
+	"https://cam2.com/product/cam2-magnum-turbo-d-15w-40-ch-4-sg-engine-oil/",
+	"https://cam2.com/product/cam2-s-k-railroad-engine-oil-9-tbn-sae-40/",
+	"https://cam2.com/product/cam2-s-k-railroad-engine-oil-multigrade-9-tbn-20w-40/",
+	"https://cam2.com/product/cam2-protect75-5w-30-sp-gf-6a-high-mileage-engine-oil/",
+	"https://cam2.com/product/cam2-protect75-5w-20-sp-gf-6a-high-mileage-engine-oil/",
+	"https://cam2.com/product/cam2-protect75-10w-40-sp-high-mileage-engine-oil/",
+	"https://cam2.com/product/cam2-protect75-10w-30-sp-gf-6a-high-mileage-engine-oil/",
+	"https://cam2.com/product/cam2-magnum-special-5w-20-synthetic-blend-engine-oil/",
+	"https://cam2.com/product/ca2-magnum-special-5w-30-synthetic-blend-engine-oil/",
+	"https://cam2.com/product/cam2-magnum-special-20w-50-synthetic-blend-engine-oil/",
+	"https://cam2.com/product/cam2-magnum-special-10w-40-synthetic-blend-engine-oil/",
+	"https://cam2.com/product/cam2-magnum-special-10w-30-synthetic-blend-engine-oil/",
+	"https://cam2.com/product/cam2-nd-sae-50-motor-oil/",
+	"https://cam2.com/product/cam2-nd-sae-40-motor-oil/",
+	"https://cam2.com/product/cam2-nd-sae-30-motor-oil/",
+	"https://cam2.com/product/cam2-nd-sae-20-motor-oil/",
+	"https://cam2.com/product/cam2-nd-sae-10-motor-oil/",
+	"https://cam2.com/product/cam2-magnum-sae-50-motor-oil/",
+	"https://cam2.com/product/cam2-magnum-sae-40-motor-oil/",
+	"https://cam2.com/product/cam2-superpro-max-30w-sp-synthetic-blend-motor-oil/",
+	"https://cam2.com/product/cam2-superpro-max-40w-sp-synthetic-blend-motor-oil/",
+	"https://cam2.com/product/cam2-superpro-max-20w-50-sp-synthetic-blend-motor-oil/",
+	"https://cam2.com/product/cam2-superpro-max-10w-30-sp-synthetic-blend-motor-oil/",
+	"https://cam2.com/product/cam2-superpro-max-10w-40-sp-synthetic-blend-motor-oil/",
+	"https://cam2.com/product/cam2-superpro-max-5w-30-sp-synthetic-blend-motor-oil/",
+	"https://cam2.com/product/cam2-superpro-max-5w-20-sp-synthetic-blend-motor-oil/",
+	"https://cam2.com/product/cam2-blue-blood-nitro-70-synthetic-blend-racing-engine-oil/",
+	"https://cam2.com/product/cam2-blue-blood-20w-50-synthetic-blend-racing-engine-oil/",
+	"https://cam2.com/product/cam2-blue-blood-0w-30-full-synthetic-racing-engine-oil/",
+	"https://cam2.com/product/cam2-blue-blood-high-performance-break-in-engine-oil/",
+	"https://cam2.com/product/cam2-blue-blood-elite-0w-30-sp-gf-6a-full-synthetic-engine-oil/",
+	"https://cam2.com/product/cam2-blue-blood-elite-0w-40-sp-full-synthetic-engine-oil/",
+	"https://cam2.com/product/cam2-blue-blood-elite-10w-30-sp-gf-6a-full-synthetic-engine-oil/",
+	"https://cam2.com/product/cam2-blue-blood-elite-5w-30-sp-gf-6a-full-synthetic-engine-oil/",
+	"https://cam2.com/product/cam2-blue-blood-elite-5w-40-sp-full-synthetic-engine-oil/",
+	"https://cam2.com/product/cam2-blue-blood-elite-euro-5w-30-full-synthetic-engine-oil/",
+	"https://cam2.com/product/cam2-blue-blood-elite-euro-5w-40-full-synthetic-engine-oil/",
+	"https://cam2.com/product/cam2-synavex-0w-16-sp-gf-6b-full-synthetic-engine-oil/",
+	"https://cam2.com/product/cam2-synavex-0w-20-sp-gf-6a-full-synthetic-engine-oil/",
+	"https://cam2.com/product/cam2-synavex-0w-40-sp-full-synthetic-engine-oil/",
+	"https://cam2.com/product/cam2-synavex-10w-30-sp-gf-6a-full-synthetic-engine-oil/",
+	"https://cam2.com/product/cam2-synavex-5w-20-sp-gf-6a-full-synthetic-engine-oil/",
+	"https://cam2.com/product/cam2-synavex-5w-30-sp-gf-6a-full-synthetic-engine-oil/",
+	"https://cam2.com/product/cam2-synavex-5w-40-sp-full-synthetic-engine-oil/",
+	"https://cam2.com/product/magnum-special-multi-purpose-dexron-iii-mercon-atf/",
+	"https://cam2.com/product/cam2-synavex-hd-trans-full-synthetic-transmission-fluid/",
+	"https://cam2.com/product/cam2-synavex-full-synthetic-trans-fluid-sae-40/",
+	"https://cam2.com/product/cam2-full-synthetic-cvt-transmission-fluid/",
+	"https://cam2.com/product/cam2-synavex-full-synthetic-sae-50-transmission-fluid/",
+	"https://cam2.com/product/cam2-dexron-vi-multi-vehicle-full-synthetic-atf/",
+	"https://cam2.com/product/cam2-mpt-sae-50-torque-fluid-to-4/",
+	"https://cam2.com/product/cam2-mpt-sae-30-torque-fluid-to-4/",
+	"https://cam2.com/product/cam2-mpt-sae-10w-torque-fluid-to-4/",
+	"https://cam2.com/product/cam2-mercon-v-multi-purpose-atf/",
+	"https://cam2.com/product/cam2-type-f-atf/",
+	"https://cam2.com/product/cam2-atf-d-m-dexron-iiih-mercon/",
+	"https://cam2.com/product/cam2-atf-4/",
+	"https://cam2.com/product/cam2-multi-vehicle-synthetic-blend-atf/",
+	"https://cam2.com/product/cam2-type-a-atf/",
+	"https://cam2.com/product/cam2-85w-140-high-performance-ep-gear-oil-gl-5/",
+	"https://cam2.com/product/cam2-80w-90-ls-gear-oil-gl-5/",
+	"https://cam2.com/product/cam2-80w-90-high-performance-ep-gear-oil-gl-5/",
+	"https://cam2.com/product/cam2-industrial-ep-gear-oil-680/",
+	"https://cam2.com/product/cam2-industrial-ep-gear-oil-32/",
+	"https://cam2.com/product/cam2-industrial-ep-gear-oil-68/",
+	"https://cam2.com/product/cam2-industrial-ep-gear-oil-460/",
+	"https://cam2.com/product/cam2-industrial-ep-gear-oil-320/",
+	"https://cam2.com/product/cam2-industrial-ep-gear-oil-220/",
+	"https://cam2.com/product/cam2-industrial-ep-gear-oil-150/",
+	"https://cam2.com/product/cam2-industrial-ep-gear-oil-100/",
+	"https://cam2.com/product/cam2-ep-320-synthetic-industrial-gear-oil/",
+	"https://cam2.com/product/cam2-ep-220-synthetic-industrial-gear-oil/",
+	"https://cam2.com/product/cam2-ep-150-synthetic-industrial-gear-oil/",
+	"https://cam2.com/product/magnum-industrial-gear-oil-ep-220/",
+	"https://cam2.com/product/magnum-industrial-gear-oil-ep-460/",
+	"https://cam2.com/product/cam2-magnum-gear-oil-90-gl-1/",
+	"https://cam2.com/product/cam2-magnum-gear-oil-140-gl-1/",
+	"https://cam2.com/product/cam2-blue-blood-80w-90-ls-gear-oil-gl-5/",
+	"https://cam2.com/product/cam2-synavex-full-synthetic-80w-140-ls-gear-oil/",
+	"https://cam2.com/product/cam2-synavex-full-synthetic-75w-90-ls-gear-oil/",
+	"https://cam2.com/product/cam2-synavex-full-synthetic-75w-140-ls-gear-oil/",
+	"https://cam2.com/product/cam2-blue-blood-sae-75w-90-full-synthetic-ls-gear-oil-gl-5/",
+	"https://cam2.com/product/cam2-ashless-aw-68-hydraulic-oil/",
+	"https://cam2.com/product/cam2-ashless-aw-46-hydraulic-oil/",
+	"https://cam2.com/product/cam2-ashless-aw-32-hydraulic-oil/",
+	"https://cam2.com/product/cam2-promax-premium-aw-150-hydraulic-oil/",
+	"https://cam2.com/product/cam2-promax-premium-aw-10-low-temp-hydraulic-oil/",
+	"https://cam2.com/product/cam2-promax-premium-aw-15-low-temp-hydraulic-oil/",
+	"https://cam2.com/product/cam2-promax-premium-aw-22-hydraulic-oil/",
+	"https://cam2.com/product/cam2-promax-premium-aw-68-hydraulic-oil/",
+	"https://cam2.com/product/cam2-promax-premium-aw-46-hydraulic-oil/",
+	"https://cam2.com/product/cam2-promax-premium-aw-32-hydraulic-oil/",
+	"https://cam2.com/product/cam2-promax-premium-aw-100-hydraulic-oil/",
+	"https://cam2.com/product/cam2-promax-premium-all-season-5w-20-hydraulic-oil/",
+	"https://cam2.com/product/cam2-sae-20w-hydra-cat-1000-hydraulic-fluid/",
+	"https://cam2.com/product/cam2-sae-10w-hydra-cat-1000-hydraulic-fluid/",
+	"https://cam2.com/product/cam2-mining-hydraulic-68-fluid/",
+	"https://cam2.com/product/cam2-promax-aw-150-hydraulic-oil/",
+	"https://cam2.com/product/cam-2-promax-aw-100-hydraulic-oil/",
+	"https://cam2.com/product/cam2-promax-aw-15-hydraulic-oil/",
+	"https://cam2.com/product/cam2-promax-aw-22-hydraulic-fluid/",
+	"https://cam2.com/product/cam2-promax-aw-68-hydraulic-oil/",
+	"https://cam2.com/product/cam2-promax-aw-46-hydraulic-oil/",
+	"https://cam2.com/product/cam2-promax-aw-32-hydraulic-oil/",
+	"https://cam2.com/product/cam2-promax-tractor-hydraulic-fluid-j20-d/",
+	"https://cam2.com/product/cam2-promax-premium-universal-tractor-hydraulic-fluid/",
+	"https://cam2.com/product/cam2-ag-20-hydraulic-fluid/",
+	"https://cam2.com/product/cam2-synthetic-air-compressor-oil-68/",
+	"https://cam2.com/product/cam2-synthetic-air-compressor-oil-46/",
+	"https://cam2.com/product/cam2-synthetic-air-compressor-oil-32/",
+	"https://cam2.com/product/cam-2-heat-transfer-oil-iso-150/",
+	"https://cam2.com/product/cam-2-heat-transfer-oil-iso-46/",
+	"https://cam2.com/product/cam-2-heat-transfer-oil-iso-32/",
+	"https://cam2.com/product/cam2-iso-32-synthetic-heat-transfer-oil/",
+	"https://cam2.com/product/cam2-iso-46-synthetic-heat-transfer-oil/",
+	"https://cam2.com/product/cam2-iso-68-synthetic-heat-transfer-oil/",
+	"https://cam2.com/product/cam2-rock-drill-oil-320/",
+	"https://cam2.com/product/cam2-rock-drill-oil-220/",
+	"https://cam2.com/product/cam2-rock-drill-oil-100/",
+	"https://cam2.com/product/cam2-ultra-turbine-oil-68/",
+	"https://cam2.com/product/cam2-ultra-turbine-oil-46/",
+	"https://cam2.com/product/cam2-ultra-turbine-oil-320/",
+	"https://cam2.com/product/cam2-ultra-turbine-oil-32/",
+	"https://cam2.com/product/cam2-ultra-turbine-oil-220/",
+	"https://cam2.com/product/cam2-ultra-turbine-oil-22/",
+	"https://cam2.com/product/cam2-ultra-turbine-oil-150/",
+	"https://cam2.com/product/cam2-ultra-turbine-oil-100/",
+	"https://cam2.com/product/cam2-ultra-turbine-oil-460/",
+	"https://cam2.com/product/cam2-way-lube-460/",
+	"https://cam2.com/product/cam2-way-lube-150/",
+	"https://cam2.com/product/cam2-way-lube-100/",
+	"https://cam2.com/product/cam2-way-lube-68/",
+	"https://cam2.com/product/cam2-way-lube-32/",
+	"https://cam2.com/product/cam2-way-lube-220/",
+	"https://cam2.com/product/cam2-wireseal-2500/",
+	"https://cam2.com/product/cam2-wireseal-1500/",
+	"https://cam2.com/product/cam2-wireseal-680/",
+	"https://cam2.com/product/cam2-cherry-picker-oil-iso-32/",
+	"https://cam2.com/product/cam2-cherry-picker-oil-iso-22/",
+	"https://cam2.com/product/cam2-aviation-smoke-oil/",
+	"https://cam2.com/product/cam2-drip-oil/",
+	"https://cam2.com/product/cam2-concrete-form-oil/",
+	"https://cam2.com/product/cam2-saw-guide-oil-150/",
+	"https://cam2.com/product/cam2-saw-guide-oil-100/",
+	"https://cam2.com/product/cam2-ultraplex-ep1-grease-with-moly-graphite/",
+	"https://cam2.com/product/cam2-ultraplex-ep2-grease-lithium-complex-with-2-moly/",
+	"https://cam2.com/product/cam2-ultraplex-ep-2-grease-lithium-complex-with-3-moly/",
+	"https://cam2.com/product/cam2-ultraplex-ep-2-hi-temp-lithium-complex-grease/",
+	"https://cam2.com/product/cam2-hi-temp-red-lithium-complex-grease/",
+	"https://cam2.com/product/cam2-cotton-picker-spindle-grease/",
+	"https://cam2.com/product/cam2-multi-purpose-lithium-grease/",
+	"https://cam2.com/product/cam2-ultra580-ep-2-grease-calcium-sulfonate-with-5-moly/",
+	"https://cam2.com/product/cam2-ultra-580-ep2-grease/",
+	"https://cam2.com/product/cam2-ultra-580-ep1-grease/",
+	"https://cam2.com/product/cam2-transformer-oil/",
+	"https://cam2.com/product/cam2-60-pale-oil/",
+	"https://cam2.com/product/cam-2-hvi-325-base-oil/",
+	"https://cam2.com/product/cam-2-hvi-240-base-oil/",
+	"https://cam2.com/product/cam-2-hvi-150-base-oil/",
+	"https://cam2.com/product/cam-2-hvi-120-base-oil/",
+	"https://cam2.com/product/cam-2-hvi-70-base-oil/",
+	"https://cam2.com/product/cam2-conventional-pre-mix-50-50-antifreeze-coolant/",
+	"https://cam2.com/product/cam2-conventional-full-strength-antifreeze-coolant/",
+	"https://cam2.com/product/cam2-global-pre-mix-50-50-antifreeze/",
+	"https://cam2.com/product/cam2-global-full-strength-antifreeze/",
+	"https://cam2.com/product/cam2-superlife-pre-mix-50-50-antifreeze/",
+	"https://cam2.com/product/cam2-superlife-full-strength-antifreeze/",
+	"https://cam2.com/product/cam2-superlife-fleet-hd-truck-full-strength-antifreeze/",
+	"https://cam2.com/product/cam2-superlife-fleet-hd-truck-pre-mix-50-50-antifreeze/",
+	"https://cam2.com/product/cam2-magnum-radiator-additive/",
+	"https://cam2.com/product/cam2-non-flammable-flat-tire-sealant-hose/",
+	"https://cam2.com/product/cam2-non-flammable-flat-tire-sealant-cone/",
+	"https://cam2.com/product/cam2-penetrating-oil/",
+	"https://cam2.com/product/cam2-carburetor-cleaner/",
+	"https://cam2.com/product/cam2-de-icer/",
+	"https://cam2.com/product/cam2-starting-fluid/",
+	"https://cam2.com/product/cam2-super-hd-brake-parts-cleaner-non-flammable/",
+	"https://cam2.com/product/cam2-super-hd-brake-parts-cleaner-non-chlorinated/",
+	"https://cam2.com/product/cam2-loggers-pride-no-sling-premium-bar-chain-oil/",
+	"https://cam2.com/product/cam2-all-season-low-sling-bar-chain-oil/",
+	"https://cam2.com/product/cam2-blue-blood-def/",
+	"https://cam2.com/product/cam2-cleaner-degreaser/",
+	"https://cam2.com/product/cam2-140-high-flash-odorless-mineral-spirits/",
+	"https://cam2.com/product/cam2-oil-treatment/",
+	"https://cam2.com/product/cam2-motor-sealer/",
+	"https://cam2.com/product/cam2-power-steering-motor-sealer/",
+	"https://cam2.com/product/cam-2-diesel-conditioner-anti-gel/",
+	"https://cam2.com/product/cam2-octane-booster/",
+	"https://cam2.com/product/cam2-gas-treatment/",
+	"https://cam2.com/product/cam2-fuel-storage-stabilizer/",
+	"https://cam2.com/product/cam2-carb-fuel-injector-cleaner/",
+	"https://cam2.com/product/cam2-power-steering-fluid/",
+	"https://cam2.com/product/cam2-super-hd-brake-fluid-dot-3/",
+	"https://cam2.com/product/cam2-super-hd-brake-fluid-dot4/",
+	"https://cam2.com/product/cam2-windshield-washer-concentrate/",
+	"https://cam2.com/product/cam2-charcoal-lighter-fluid/",
+	"https://cam2.com/product/cam2-aluminum-brightener-fiberglass-cleaner/",
+	"https://cam2.com/product/cam2-cotton-picker-spindle-cleaner/",
+	"https://cam2.com/product/cam2-blue-blood-elite-4t-10w-40-synthetic-motorcycle-oil/",
+	"https://cam2.com/product/cam2-blue-blood-elite-4t-20w-50-synthetic-motorcycle-oil/",
+}
 
-	// Extract the hostname (e.g., "sub.example.com")
-	hostName := parsedUrl.Hostname()
+func main() {
+	options := crawlOptions{outputDir: "PDFs/", manifestPath: manifestPath}
+	crawlFlags := flag.NewFlagSet("crawl", flag.ExitOnError)
+	// The one flag of every command; the others are the crawl's
+	crawlFlags.StringVar(&userAgent, "user-agent", userAgent, "User-Agent of every request; keep a URL or e-mail address in it so the site can reach you")
+	crawlFlags.BoolVar(&options.full, "full", false, "fetch every page, even those whose sitemap lastmod is unchanged")
+	crawlFlags.BoolVar(&options.offline, "offline", false, "rerun the extraction on the page cache without using the network")
+	crawlFlags.StringVar(&options.warcDir, "warc", "", "archive the crawl as a WARC file in this directory, e.g. "+warcDir+"; every page and PDF is then fetched in full")
+	crawlFlags.BoolVar(&options.warcIncremental, "warc-incremental", false, "with -warc, keep the sitemap shortcut and conditional requests; a 304 in the archive stands for the response an earlier full archive holds")
+	crawlFlags.StringVar(&options.harPath, "har", "", "write the timings of every request to this HAR file")
+	crawlFlags.Usage = func() {
+		printUsage()
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "flags (before the command; all but -user-agent only for the crawl):")
+		crawlFlags.PrintDefaults()
+	}
+	crawlFlags.Parse(os.Args[1:])
+	// Run a subcommand instead of the crawler when one is given
+	if crawlFlags.NArg() > 0 {
+		crawlFlags.Visit(func(f *flag.Flag) {
+			if f.Name != "user-agent" {
+				fmt.Fprintf(os.Stderr, "-%s only applies to the crawl, not to %s\n", f.Name, crawlFlags.Arg(0))
+				os.Exit(2)
+			}
+		})
+		runCommand(crawlFlags.Arg(0), crawlFlags.Args()[1:])
+		return
+	}
+	crawl(options)
+}
 
-	// Split the hostname into parts separated by "."
-	// For example: "sub.example.com" -> ["sub", "example", "com"]
-	parts := strings.Split(hostName, ".")
+// crawlOptions are the ways a crawl can run
+type crawlOptions struct {
+	full    bool   // Fetch pages whose sitemap lastmod is unchanged too
+	offline bool   // Use the page cache only
+	replay  bool   // Requests are answered from a WARC archive by httpTransport
+	warcDir string // Where to archive the requests, "" for nowhere
+	harPath string // Where to write request timings, "" for nowhere
 
-	// If there are at least 2 parts, the second last part is usually the domain
-	// Example: "sub.example.com" -> "example"
-	//          "blog.my-site.co.uk" -> "my-site"
-	if len(parts) >= 2 {
-		return parts[len(parts)-2]
-	}
+	warcIncremental bool // Archive only what an incremental crawl fetches
 
-	// If splitting fails or domain structure is unusual, return the hostname itself
-	return hostName
+	outputDir    string // Where the PDFs go
+	manifestPath string // Where the page to document mapping is kept
 }
 
-func main() {
-	outputDir := "PDFs/" // Directory to store downloaded PDFs
+// crawl downloads the data sheets linked from the product pages into PDFs/
+// and records what changed
+func crawl(options crawlOptions) {
+	// Time and archive every request of a live crawl, and keep to robots.txt
+	if !options.offline && !options.replay {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DisableCompression = true // Archive the bytes as sent
+		httpTransport = transport
+		if options.harPath != "" {
+			timings := newHARLog()
+			httpTransport = &harTransport{next: httpTransport, log: timings}
+			defer timings.save(options.harPath)
+		}
+		if options.warcDir != "" {
+			archive, err := createWARC(options.warcDir)
+			if err != nil {
+				log.Println("Not archiving the crawl:", err)
+			} else {
+				httpTransport = &warcTransport{next: httpTransport, writer: archive, revalidate: options.warcIncremental}
+				defer archive.close()
+				// The archive should hold every page, not just the changed
+				// ones, unless it is one of a series after a full one
+				if !options.warcIncremental {
+					options.full = true
+				}
+			}
+		}
+		// Ask robots.txt first, so that skipped URLs are neither archived
+		// nor timed
+		defer useRobots().report()
+	}
+
+	outputDir := options.outputDir // Directory to store downloaded PDFs
 
 	if !directoryExists(outputDir) { // Check if directory exists
 		createDirectory(outputDir, 0o755) // Create directory with read-write-execute permissions
@@ -319,269 +686,133 @@ func main() {
 	// The remote domain name.
 	remoteDomainName := "https://cam2.com"
 
-	// The location to the local.
-	localFile := extractBaseDomain(remoteDomainName) + ".html"
-	// Check if the local file exists.
-	if fileExists(localFile) {
-		removeFile(localFile)
-	}
-	// The location to the remote url.
-	remoteURL := []string{
-		"https://cam2.com/data-sheets/",
-		"https://cam2.com/product/cam2-premium-synthetic-blend-tc-w3-2-cycle-outboard-oil/",
-		"https://cam2.com/product/cam2-magnum-economy-2-cycle-engine-oil/",
-		"https://cam2.com/product/cam2-2-cycle-engine-oil-air-cooled/",
-		"https://cam2.com/product/cam2-blue-blood-12-2-6-ounce-2-cycle-synthetic-engine-oil/",
-		"https://cam2.com/product/cam2-blue-blood-marine-2-cycle-oil-tc-w3/",
-		"https://cam2.com/product/cam2-nitrile-gloves-8mil-black-medium/",
-		"https://cam2.com/product/cam2-promax-r-o-hydraulic-oil/",
-		"https://cam2.com/product/cam2-full-synthetic-global-low-vis-atf/",
-		"https://cam2.com/product/cam2-synavex-dexos1-gen-3-sae-5w-30-sp-gf-6a-full-synthetic-motor-oil/",
-		"https://cam2.com/product/cam2-synavex-dexos1-gen-3-sae-0w-20-sp-gf-6a-full-synthetic-motor-oil/",
-		"https://cam2.com/product/cam2-magnum-gear-oil-sae-80w-90-gl-5/",
-		"https://cam2.com/product/cam2-magnum-gear-oil-sae-75w-140-gl-5/",
-		"https://cam2.com/product/cam2-magnum-gear-oil-sae-75w-90-gl-5/",
-		"https://cam2.com/product/cam2-k-1-kerosene/",
-		"https://cam2.com/product/hand-sanitizer/",
-		"https://cam2.com/product/cam2-blue-blood-elite-hd-5w-40-ck-4-w-detox-technology/",
-		"https://cam2.com/product/cam2-ngeo-sae-15w-40-ces-20074-engine-oil/",
-		"https://cam2.com/product/cam2-geo-sae-30-ashless-engine-oil/",
-		"https://cam2.com/product/cam2-ngeo-low-ash-engine-oil-sae-30/",
-		"https://cam2.com/product/cam2-ngeo-low-ash-engine-oil-sae-40/",
-		"https://cam2.com/product/cam2-super-hd-15w-40-performance-driven-ck-4-sn-synthetic-blend-engine-oil/",
-		"https://cam2.com/product/cam-2-super-hd-10w-30-performance-driven-ck-4-synthetic-blend-engine-oil/",
-		"https://cam2.com/product/cam2-super-hd-sae-50-api-cf-cf-2-sl-engine-oil/",
-		"https://cam2.com/product/cam2-super-hd-sae-40-api-cf-cf-2-sl-engine-oil/",
-		"https://cam2.com/product/cam2-super-hd-sae-30-api-cf-cf-2-sl-engine-oil/",
-		"https://cam2.com/product/cam2-super-hd-10w-40-ck-4-heavy-duty-engine-oil/",
-		"https://cam2.com/product/cam2-super-hd-sae-10w-engine-oil/",
-		"https://cam2.com/product/cam2-magnum-turbo-d-25w-60-ch-4-sg-green-with-tackifier-engine-oil/",
-		"https://cam2.com/product/cam2-magnum-turbo-d-25w-50-ch-4-sg-engine-oil/",
-		"https://cam2.com/product/cam2-magnum-turbo-d-20w-50-ci-4-plus-sl-engine-oil/",
-		"https://cam2.com/product/cam2-magnum-turbo-d-20w-50-ch-4-sg-engine-oil/",
-		"https://cam2.com/product/cam2-magnum-turbo-d-15w-40-ci-4-plus-sl-engine-oil/",
-		"https://cam2.com/product/cam2-magnum-turbo-d-15w-40-ch-4-sg-engine-oil/",
-		"https://cam2.com/product/cam2-s-k-railroad-engine-oil-9-tbn-sae-40/",
-		"https://cam2.com/product/cam2-s-k-railroad-engine-oil-multigrade-9-tbn-20w-40/",
-		"https://cam2.com/product/cam2-protect75-5w-30-sp-gf-6a-high-mileage-engine-oil/",
-		"https://cam2.com/product/cam2-protect75-5w-20-sp-gf-6a-high-mileage-engine-oil/",
-		"https://cam2.com/product/cam2-protect75-10w-40-sp-high-mileage-engine-oil/",
-		"https://cam2.com/product/cam2-protect75-10w-30-sp-gf-6a-high-mileage-engine-oil/",
-		"https://cam2.com/product/cam2-magnum-special-5w-20-synthetic-blend-engine-oil/",
-		"https://cam2.com/product/ca2-magnum-special-5w-30-synthetic-blend-engine-oil/",
-		"https://cam2.com/product/cam2-magnum-special-20w-50-synthetic-blend-engine-oil/",
-		"https://cam2.com/product/cam2-magnum-special-10w-40-synthetic-blend-engine-oil/",
-		"https://cam2.com/product/cam2-magnum-special-10w-30-synthetic-blend-engine-oil/",
-		"https://cam2.com/product/cam2-nd-sae-50-motor-oil/",
-		"https://cam2.com/product/cam2-nd-sae-40-motor-oil/",
-		"https://cam2.com/product/cam2-nd-sae-30-motor-oil/",
-		"https://cam2.com/product/cam2-nd-sae-20-motor-oil/",
-		"https://cam2.com/product/cam2-nd-sae-10-motor-oil/",
-		"https://cam2.com/product/cam2-magnum-sae-50-motor-oil/",
-		"https://cam2.com/product/cam2-magnum-sae-40-motor-oil/",
-		"https://cam2.com/product/cam2-superpro-max-30w-sp-synthetic-blend-motor-oil/",
-		"https://cam2.com/product/cam2-superpro-max-40w-sp-synthetic-blend-motor-oil/",
-		"https://cam2.com/product/cam2-superpro-max-20w-50-sp-synthetic-blend-motor-oil/",
-		"https://cam2.com/product/cam2-superpro-max-10w-30-sp-synthetic-blend-motor-oil/",
-		"https://cam2.com/product/cam2-superpro-max-10w-40-sp-synthetic-blend-motor-oil/",
-		"https://cam2.com/product/cam2-superpro-max-5w-30-sp-synthetic-blend-motor-oil/",
-		"https://cam2.com/product/cam2-superpro-max-5w-20-sp-synthetic-blend-motor-oil/",
-		"https://cam2.com/product/cam2-blue-blood-nitro-70-synthetic-blend-racing-engine-oil/",
-		"https://cam2.com/product/cam2-blue-blood-20w-50-synthetic-blend-racing-engine-oil/",
-		"https://cam2.com/product/cam2-blue-blood-0w-30-full-synthetic-racing-engine-oil/",
-		"https://cam2.com/product/cam2-blue-blood-high-performance-break-in-engine-oil/",
-		"https://cam2.com/product/cam2-blue-blood-elite-0w-30-sp-gf-6a-full-synthetic-engine-oil/",
-		"https://cam2.com/product/cam2-blue-blood-elite-0w-40-sp-full-synthetic-engine-oil/",
-		"https://cam2.com/product/cam2-blue-blood-elite-10w-30-sp-gf-6a-full-synthetic-engine-oil/",
-		"https://cam2.com/product/cam2-blue-blood-elite-5w-30-sp-gf-6a-full-synthetic-engine-oil/",
-		"https://cam2.com/product/cam2-blue-blood-elite-5w-40-sp-full-synthetic-engine-oil/",
-		"https://cam2.com/product/cam2-blue-blood-elite-euro-5w-30-full-synthetic-engine-oil/",
-		"https://cam2.com/product/cam2-blue-blood-elite-euro-5w-40-full-synthetic-engine-oil/",
-		"https://cam2.com/product/cam2-synavex-0w-16-sp-gf-6b-full-synthetic-engine-oil/",
-		"https://cam2.com/product/cam2-synavex-0w-20-sp-gf-6a-full-synthetic-engine-oil/",
-		"https://cam2.com/product/cam2-synavex-0w-40-sp-full-synthetic-engine-oil/",
-		"https://cam2.com/product/cam2-synavex-10w-30-sp-gf-6a-full-synthetic-engine-oil/",
-		"https://cam2.com/product/cam2-synavex-5w-20-sp-gf-6a-full-synthetic-engine-oil/",
-		"https://cam2.com/product/cam2-synavex-5w-30-sp-gf-6a-full-synthetic-engine-oil/",
-		"https://cam2.com/product/cam2-synavex-5w-40-sp-full-synthetic-engine-oil/",
-		"https://cam2.com/product/magnum-special-multi-purpose-dexron-iii-mercon-atf/",
-		"https://cam2.com/product/cam2-synavex-hd-trans-full-synthetic-transmission-fluid/",
-		"https://cam2.com/product/cam2-synavex-full-synthetic-trans-fluid-sae-40/",
-		"https://cam2.com/product/cam2-full-synthetic-cvt-transmission-fluid/",
-		"https://cam2.com/product/cam2-synavex-full-synthetic-sae-50-transmission-fluid/",
-		"https://cam2.com/product/cam2-dexron-vi-multi-vehicle-full-synthetic-atf/",
-		"https://cam2.com/product/cam2-mpt-sae-50-torque-fluid-to-4/",
-		"https://cam2.com/product/cam2-mpt-sae-30-torque-fluid-to-4/",
-		"https://cam2.com/product/cam2-mpt-sae-10w-torque-fluid-to-4/",
-		"https://cam2.com/product/cam2-mercon-v-multi-purpose-atf/",
-		"https://cam2.com/product/cam2-type-f-atf/",
-		"https://cam2.com/product/cam2-atf-d-m-dexron-iiih-mercon/",
-		"https://cam2.com/product/cam2-atf-4/",
-		"https://cam2.com/product/cam2-multi-vehicle-synthetic-blend-atf/",
-		"https://cam2.com/product/cam2-type-a-atf/",
-		"https://cam2.com/product/cam2-85w-140-high-performance-ep-gear-oil-gl-5/",
-		"https://cam2.com/product/cam2-80w-90-ls-gear-oil-gl-5/",
-		"https://cam2.com/product/cam2-80w-90-high-performance-ep-gear-oil-gl-5/",
-		"https://cam2.com/product/cam2-industrial-ep-gear-oil-680/",
-		"https://cam2.com/product/cam2-industrial-ep-gear-oil-32/",
-		"https://cam2.com/product/cam2-industrial-ep-gear-oil-68/",
-		"https://cam2.com/product/cam2-industrial-ep-gear-oil-460/",
-		"https://cam2.com/product/cam2-industrial-ep-gear-oil-320/",
-		"https://cam2.com/product/cam2-industrial-ep-gear-oil-220/",
-		"https://cam2.com/product/cam2-industrial-ep-gear-oil-150/",
-		"https://cam2.com/product/cam2-industrial-ep-gear-oil-100/",
-		"https://cam2.com/product/cam2-ep-320-synthetic-industrial-gear-oil/",
-		"https://cam2.com/product/cam2-ep-220-synthetic-industrial-gear-oil/",
-		"https://cam2.com/product/cam2-ep-150-synthetic-industrial-gear-oil/",
-		"https://cam2.com/product/magnum-industrial-gear-oil-ep-220/",
-		"https://cam2.com/product/magnum-industrial-gear-oil-ep-460/",
-		"https://cam2.com/product/cam2-magnum-gear-oil-90-gl-1/",
-		"https://cam2.com/product/cam2-magnum-gear-oil-140-gl-1/",
-		"https://cam2.com/product/cam2-blue-blood-80w-90-ls-gear-oil-gl-5/",
-		"https://cam2.com/product/cam2-synavex-full-synthetic-80w-140-ls-gear-oil/",
-		"https://cam2.com/product/cam2-synavex-full-synthetic-75w-90-ls-gear-oil/",
-		"https://cam2.com/product/cam2-synavex-full-synthetic-75w-140-ls-gear-oil/",
-		"https://cam2.com/product/cam2-blue-blood-sae-75w-90-full-synthetic-ls-gear-oil-gl-5/",
-		"https://cam2.com/product/cam2-ashless-aw-68-hydraulic-oil/",
-		"https://cam2.com/product/cam2-ashless-aw-46-hydraulic-oil/",
-		"https://cam2.com/product/cam2-ashless-aw-32-hydraulic-oil/",
-		"https://cam2.com/product/cam2-promax-premium-aw-150-hydraulic-oil/",
-		"https://cam2.com/product/cam2-promax-premium-aw-10-low-temp-hydraulic-oil/",
-		"https://cam2.com/product/cam2-promax-premium-aw-15-low-temp-hydraulic-oil/",
-		"https://cam2.com/product/cam2-promax-premium-aw-22-hydraulic-oil/",
-		"https://cam2.com/product/cam2-promax-premium-aw-68-hydraulic-oil/",
-		"https://cam2.com/product/cam2-promax-premium-aw-46-hydraulic-oil/",
-		"https://cam2.com/product/cam2-promax-premium-aw-32-hydraulic-oil/",
-		"https://cam2.com/product/cam2-promax-premium-aw-100-hydraulic-oil/",
-		"https://cam2.com/product/cam2-promax-premium-all-season-5w-20-hydraulic-oil/",
-		"https://cam2.com/product/cam2-sae-20w-hydra-cat-1000-hydraulic-fluid/",
-		"https://cam2.com/product/cam2-sae-10w-hydra-cat-1000-hydraulic-fluid/",
-		"https://cam2.com/product/cam2-mining-hydraulic-68-fluid/",
-		"https://cam2.com/product/cam2-promax-aw-150-hydraulic-oil/",
-		"https://cam2.com/product/cam-2-promax-aw-100-hydraulic-oil/",
-		"https://cam2.com/product/cam2-promax-aw-15-hydraulic-oil/",
-		"https://cam2.com/product/cam2-promax-aw-22-hydraulic-fluid/",
-		"https://cam2.com/product/cam2-promax-aw-68-hydraulic-oil/",
-		"https://cam2.com/product/cam2-promax-aw-46-hydraulic-oil/",
-		"https://cam2.com/product/cam2-promax-aw-32-hydraulic-oil/",
-		"https://cam2.com/product/cam2-promax-tractor-hydraulic-fluid-j20-d/",
-		"https://cam2.com/product/cam2-promax-premium-universal-tractor-hydraulic-fluid/",
-		"https://cam2.com/product/cam2-ag-20-hydraulic-fluid/",
-		"https://cam2.com/product/cam2-synthetic-air-compressor-oil-68/",
-		"https://cam2.com/product/cam2-synthetic-air-compressor-oil-46/",
-		"https://cam2.com/product/cam2-synthetic-air-compressor-oil-32/",
-		"https://cam2.com/product/cam-2-heat-transfer-oil-iso-150/",
-		"https://cam2.com/product/cam-2-heat-transfer-oil-iso-46/",
-		"https://cam2.com/product/cam-2-heat-transfer-oil-iso-32/",
-		"https://cam2.com/product/cam2-iso-32-synthetic-heat-transfer-oil/",
-		"https://cam2.com/product/cam2-iso-46-synthetic-heat-transfer-oil/",
-		"https://cam2.com/product/cam2-iso-68-synthetic-heat-transfer-oil/",
-		"https://cam2.com/product/cam2-rock-drill-oil-320/",
-		"https://cam2.com/product/cam2-rock-drill-oil-220/",
-		"https://cam2.com/product/cam2-rock-drill-oil-100/",
-		"https://cam2.com/product/cam2-ultra-turbine-oil-68/",
-		"https://cam2.com/product/cam2-ultra-turbine-oil-46/",
-		"https://cam2.com/product/cam2-ultra-turbine-oil-320/",
-		"https://cam2.com/product/cam2-ultra-turbine-oil-32/",
-		"https://cam2.com/product/cam2-ultra-turbine-oil-220/",
-		"https://cam2.com/product/cam2-ultra-turbine-oil-22/",
-		"https://cam2.com/product/cam2-ultra-turbine-oil-150/",
-		"https://cam2.com/product/cam2-ultra-turbine-oil-100/",
-		"https://cam2.com/product/cam2-ultra-turbine-oil-460/",
-		"https://cam2.com/product/cam2-way-lube-460/",
-		"https://cam2.com/product/cam2-way-lube-150/",
-		"https://cam2.com/product/cam2-way-lube-100/",
-		"https://cam2.com/product/cam2-way-lube-68/",
-		"https://cam2.com/product/cam2-way-lube-32/",
-		"https://cam2.com/product/cam2-way-lube-220/",
-		"https://cam2.com/product/cam2-wireseal-2500/",
-		"https://cam2.com/product/cam2-wireseal-1500/",
-		"https://cam2.com/product/cam2-wireseal-680/",
-		"https://cam2.com/product/cam2-cherry-picker-oil-iso-32/",
-		"https://cam2.com/product/cam2-cherry-picker-oil-iso-22/",
-		"https://cam2.com/product/cam2-aviation-smoke-oil/",
-		"https://cam2.com/product/cam2-drip-oil/",
-		"https://cam2.com/product/cam2-concrete-form-oil/",
-		"https://cam2.com/product/cam2-saw-guide-oil-150/",
-		"https://cam2.com/product/cam2-saw-guide-oil-100/",
-		"https://cam2.com/product/cam2-ultraplex-ep1-grease-with-moly-graphite/",
-		"https://cam2.com/product/cam2-ultraplex-ep2-grease-lithium-complex-with-2-moly/",
-		"https://cam2.com/product/cam2-ultraplex-ep-2-grease-lithium-complex-with-3-moly/",
-		"https://cam2.com/product/cam2-ultraplex-ep-2-hi-temp-lithium-complex-grease/",
-		"https://cam2.com/product/cam2-hi-temp-red-lithium-complex-grease/",
-		"https://cam2.com/product/cam2-cotton-picker-spindle-grease/",
-		"https://cam2.com/product/cam2-multi-purpose-lithium-grease/",
-		"https://cam2.com/product/cam2-ultra580-ep-2-grease-calcium-sulfonate-with-5-moly/",
-		"https://cam2.com/product/cam2-ultra-580-ep2-grease/",
-		"https://cam2.com/product/cam2-ultra-580-ep1-grease/",
-		"https://cam2.com/product/cam2-transformer-oil/",
-		"https://cam2.com/product/cam2-60-pale-oil/",
-		"https://cam2.com/product/cam-2-hvi-325-base-oil/",
-		"https://cam2.com/product/cam-2-hvi-240-base-oil/",
-		"https://cam2.com/product/cam-2-hvi-150-base-oil/",
-		"https://cam2.com/product/cam-2-hvi-120-base-oil/",
-		"https://cam2.com/product/cam-2-hvi-70-base-oil/",
-		"https://cam2.com/product/cam2-conventional-pre-mix-50-50-antifreeze-coolant/",
-		"https://cam2.com/product/cam2-conventional-full-strength-antifreeze-coolant/",
-		"https://cam2.com/product/cam2-global-pre-mix-50-50-antifreeze/",
-		"https://cam2.com/product/cam2-global-full-strength-antifreeze/",
-		"https://cam2.com/product/cam2-superlife-pre-mix-50-50-antifreeze/",
-		"https://cam2.com/product/cam2-superlife-full-strength-antifreeze/",
-		"https://cam2.com/product/cam2-superlife-fleet-hd-truck-full-strength-antifreeze/",
-		"https://cam2.com/product/cam2-superlife-fleet-hd-truck-pre-mix-50-50-antifreeze/",
-		"https://cam2.com/product/cam2-magnum-radiator-additive/",
-		"https://cam2.com/product/cam2-non-flammable-flat-tire-sealant-hose/",
-		"https://cam2.com/product/cam2-non-flammable-flat-tire-sealant-cone/",
-		"https://cam2.com/product/cam2-penetrating-oil/",
-		"https://cam2.com/product/cam2-carburetor-cleaner/",
-		"https://cam2.com/product/cam2-de-icer/",
-		"https://cam2.com/product/cam2-starting-fluid/",
-		"https://cam2.com/product/cam2-super-hd-brake-parts-cleaner-non-flammable/",
-		"https://cam2.com/product/cam2-super-hd-brake-parts-cleaner-non-chlorinated/",
-		"https://cam2.com/product/cam2-loggers-pride-no-sling-premium-bar-chain-oil/",
-		"https://cam2.com/product/cam2-all-season-low-sling-bar-chain-oil/",
-		"https://cam2.com/product/cam2-blue-blood-def/",
-		"https://cam2.com/product/cam2-cleaner-degreaser/",
-		"https://cam2.com/product/cam2-140-high-flash-odorless-mineral-spirits/",
-		"https://cam2.com/product/cam2-oil-treatment/",
-		"https://cam2.com/product/cam2-motor-sealer/",
-		"https://cam2.com/product/cam2-power-steering-motor-sealer/",
-		"https://cam2.com/product/cam-2-diesel-conditioner-anti-gel/",
-		"https://cam2.com/product/cam2-octane-booster/",
-		"https://cam2.com/product/cam2-gas-treatment/",
-		"https://cam2.com/product/cam2-fuel-storage-stabilizer/",
-		"https://cam2.com/product/cam2-carb-fuel-injector-cleaner/",
-		"https://cam2.com/product/cam2-power-steering-fluid/",
-		"https://cam2.com/product/cam2-super-hd-brake-fluid-dot-3/",
-		"https://cam2.com/product/cam2-super-hd-brake-fluid-dot4/",
-		"https://cam2.com/product/cam2-windshield-washer-concentrate/",
-		"https://cam2.com/product/cam2-charcoal-lighter-fluid/",
-		"https://cam2.com/product/cam2-aluminum-brightener-fiberglass-cleaner/",
-		"https://cam2.com/product/cam2-cotton-picker-spindle-cleaner/",
-		"https://cam2.com/product/cam2-blue-blood-elite-4t-10w-40-synthetic-motorcycle-oil/",
-		"https://cam2.com/product/cam2-blue-blood-elite-4t-20w-50-synthetic-motorcycle-oil/",
-	}
-	// Loop over the urls and save content to file.
-	for _, url := range remoteURL {
-		// Call fetchPage to download the content of that page
-		pageContent := getDataFromURL(url)
-		// Append it and save it to the file.
-		appendAndWriteToFile(localFile, pageContent)
-	}
-	// Read the file content
-	fileContent := readAFileAsString(localFile)
-	// Extract the URLs from the given content.
-	extractedPDFURLs := extractPDFUrls(fileContent)
+	// Load the record of which page links to which document, and keep a copy
+	// to see what changed
+	manifest := loadManifest(options.manifestPath)
+	previousManifest := loadManifest(options.manifestPath)
+	// Add the product pages the shop lists but the seeds miss; offline or
+	// when the Store API is down, the ones it listed last time
+	storeProducts := manifest.knownStoreProducts()
+	if !options.offline {
+		if listed, ok := discoverStoreProducts(remoteDomainName); ok {
+			storeProducts = listed
+		}
+	}
+	pages := append(remoteURL[:len(remoteURL):len(remoteURL)], newStorePages(storeProducts, remoteURL)...)
+	// Pages whose sitemap lastmod has not changed since the last successful
+	// crawl are read from the page cache, unless a full crawl was asked for
+	crawlState := loadCrawlState(crawlStatePath)
+	var lastMods map[string]string
+	if !options.offline && !options.replay {
+		lastMods = readSitemaps(remoteDomainName)
+	}
+	// Loop over the urls and keep the content of each page.
+	var pageContents []string
+	today := time.Now().Format("2006-01-02")
+	for _, url := range pages {
+		var pageContent string
+		status := 0
+		if crawlState.skip(url, lastMods, options.full) {
+			log.Println("Unchanged since the last crawl", url)
+			pageContent, status = fetchPage(url, true)
+		}
+		switch {
+		case options.replay:
+			pageContent, status = fetchURL(url)
+		case status == 0:
+			// Call fetchPage to download the content of that page
+			pageContent, status = fetchPage(url, options.offline)
+		}
+		// A product page that is gone means the product was discontinued
+		if pageGone(status) {
+			manifest.withdrawProduct(url, today)
+			continue
+		}
+		if status >= 200 && status <= 299 {
+			crawlState.fetched(url, lastMods)
+		}
+		pageContents = append(pageContents, pageContent)
+		// Remember which PDFs this page links to
+		manifest.recordProductPage(url, pageContent, remoteDomainName)
+	}
+	// The shop's names, SKUs and categories replace the scraped ones
+	manifest.recordStoreProducts(storeProducts)
+	// List the PDFs in the media library, including ones no page links
+	var mediaItems []MediaItem
+	mediaComplete := false
+	if !options.offline {
+		mediaItems, mediaComplete = discoverMediaPDFs(remoteDomainName)
+	}
+	manifest.recordMedia(mediaItems, mediaComplete)
+	// Extract the URLs from the pages and add the media library's.
+	extractedPDFURLs := append(extractPDFUrls(strings.Join(pageContents, "\n")), mediaURLs(mediaItems)...)
+	// Retry the downloads that failed before
+	extractedPDFURLs = append(extractedPDFURLs, manifest.missingDocumentURLs(outputDir)...)
 	// Remove duplicates from the slice.
 	extractedPDFURLs = removeDuplicatesFromSlice(extractedPDFURLs)
 	// Loop through all extracted PDF URLs
+	if options.offline {
+		log.Printf("Offline: not downloading %d PDFs", len(extractedPDFURLs))
+		extractedPDFURLs = nil
+	}
+	// One URL per file, the manifest's, so that a document linked from two
+	// upload folders is not fetched twice and replaced back and forth
+	downloads := map[string]string{}
+	var downloadOrder []string
 	for _, urls := range extractedPDFURLs {
 		if !hasDomain(urls) {
 			urls = remoteDomainName + urls
 
 		}
-		if isUrlValid(urls) { // Check if the final URL is valid
-			downloadPDF(urls, outputDir) // Download the PDF
+		if !isUrlValid(urls) { // Check if the final URL is valid
+			continue
 		}
-	}
+		filename := strings.ToLower(urlToFilename(urls))
+		if _, found := downloads[filename]; found {
+			continue
+		}
+		if document := manifest.Documents[filename]; document != nil && document.SourceURL != "" {
+			urls = document.SourceURL
+		}
+		downloads[filename] = urls
+		downloadOrder = append(downloadOrder, filename)
+	}
+	for _, filename := range downloadOrder {
+		urls := downloads[filename]
+		// Download the PDF, or revalidate the copy, noting a server that
+		// mislabels it
+		_, declared, detected := downloadPDF(urls, outputDir, manifest.Documents[filename])
+		manifest.recordContentType(urls, declared, detected)
+	}
+	// Date the documents from their upload folders and PDF metadata
+	manifest.addDocumentDates(outputDir)
+	// Work out what was added, revised and withdrawn, moving withdrawn files
+	// out of the mirror
+	changes := detectChanges(previousManifest, manifest)
+	addRevisionDates(changes, outputDir)
+	manifest.applyWithdrawals(changes, outputDir, today)
+	// Save the page to document mapping next to the PDFs
+	manifest.save(options.manifestPath)
+	// Index the PDFs that were added or replaced for the search command; a
+	// replay leaves the index of the mirror alone
+	if !options.replay {
+		updateSearchIndex(outputDir)
+	}
+	// An offline run or a replay only repeats the extraction, so it is not
+	// a crawl and tells nobody about its changes
+	if options.offline || options.replay {
+		log.Printf("Offline run: %d changes neither sent nor added to the feed", len(changes))
+		return
+	}
+	crawlState.finish()
+	crawlState.save(crawlStatePath)
+	// Without the manifest of an earlier run every document looks added, so
+	// the first run only records the baseline
+	if len(previousManifest.Documents) == 0 {
+		log.Printf("No earlier manifest: recorded %d documents as the baseline without notifications or feed entries", len(manifest.Documents))
+		return
+	}
+	// Tell the watchlists about the changes
+	notifyChanges(loadNotificationConfig(notificationsConfigPath), changes)
+	writeFeed(changes)
 }
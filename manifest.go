@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"html"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// manifestPath is where the crawler records which product page links to
+// which document
+const manifestPath = "manifest.json"
+
+// Manifest ties the files in PDFs/ back to the product pages that link them
+type Manifest struct {
+	Products  map[string]*Product  `json:"products"`  // Keyed by product slug
+	Documents map[string]*Document `json:"documents"` // Keyed by local filename
+}
+
+// Document is one downloaded PDF
+type Document struct {
+	Filename   string   `json:"filename"`
+	SourceURL  string   `json:"source_url"`
+	Kind       string   `json:"kind"` // "sds", "tds" or "other"
+	PartNumber string   `json:"part_number,omitempty"`
+	Products   []string `json:"products,omitempty"`  // Slugs of the pages linking it
+	Withdrawn  string   `json:"withdrawn,omitempty"` // Date no page linked it any more; the file is in withdrawn/
+	Title      string   `json:"title,omitempty"`     // Title in the WordPress media library
+	Uploaded   string   `json:"uploaded,omitempty"`  // Upload date in the WordPress media library
+	// Where else it is published: pages other than product pages, such as
+	// /data-sheets/, and the WordPress media library
+	Pages          []string `json:"pages,omitempty"`
+	InMediaLibrary bool     `json:"media_library,omitempty"`
+	// The copy in PDFs/: where it was downloaded from, its digest and the
+	// validators to revalidate it with
+	FetchedURL   string   `json:"fetched_url,omitempty"`
+	SHA256       string   `json:"sha256,omitempty"`
+	ETag         string   `json:"etag,omitempty"`
+	LastModified string   `json:"last_modified,omitempty"`
+	Superseded   []string `json:"superseded,omitempty"` // Earlier copies replaced in place, kept in withdrawn/
+	// Content-Type the server sent and the type the bytes turned out to be,
+	// recorded when the two disagree
+	DeclaredType string `json:"declared_type,omitempty"`
+	DetectedType string `json:"detected_type,omitempty"`
+
+	// Filled in by addDocumentDates
+	UploadMonth  string   `json:"upload_month,omitempty"` // From the /wp-content/uploads/YYYY/MM/ path
+	PDFInfo      *PDFInfo `json:"pdf_info,omitempty"`
+	RevisionDate string   `json:"revision_date,omitempty"` // Of safety data sheets, YYYY-MM-DD
+}
+
+// loadManifest reads the manifest, returning an empty one if it is missing
+func loadManifest(path string) *Manifest {
+	manifest := &Manifest{Products: map[string]*Product{}, Documents: map[string]*Document{}}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Println(err)
+		}
+		return manifest
+	}
+	if err := json.Unmarshal(content, manifest); err != nil {
+		log.Printf("Failed to parse %s: %v", path, err)
+	}
+	if manifest.Products == nil {
+		manifest.Products = map[string]*Product{}
+	}
+	if manifest.Documents == nil {
+		manifest.Documents = map[string]*Document{}
+	}
+	return manifest
+}
+
+// save writes the manifest with sorted keys so that diffs stay small
+func (m *Manifest) save(path string) {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	temporaryPath := path + ".tmp"
+	if err := os.WriteFile(temporaryPath, append(content, '\n'), 0o644); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := os.Rename(temporaryPath, path); err != nil {
+		log.Println(err)
+	}
+}
+
+// partNumberPattern matches the leading numeric groups of a filename, such
+// as "80565_082" in "80565_082_sds_1.pdf"
+var partNumberPattern = regexp.MustCompile(`^\d+(_\d+)*`)
+
+// partNumberFromFilename derives a CAM2 part number from a sanitized
+// filename, e.g. "80565_082_sds_1.pdf" → "80565-082"
+func partNumberFromFilename(filename string) string {
+	match := partNumberPattern.FindString(strings.TrimSuffix(getFilename(filename), ".pdf"))
+	return strings.ReplaceAll(match, "_", "-")
+}
+
+// productSlug returns the slug of a /product/<slug>/ URL, or "" for other pages
+func productSlug(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) == 2 && parts[0] == "product" {
+		return parts[1]
+	}
+	return ""
+}
+
+// absolutePDFURL resolves a link found on a page against the site root
+func absolutePDFURL(link, remoteDomainName string) string {
+	if !hasDomain(link) {
+		return remoteDomainName + link
+	}
+	return link
+}
+
+// Patterns for the product title on a product page
+var (
+	productTitlePattern = regexp.MustCompile(`(?is)<h1[^>]*product_title[^>]*>(.*?)</h1>`)
+	htmlTitlePattern    = regexp.MustCompile(`(?is)<title>(.*?)</title>`)
+	htmlTagPattern      = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// stripHTML removes tags and entities and collapses whitespace
+func stripHTML(fragment string) string {
+	text := html.UnescapeString(htmlTagPattern.ReplaceAllString(fragment, " "))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// extractPageTitle returns the product name from a product page
+func extractPageTitle(htmlContent string) string {
+	if match := productTitlePattern.FindStringSubmatch(htmlContent); match != nil {
+		return stripHTML(match[1])
+	}
+	if match := htmlTitlePattern.FindStringSubmatch(htmlContent); match != nil {
+		title := stripHTML(match[1])
+		if index := strings.LastIndex(title, " - "); index > 0 { // Drop the site name
+			title = title[:index]
+		}
+		return title
+	}
+	return ""
+}
+
+// linked reports whether a product page, another page or the media library
+// still publishes the document
+func (d *Document) linked() bool {
+	return len(d.Products) > 0 || len(d.Pages) > 0 || d.InMediaLibrary
+}
+
+// recordProductPage stores the PDF links found on one fetched page. Links on
+// pages that are not product pages are recorded in the documents' pages.
+func (m *Manifest) recordProductPage(pageURL, htmlContent, remoteDomainName string) {
+	if htmlContent == "" {
+		return
+	}
+	slug := productSlug(pageURL)
+	var product *Product
+	if slug == "" {
+		// Unlink what the page linked last time, as for product pages below
+		for _, document := range m.Documents {
+			document.Pages = difference(document.Pages, []string{pageURL})
+		}
+	} else {
+		product = m.Products[slug]
+		if product == nil {
+			product = &Product{Slug: slug}
+			m.Products[slug] = product
+		}
+		page := parseProductPage(pageURL, htmlContent)
+		product.URL = pageURL
+		product.Withdrawn = ""
+		if page.Title != "" {
+			product.Title = page.Title
+		}
+		product.Description, product.SKU, product.Sizes = page.Description, page.SKU, page.Sizes
+		product.Specifications, product.Approvals = page.Specifications, page.Approvals
+		// Unlink the documents the page linked last time; the ones still on
+		// the page are linked again below
+		for _, filename := range product.Documents {
+			if document := m.Documents[filename]; document != nil {
+				document.Products = difference(document.Products, []string{slug})
+			}
+		}
+		product.Documents = nil
+	}
+	for _, link := range removeDuplicatesFromSlice(extractPDFUrls(htmlContent)) {
+		sourceURL := absolutePDFURL(html.UnescapeString(link), remoteDomainName)
+		if !isUrlValid(sourceURL) {
+			continue
+		}
+		filename := strings.ToLower(urlToFilename(sourceURL))
+		document := m.Documents[filename]
+		if document == nil {
+			document = &Document{Filename: filename}
+			m.Documents[filename] = document
+		}
+		document.SourceURL = sourceURL
+		document.Kind = documentKind(filename)
+		document.PartNumber = partNumberFromFilename(filename)
+		if product != nil {
+			product.Documents = appendUnique(product.Documents, filename)
+			document.Products = appendUnique(document.Products, slug)
+			sort.Strings(document.Products)
+		} else {
+			document.Pages = appendUnique(document.Pages, pageURL)
+			sort.Strings(document.Pages)
+		}
+	}
+	if product != nil {
+		sort.Strings(product.Documents)
+	}
+}
+
+// missingDocumentURLs returns the source URLs of documents that are still
+// published but not in dir, such as failed downloads on pages an
+// incremental crawl skipped
+func (m *Manifest) missingDocumentURLs(dir string) []string {
+	var urls []string
+	for _, document := range m.Documents {
+		if document.Withdrawn == "" && document.SourceURL != "" && !fileExists(filepath.Join(dir, document.Filename)) {
+			urls = append(urls, document.SourceURL)
+		}
+	}
+	sort.Strings(urls)
+	return urls
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// mediaPageSize is how many entries each media request asks for; 100 is
+// the most WordPress allows
+const mediaPageSize = 100
+
+// MediaItem is one PDF in the WordPress media library
+type MediaItem struct {
+	ID        int    `json:"id"`
+	Title     string `json:"title"`
+	Date      string `json:"date"` // Upload date, in the site's time zone
+	SourceURL string `json:"source_url"`
+}
+
+// wpMedia is the part of a /wp-json/wp/v2/media entry the crawler reads
+type wpMedia struct {
+	ID    int    `json:"id"`
+	Date  string `json:"date"`
+	Title struct {
+		Rendered string `json:"rendered"`
+	} `json:"title"`
+	MimeType  string `json:"mime_type"`
+	SourceURL string `json:"source_url"`
+}
+
+// mediaPageURL returns the URL of one page of PDFs in the media library
+func mediaPageURL(baseURL string, page int) string {
+	query := url.Values{}
+	query.Set("mime_type", "application/pdf")
+	query.Set("per_page", strconv.Itoa(mediaPageSize))
+	query.Set("page", strconv.Itoa(page))
+	query.Set("orderby", "id")
+	query.Set("order", "asc")
+	return strings.TrimSuffix(baseURL, "/") + "/wp-json/wp/v2/media?" + query.Encode()
+}
+
+// discoverMediaPDFs pages through the media library of the WordPress site
+// at baseURL and returns its PDFs. Pages fetched before an error are kept,
+// and false is returned with them as the list is incomplete.
+func discoverMediaPDFs(baseURL string) ([]MediaItem, bool) {
+	client := &http.Client{Timeout: time.Minute, Transport: httpTransport}
+	var items []MediaItem
+	pageURL := func(page int) string { return mediaPageURL(baseURL, page) }
+	err := listWordPress(client, "media", pageURL, func(decoder *json.Decoder) (int, error) {
+		var entries []wpMedia
+		if err := decoder.Decode(&entries); err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			if entry.SourceURL == "" || (entry.MimeType != "" && entry.MimeType != "application/pdf") {
+				continue
+			}
+			items = append(items, MediaItem{
+				ID:        entry.ID,
+				Title:     stripHTML(html.UnescapeString(entry.Title.Rendered)),
+				Date:      entry.Date,
+				SourceURL: entry.SourceURL,
+			})
+		}
+		return len(entries), nil
+	})
+	if err != nil {
+		log.Println("Media discovery stopped:", err)
+		return items, false
+	}
+	return items, true
+}
+
+// mediaURLs returns the source URLs of media items for downloading
+func mediaURLs(items []MediaItem) []string {
+	var urls []string
+	for _, item := range items {
+		urls = append(urls, item.SourceURL)
+	}
+	return urls
+}
+
+// recordMedia stores the media library's title and upload date of each PDF.
+// Documents only found this way are kept without a product. When the list
+// is complete, documents missing from it are no longer in the library.
+func (m *Manifest) recordMedia(items []MediaItem, complete bool) {
+	if complete {
+		for _, document := range m.Documents {
+			document.InMediaLibrary = false
+		}
+	}
+	for _, item := range items {
+		if !isUrlValid(item.SourceURL) {
+			continue
+		}
+		filename := strings.ToLower(urlToFilename(item.SourceURL))
+		document := m.Documents[filename]
+		if document == nil {
+			document = &Document{Filename: filename, SourceURL: item.SourceURL}
+			m.Documents[filename] = document
+		}
+		if document.SourceURL == "" {
+			document.SourceURL = item.SourceURL
+		}
+		document.Kind = documentKind(filename)
+		document.PartNumber = partNumberFromFilename(filename)
+		document.Title = item.Title
+		document.Uploaded = item.Date
+		document.InMediaLibrary = true
+	}
+}
+
+// mediaCommand implements "media": list the PDFs in the media library and
+// whether a product page links them
+func mediaCommand(args []string) {
+	flags := flag.NewFlagSet("media", flag.ExitOnError)
+	baseURL := flags.String("base", "https://cam2.com", "WordPress site to list, e.g. a local stub")
+	asJSON := flags.Bool("json", false, "print the media items as JSON")
+	unlinked := flags.Bool("unlinked", false, "only list PDFs no product page links")
+	manifestFile := flags.String("manifest", manifestPath, "manifest written by the crawler")
+	flags.Parse(args)
+
+	manifest := loadManifest(*manifestFile)
+	items, complete := discoverMediaPDFs(*baseURL)
+	if !complete {
+		fmt.Fprintln(os.Stderr, "The media library could only be listed in part.")
+	}
+	linked := func(item MediaItem) bool {
+		document := manifest.Documents[strings.ToLower(urlToFilename(item.SourceURL))]
+		return document != nil && len(document.Products) > 0
+	}
+	if *unlinked {
+		var filtered []MediaItem
+		for _, item := range items {
+			if !linked(item) {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Date > items[j].Date })
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(items)
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "UPLOADED\tLINKED\tTITLE\tURL")
+	for _, item := range items {
+		isLinked := "no"
+		if linked(item) {
+			isLinked = "yes"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", item.Date, isLinked, item.Title, item.SourceURL)
+	}
+	writer.Flush()
+	fmt.Printf("%d PDFs in the media library.\n", len(items))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// mediaServer serves testdata/media-page-N.json as pages of the media
+// library the way WordPress does, with X-WP-TotalPages when totalPages is
+// set, 400 rest_post_invalid_page_number past the last page and failing
+// with status for the pages in fail
+func mediaServer(t *testing.T, totalPages int, fail map[int]int) (*httptest.Server, *[]int) {
+	var requested []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/wp-json/wp/v2/media" || r.URL.Query().Get("mime_type") != "application/pdf" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		requested = append(requested, page)
+		if status := fail[page]; status != 0 {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		content, err := os.ReadFile(filepath.Join("testdata", "media-page-"+strconv.Itoa(page)+".json"))
+		if err != nil {
+			content, _ = os.ReadFile(filepath.Join("testdata", "media-invalid-page.json"))
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(content)
+			return
+		}
+		if totalPages > 0 {
+			w.Header().Set("X-WP-Total", "4")
+			w.Header().Set("X-WP-TotalPages", strconv.Itoa(totalPages))
+		}
+		w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requested
+}
+
+func TestDiscoverMediaPDFs(t *testing.T) {
+	// The image on page 2 is left out
+	all := []MediaItem{
+		{ID: 1204, Title: "80565_082_SDS", Date: "2023-03-14T09:21:07", SourceURL: "https://cam2.com/wp-content/uploads/2023/03/80565_082_SDS.pdf"},
+		{ID: 1205, Title: "Synavex® dexos1™ Gen 3 5W-30 TDS", Date: "2023-03-14T09:21:12", SourceURL: "https://cam2.com/wp-content/uploads/2023/03/80565_082_TDS.pdf"},
+		{ID: 1391, Title: "NGEO 15W-40 SDS", Date: "2024-05-02T14:10:41", SourceURL: "https://cam2.com/wp-content/uploads/2024/05/80565_215_SDS.pdf"},
+	}
+	tests := []struct {
+		name       string
+		totalPages int
+		fail       map[int]int
+		want       []MediaItem
+		complete   bool
+		requested  []int
+	}{
+		{"X-WP-TotalPages ends the listing", 2, nil, all, true, []int{1, 2}},
+		{"400 past the last page ends the listing", 0, nil, all, true, []int{1, 2, 3}},
+		{"an error keeps the pages before it", 2, map[int]int{2: http.StatusInternalServerError}, all[:2], false, []int{1, 2}},
+		{"400 on the first page is an error", 0, map[int]int{1: http.StatusBadRequest}, nil, false, []int{1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, requested := mediaServer(t, test.totalPages, test.fail)
+			items, complete := discoverMediaPDFs(server.URL)
+			if !reflect.DeepEqual(items, test.want) {
+				t.Errorf("items = %+v\nwant %+v", items, test.want)
+			}
+			if complete != test.complete {
+				t.Errorf("complete = %v, want %v", complete, test.complete)
+			}
+			if !reflect.DeepEqual(*requested, test.requested) {
+				t.Errorf("requested pages %v, want %v", *requested, test.requested)
+			}
+		})
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// notificationsConfigPath is where the notification sinks and watchlists
+// are configured; without it no notifications are sent
+const notificationsConfigPath = "notifications.json"
+
+// ChangeEvent is one document that was added, revised or withdrawn
+type ChangeEvent struct {
+	Type       string   `json:"type"` // "added", "revised" or "withdrawn"
+	Filename   string   `json:"filename"`
+	Kind       string   `json:"kind"`
+	PartNumber string   `json:"part_number,omitempty"`
+	SourceURL  string   `json:"source_url,omitempty"`
+	Products   []string `json:"products,omitempty"`
+	Titles     []string `json:"titles,omitempty"`        // Names of the products
+	Revision   string   `json:"revision_date,omitempty"` // YYYY-MM-DD, read from the PDF
+	Replaces   string   `json:"replaces,omitempty"`      // Filename of the previous revision
+	Time       string   `json:"time"`                    // RFC 3339
+}
+
+// SMTPConfig is the mail server used by watchlists with email recipients
+type SMTPConfig struct {
+	Addr     string `json:"addr"` // host:port, e.g. "localhost:1025"
+	From     string `json:"from"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Watchlist sends the changes to some products to some sinks
+type Watchlist struct {
+	Name     string   `json:"name"`
+	Products []string `json:"products,omitempty"` // Slugs or part numbers; empty watches everything
+	Kinds    []string `json:"kinds,omitempty"`    // e.g. ["sds"]; empty means every kind
+	Webhook  string   `json:"webhook,omitempty"`  // URL that receives a JSON POST
+	Email    []string `json:"email,omitempty"`    // Recipients, sent through SMTP
+	Outbox   string   `json:"outbox,omitempty"`   // Directory that receives one JSON file per batch
+}
+
+// NotificationConfig is the content of notifications.json
+type NotificationConfig struct {
+	SMTP       SMTPConfig  `json:"smtp"`
+	Watchlists []Watchlist `json:"watchlists"`
+}
+
+// notificationSink delivers a batch of changes somewhere
+type notificationSink interface {
+	send(list string, events []ChangeEvent) error
+	String() string
+}
+
+// webhookSink posts the changes as JSON
+type webhookSink struct{ url string }
+
+func (s webhookSink) String() string { return "webhook " + s.url }
+
+func (s webhookSink) send(list string, events []ChangeEvent) error {
+	body, err := json.Marshal(map[string]interface{}{"watchlist": list, "events": events})
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	response, err := client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", response.Status)
+	}
+	return nil
+}
+
+// smtpSink mails a plain-text summary of the changes
+type smtpSink struct {
+	config SMTPConfig
+	to     []string
+}
+
+func (s smtpSink) String() string { return "email to " + strings.Join(s.to, ", ") }
+
+func (s smtpSink) send(list string, events []ChangeEvent) error {
+	if s.config.Addr == "" || s.config.From == "" {
+		return errors.New("smtp addr and from must be set in " + notificationsConfigPath)
+	}
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", s.config.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&message, "Subject: CAM2 data sheet changes (%s): %s\r\n", list, summarizeEvents(events))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprint(&message, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, event := range events {
+		fmt.Fprintf(&message, "%s\r\n", describeEvent(event))
+	}
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		host := s.config.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, host)
+	}
+	return smtp.SendMail(s.config.Addr, auth, s.config.From, s.to, message.Bytes())
+}
+
+// outboxSink writes each batch of changes to a JSON file
+type outboxSink struct{ dir string }
+
+func (s outboxSink) String() string { return "outbox " + s.dir }
+
+func (s outboxSink) send(list string, events []ChangeEvent) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(map[string]interface{}{"watchlist": list, "events": events}, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Timestamped names keep the files in order; the suffix avoids clobbering
+	// a batch written in the same second
+	base := time.Now().UTC().Format("20060102T150405Z")
+	for attempt := 0; ; attempt++ {
+		name := base + ".json"
+		if attempt > 0 {
+			name = fmt.Sprintf("%s-%d.json", base, attempt)
+		}
+		file, err := os.OpenFile(filepath.Join(s.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(append(content, '\n'))
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+}
+
+// sinks returns where a watchlist sends its notifications
+func (w Watchlist) sinks(config NotificationConfig) []notificationSink {
+	var sinks []notificationSink
+	if w.Webhook != "" {
+		sinks = append(sinks, webhookSink{w.Webhook})
+	}
+	if len(w.Email) > 0 {
+		sinks = append(sinks, smtpSink{config.SMTP, w.Email})
+	}
+	if w.Outbox != "" {
+		sinks = append(sinks, outboxSink{w.Outbox})
+	}
+	return sinks
+}
+
+// matches reports whether a watchlist covers a change
+func (w Watchlist) matches(event ChangeEvent) bool {
+	if len(w.Kinds) > 0 && !containsString(w.Kinds, event.Kind) {
+		return false
+	}
+	if len(w.Products) == 0 {
+		return true
+	}
+	for _, watched := range w.Products {
+		watched = strings.ToLower(strings.Trim(watched, "/ "))
+		if slug := productSlug(watched); slug != "" {
+			watched = slug
+		}
+		if containsString(event.Products, watched) || event.PartNumber != "" && event.PartNumber == strings.ReplaceAll(watched, "_", "-") {
+			return true
+		}
+	}
+	return false
+}
+
+// loadNotificationConfig reads notifications.json; a missing file means no
+// watchlists
+func loadNotificationConfig(path string) NotificationConfig {
+	var config NotificationConfig
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Println(err)
+		}
+		return config
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		log.Printf("Failed to parse %s: %v", path, err)
+	}
+	return config
+}
+
+// detectChanges compares the manifest before and after a crawl. A product
+// that stops linking one document of a kind and starts linking another has
+// had that document revised, as has a document whose mirrored copy was
+// replaced by different bytes; other documents that appear on a product
+// page, another page or in the media library are added, and documents
+// published nowhere any more are withdrawn.
+func detectChanges(before, after *Manifest) []ChangeEvent {
+	now := time.Now().UTC().Format(time.RFC3339)
+	event := func(kind string, document *Document, manifest *Manifest) ChangeEvent {
+		change := ChangeEvent{
+			Type:       kind,
+			Filename:   document.Filename,
+			Kind:       document.Kind,
+			PartNumber: document.PartNumber,
+			SourceURL:  document.SourceURL,
+			Products:   document.Products,
+			Time:       now,
+		}
+		for _, slug := range document.Products {
+			if product := manifest.Products[slug]; product != nil {
+				change.Titles = append(change.Titles, product.name())
+			}
+		}
+		return change
+	}
+	linked := func(manifest *Manifest, filename string) bool {
+		document := manifest.Documents[filename]
+		return document != nil && document.linked()
+	}
+	var events []ChangeEvent
+	reported := map[string]bool{}
+	var slugs []string
+	for slug := range after.Products {
+		slugs = append(slugs, slug)
+	}
+	sort.Strings(slugs)
+	for _, slug := range slugs {
+		previous := before.Products[slug]
+		if previous == nil {
+			continue
+		}
+		current := after.Products[slug]
+		for _, kind := range []string{"sds", "tds", "other"} {
+			oldFiles, newFiles := previous.documentsOfKind(before, kind), current.documentsOfKind(after, kind)
+			removed, added := difference(oldFiles, newFiles), difference(newFiles, oldFiles)
+			for i := 0; i < len(removed) && i < len(added); i++ {
+				if reported[added[i]] || linked(before, added[i]) {
+					continue
+				}
+				revision := event("revised", after.Documents[added[i]], after)
+				revision.Replaces = removed[i]
+				events = append(events, revision)
+				reported[added[i]], reported[removed[i]] = true, true
+			}
+		}
+	}
+	var filenames []string
+	for filename := range after.Documents {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	// Revised in place, or uploaded again under the same name
+	for _, filename := range filenames {
+		previous, current := before.Documents[filename], after.Documents[filename]
+		if reported[filename] || previous == nil || previous.SHA256 == "" || current.SHA256 == "" || current.SHA256 == previous.SHA256 || !linked(after, filename) {
+			continue
+		}
+		events = append(events, event("revised", current, after))
+		reported[filename] = true
+	}
+	for _, filename := range filenames {
+		if !reported[filename] && linked(after, filename) && !linked(before, filename) {
+			events = append(events, event("added", after.Documents[filename], after))
+		}
+	}
+	filenames = nil
+	for filename := range before.Documents {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		if !reported[filename] && linked(before, filename) && !linked(after, filename) {
+			events = append(events, event("withdrawn", before.Documents[filename], before))
+		}
+	}
+	return events
+}
+
+// addRevisionDates reads the revision date of each changed document that
+// is in dir
+func addRevisionDates(events []ChangeEvent, dir string) {
+	for i := range events {
+		if text, err := pdfText(filepath.Join(dir, events[i].Filename)); err == nil {
+			if date, ok := documentRevisionDate(text); ok {
+				events[i].Revision = date.Format("2006-01-02")
+			}
+		}
+	}
+}
+
+// describeEvent is a one-line description of a change
+func describeEvent(event ChangeEvent) string {
+	text := fmt.Sprintf("%s %s %s", strings.ToUpper(event.Kind), event.Filename, event.Type)
+	if event.Replaces != "" {
+		text += ", replacing " + event.Replaces
+	}
+	if len(event.Titles) > 0 {
+		text += " (" + strings.Join(event.Titles, ", ") + ")"
+	} else if len(event.Products) > 0 {
+		text += " (" + strings.Join(event.Products, ", ") + ")"
+	}
+	if event.Revision != "" {
+		text += ", revised " + event.Revision
+	}
+	if event.SourceURL != "" {
+		text += " " + event.SourceURL
+	}
+	return text
+}
+
+// summarizeEvents counts the changes by type, e.g. "2 revised, 1 added"
+func summarizeEvents(events []ChangeEvent) string {
+	counts := map[string]int{}
+	for _, event := range events {
+		counts[event.Type]++
+	}
+	var parts []string
+	for _, kind := range []string{"added", "revised", "withdrawn"} {
+		if counts[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// notifyChanges sends each watchlist the changes it covers
+func notifyChanges(config NotificationConfig, events []ChangeEvent) {
+	for _, watchlist := range config.Watchlists {
+		var matched []ChangeEvent
+		for _, event := range events {
+			if watchlist.matches(event) {
+				matched = append(matched, event)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		for _, sink := range watchlist.sinks(config) {
+			if err := sink.send(watchlist.Name, matched); err != nil {
+				log.Printf("Failed to notify %s through %s: %v", watchlist.Name, sink, err)
+			} else {
+				log.Printf("Notified %s through %s: %s", watchlist.Name, sink, summarizeEvents(matched))
+			}
+		}
+	}
+}
+
+// notifyCommand implements "notify": sends a test change to every sink so
+// the configuration can be checked, e.g. against a local SMTP stand-in
+func notifyCommand(args []string) {
+	flags := flag.NewFlagSet("notify", flag.ExitOnError)
+	configFile := flags.String("config", notificationsConfigPath, "notification sinks and watchlists")
+	flags.Parse(args)
+
+	config := loadNotificationConfig(*configFile)
+	if len(config.Watchlists) == 0 {
+		fmt.Fprintf(os.Stderr, "no watchlists in %s\n", *configFile)
+		os.Exit(1)
+	}
+	failed := false
+	for _, watchlist := range config.Watchlists {
+		event := ChangeEvent{Type: "added", Filename: "test_sds.pdf", Kind: "sds", Time: time.Now().UTC().Format(time.RFC3339)}
+		if len(watchlist.Products) > 0 {
+			event.Products = []string{watchlist.Products[0]}
+		}
+		sinks := watchlist.sinks(config)
+		if len(sinks) == 0 {
+			fmt.Printf("%s: no webhook, email or outbox\n", watchlist.Name)
+		}
+		for _, sink := range sinks {
+			if err := sink.send(watchlist.Name, []ChangeEvent{event}); err != nil {
+				fmt.Printf("%s: %s failed: %v\n", watchlist.Name, sink, err)
+				failed = true
+			} else {
+				fmt.Printf("%s: %s ok\n", watchlist.Name, sink)
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
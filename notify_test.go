@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// eventSummary is what the tests compare of a change
+type eventSummary struct {
+	Type, Filename, Replaces string
+}
+
+func summaries(events []ChangeEvent) []eventSummary {
+	var result []eventSummary
+	for _, event := range events {
+		result = append(result, eventSummary{event.Type, event.Filename, event.Replaces})
+	}
+	return result
+}
+
+func TestDetectChanges(t *testing.T) {
+	before := &Manifest{
+		Products: map[string]*Product{"oil": {Slug: "oil", Documents: []string{"a_sds.pdf", "b_tds.pdf"}}},
+		Documents: map[string]*Document{
+			"a_sds.pdf":   {Filename: "a_sds.pdf", Kind: "sds", Products: []string{"oil"}, SHA256: "1"},
+			"b_tds.pdf":   {Filename: "b_tds.pdf", Kind: "tds", Products: []string{"oil"}, SHA256: "2"},
+			"c_sds.pdf":   {Filename: "c_sds.pdf", Kind: "sds", Pages: []string{"https://cam2.com/data-sheets/"}, SHA256: "3"},
+			"d_sds.pdf":   {Filename: "d_sds.pdf", Kind: "sds", InMediaLibrary: true, SHA256: "4"},
+			"old_tds.pdf": {Filename: "old_tds.pdf", Kind: "tds", Products: []string{"grease"}, SHA256: "5"},
+		},
+	}
+	after := &Manifest{
+		Products: map[string]*Product{"oil": {Slug: "oil", Documents: []string{"a_sds.pdf", "b2_tds.pdf"}}},
+		Documents: map[string]*Document{
+			// Replaced in place with different bytes
+			"a_sds.pdf": {Filename: "a_sds.pdf", Kind: "sds", Products: []string{"oil"}, SHA256: "1b"},
+			// Replaced by a new file on the product page
+			"b_tds.pdf":  {Filename: "b_tds.pdf", Kind: "tds", SHA256: "2"},
+			"b2_tds.pdf": {Filename: "b2_tds.pdf", Kind: "tds", Products: []string{"oil"}, SHA256: "6"},
+			// No longer on /data-sheets/
+			"c_sds.pdf": {Filename: "c_sds.pdf", Kind: "sds", SHA256: "3"},
+			// Still in the media library, unchanged
+			"d_sds.pdf": {Filename: "d_sds.pdf", Kind: "sds", InMediaLibrary: true, SHA256: "4"},
+			// New, only in the media library
+			"e_sds.pdf": {Filename: "e_sds.pdf", Kind: "sds", InMediaLibrary: true, SHA256: "7"},
+			// Unlinked from its product page
+			"old_tds.pdf": {Filename: "old_tds.pdf", Kind: "tds", SHA256: "5"},
+		},
+	}
+	want := []eventSummary{
+		{"revised", "b2_tds.pdf", "b_tds.pdf"},
+		{"revised", "a_sds.pdf", ""},
+		{"added", "e_sds.pdf", ""},
+		{"withdrawn", "c_sds.pdf", ""},
+		{"withdrawn", "old_tds.pdf", ""},
+	}
+	if got := summaries(detectChanges(before, after)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestDownloadPDFRevalidates(t *testing.T) {
+	t.Chdir(t.TempDir()) // withdrawn/ is relative to the working directory
+	body, etag := "%PDF-1.4 first", `"v1"`
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	dir := "PDFs"
+	os.Mkdir(dir, 0o755)
+	sourceURL := server.URL + "/wp-content/uploads/2024/05/80565_082_sds.pdf"
+	document := &Document{Filename: "80565_082_sds.pdf"}
+	path := filepath.Join(dir, document.Filename)
+	read := func() string {
+		content, _ := os.ReadFile(path)
+		return string(content)
+	}
+
+	if written, _, _ := downloadPDF(sourceURL, dir, document); !written || read() != body || document.ETag != etag || document.SHA256 == "" {
+		t.Fatalf("first download: written %v, file %q, document %+v", written, read(), document)
+	}
+	first := document.SHA256
+	// As addDocumentDates records them from the first version
+	document.PDFInfo = &PDFInfo{Modified: "2024-05-02T10:00:00Z"}
+	document.RevisionDate = "2024-04-30"
+
+	// Not modified: nothing is written and the dates stand
+	if written, _, detected := downloadPDF(sourceURL, dir, document); written || detected != "" {
+		t.Errorf("revalidation wrote the file again")
+	}
+	if document.PDFInfo == nil || document.RevisionDate == "" {
+		t.Errorf("revalidation forgot the dates: %+v", document)
+	}
+
+	// Revised in place: the copy is replaced and the old one kept
+	body, etag = "%PDF-1.4 second", `"v2"`
+	if written, _, _ := downloadPDF(sourceURL, dir, document); !written || read() != body {
+		t.Fatalf("revision: written %v, file %q", written, read())
+	}
+	if document.SHA256 == first || len(document.Superseded) != 1 {
+		t.Fatalf("revision not recorded: %+v", document)
+	}
+	// The dates of the old version must be read again from the new one
+	if document.PDFInfo != nil || document.RevisionDate != "" {
+		t.Errorf("revision kept the old dates: %+v, %q", document.PDFInfo, document.RevisionDate)
+	}
+	if old, err := os.ReadFile(filepath.Join(withdrawnDir, document.Superseded[0])); err != nil || string(old) != "%PDF-1.4 first" {
+		t.Errorf("replaced copy %s: %q, %v", document.Superseded[0], old, err)
+	}
+
+	// Uploaded again under another month folder with the same bytes: fetched
+	// without validators, and left alone
+	movedURL := server.URL + "/wp-content/uploads/2025/01/80565_082_sds.pdf"
+	etag = `"v3"`
+	if written, _, _ := downloadPDF(movedURL, dir, document); written || document.FetchedURL != movedURL || len(document.Superseded) != 1 {
+		t.Errorf("same bytes under a new URL: written %v, document %+v", written, document)
+	}
+	if requests != 4 {
+		t.Errorf("%d requests, want 4", requests)
+	}
+}
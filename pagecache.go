@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pageCacheDir keeps the last response of every page the crawler fetched
+const pageCacheDir = ".cache/pages"
+
+// cachedPage is the metadata of one cached page; the body is stored next to
+// it as a .html file so it can be opened directly
+type cachedPage struct {
+	URL          string `json:"url"`
+	Status       int    `json:"status"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	Fetched      string `json:"fetched"`             // RFC 3339
+	Validated    string `json:"validated,omitempty"` // Last 304 Not Modified, RFC 3339
+}
+
+// pageCachePaths returns the metadata and body paths of a URL's cache entry
+func pageCachePaths(uri string) (string, string) {
+	sum := sha256.Sum256([]byte(uri))
+	key := filepath.Join(pageCacheDir, hex.EncodeToString(sum[:16]))
+	return key + ".json", key + ".html"
+}
+
+// loadCachedPage returns the cache entry of a URL and its body
+func loadCachedPage(uri string) (*cachedPage, string, bool) {
+	metaPath, bodyPath := pageCachePaths(uri)
+	content, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, "", false
+	}
+	var page cachedPage
+	if err := json.Unmarshal(content, &page); err != nil || page.URL != uri {
+		return nil, "", false
+	}
+	body, err := os.ReadFile(bodyPath)
+	if err != nil && page.Status >= 200 && page.Status <= 299 {
+		return nil, "", false
+	}
+	return &page, string(body), true
+}
+
+// save writes a cache entry and its body
+func (p *cachedPage) save(body string) {
+	metaPath, bodyPath := pageCachePaths(p.URL)
+	if err := os.MkdirAll(pageCacheDir, 0o755); err != nil {
+		log.Println(err)
+		return
+	}
+	content, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := os.WriteFile(bodyPath, []byte(body), 0o644); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := os.WriteFile(metaPath, append(content, '\n'), 0o644); err != nil {
+		log.Println(err)
+	}
+}
+
+// fetchPage is fetchURL through the page cache. A cached page is
+// revalidated with If-None-Match and If-Modified-Since, and reused when the
+// server answers 304 Not Modified. Offline, only the cache is used and
+// pages that were never fetched have status 0.
+func fetchPage(uri string, offline bool) (string, int) {
+	cached, cachedBody, found := loadCachedPage(uri)
+	if offline {
+		if !found {
+			log.Println("Not in the page cache:", uri)
+			return "", 0
+		}
+		log.Println("Using cached", uri)
+		return cachedBody, cached.Status
+	}
+
+	header := http.Header{}
+	if found && cached.Status >= 200 && cached.Status <= 299 {
+		if cached.ETag != "" {
+			header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	body, status, responseHeader := requestURL(uri, header)
+	now := time.Now().UTC().Format(time.RFC3339)
+	switch {
+	case status == http.StatusNotModified && found:
+		log.Println("Not modified, using cached", uri)
+		cached.Validated = now
+		cached.save(cachedBody)
+		return cachedBody, cached.Status
+	case status >= 200 && status <= 299 && body != "":
+		page := &cachedPage{URL: uri, Status: status, Fetched: now,
+			ETag: responseHeader.Get("ETag"), LastModified: responseHeader.Get("Last-Modified")}
+		page.save(body)
+	case pageGone(status):
+		// Remembered so offline runs see the page gone too
+		page := &cachedPage{URL: uri, Status: status, Fetched: now}
+		page.save("")
+	}
+	return body, status
+}
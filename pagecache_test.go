@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchPageRevalidates(t *testing.T) {
+	t.Chdir(t.TempDir()) // .cache/pages is relative to the working directory
+	const lastModified = "Wed, 01 Oct 2026 10:00:00 GMT"
+	body, etag := "<html><body>CAM2 Synavex 5W-30</body></html>", `"v1"`
+	var requests []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Clone())
+		if r.URL.Path == "/product/cam2-discontinued/" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+	page := server.URL + "/product/cam2-synavex-5w-30/"
+	gone := server.URL + "/product/cam2-discontinued/"
+
+	// Offline, a page that was never fetched is missing
+	if got, status := fetchPage(page, true); got != "" || status != 0 || len(requests) != 0 {
+		t.Errorf("offline before any fetch: %q, %d, %d requests", got, status, len(requests))
+	}
+
+	// The first fetch is stored with its validators
+	if got, status := fetchPage(page, false); got != body || status != http.StatusOK {
+		t.Fatalf("first fetch: %q, %d", got, status)
+	}
+	cached, cachedBody, found := loadCachedPage(page)
+	if !found || cachedBody != body || cached.ETag != etag || cached.LastModified != lastModified || cached.Validated != "" {
+		t.Fatalf("cached %+v, %q, %v", cached, cachedBody, found)
+	}
+	if requests[0].Get("If-None-Match") != "" || requests[0].Get("If-Modified-Since") != "" {
+		t.Errorf("first fetch sent validators: %v", requests[0])
+	}
+
+	// A 304 reuses the cached body
+	if got, status := fetchPage(page, false); got != body || status != http.StatusOK {
+		t.Errorf("revalidation: %q, %d", got, status)
+	}
+	if header := requests[1]; header.Get("If-None-Match") != etag || header.Get("If-Modified-Since") != lastModified {
+		t.Errorf("revalidation sent %v", header)
+	}
+	if cached, _, _ := loadCachedPage(page); cached.Validated == "" || cached.ETag != etag {
+		t.Errorf("revalidation not recorded: %+v", cached)
+	}
+
+	// A changed page replaces the cache entry
+	body, etag = "<html><body>CAM2 Synavex 5W-30 SP</body></html>", `"v2"`
+	if got, status := fetchPage(page, false); got != body || status != http.StatusOK {
+		t.Errorf("changed page: %q, %d", got, status)
+	}
+	if cached, cachedBody, _ := loadCachedPage(page); cachedBody != body || cached.ETag != etag || cached.Validated != "" {
+		t.Errorf("changed page cached as %+v, %q", cached, cachedBody)
+	}
+
+	// A page that is gone is remembered without validators
+	if got, status := fetchPage(gone, false); got != "" || status != http.StatusNotFound {
+		t.Errorf("gone page: %q, %d", got, status)
+	}
+	fetchPage(gone, false)
+	if header := requests[len(requests)-1]; header.Get("If-None-Match") != "" || header.Get("If-Modified-Since") != "" {
+		t.Errorf("gone page revalidated with %v", header)
+	}
+
+	// Offline, both come from the cache without a request
+	count := len(requests)
+	if got, status := fetchPage(page, true); got != body || status != http.StatusOK {
+		t.Errorf("offline: %q, %d", got, status)
+	}
+	if got, status := fetchPage(gone, true); got != "" || status != http.StatusNotFound {
+		t.Errorf("offline gone page: %q, %d", got, status)
+	}
+	if len(requests) != count {
+		t.Errorf("offline made %d requests", len(requests)-count)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// PDFInfo is the document information dictionary of a PDF
+type PDFInfo struct {
+	Title    string `json:"title,omitempty"`
+	Producer string `json:"producer,omitempty"`
+	Created  string `json:"created,omitempty"`  // CreationDate, RFC 3339
+	Modified string `json:"modified,omitempty"` // ModDate, RFC 3339
+}
+
+var (
+	uploadPathPattern = regexp.MustCompile(`/wp-content/uploads/(\d{4})/(\d{2})/`)
+	pdfDatePattern    = regexp.MustCompile(`^(?:D:)?(\d{4})(\d{2})?(\d{2})?(\d{2})?(\d{2})?(\d{2})?(?:([Zz+\-])(\d{2})?'?(\d{2})?'?)?`)
+)
+
+// uploadMonth returns the year and month WordPress filed an upload under,
+// as "2023-05", or "" when the URL is not an upload path
+func uploadMonth(sourceURL string) string {
+	match := uploadPathPattern.FindStringSubmatch(sourceURL)
+	if match == nil {
+		return ""
+	}
+	return match[1] + "-" + match[2]
+}
+
+// parsePDFDate parses a PDF date string such as "D:20230510142201-05'00'".
+// Everything after the year is optional; without a zone it is taken as UTC.
+func parsePDFDate(raw string) (time.Time, bool) {
+	match := pdfDatePattern.FindStringSubmatch(strings.TrimSpace(raw))
+	if match == nil {
+		return time.Time{}, false
+	}
+	number := func(field string, fallback int) int {
+		if field == "" {
+			return fallback
+		}
+		value := 0
+		fmt.Sscan(field, &value)
+		return value
+	}
+	location := time.UTC
+	if sign := match[7]; sign == "+" || sign == "-" {
+		offset := number(match[8], 0)*3600 + number(match[9], 0)*60
+		if sign == "-" {
+			offset = -offset
+		}
+		location = time.FixedZone("", offset)
+	}
+	month, day, hour, minute := number(match[2], 1), number(match[3], 1), number(match[4], 0), number(match[5], 0)
+	date := time.Date(number(match[1], 0), time.Month(month), day, hour, minute, number(match[6], 0), 0, location)
+	// time.Date rolls 20230231 over into March; such dates are rejected
+	if int(date.Month()) != month || date.Day() != day || date.Hour() != hour || date.Minute() != minute {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// readPDFInfo reads the document information dictionary of a PDF file
+func readPDFInfo(path string) (PDFInfo, error) {
+	var info PDFInfo
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return info, err
+	}
+	doc, err := parsePDF(data)
+	if err != nil {
+		return info, err
+	}
+	dict := doc.dict(doc.trailer["Info"])
+	if dict == nil {
+		return info, errors.New("no document information dictionary")
+	}
+	text := func(key string) string {
+		raw, _ := doc.resolve(dict[key]).(pdfString)
+		return strings.Join(strings.Fields(decodePDFTextString(raw)), " ")
+	}
+	date := func(key string) string {
+		if parsed, ok := parsePDFDate(text(key)); ok {
+			return parsed.Format(time.RFC3339)
+		}
+		return ""
+	}
+	info = PDFInfo{Title: text("Title"), Producer: text("Producer"), Created: date("CreationDate"), Modified: date("ModDate")}
+	return info, nil
+}
+
+// addDocumentDates records the upload month of every document, and the
+// information dictionary and SDS revision date of those in dir that do not
+// have them yet. downloadPDF forgets both when it replaces a file, so each
+// version of a file is read once.
+func (m *Manifest) addDocumentDates(dir string) {
+	for _, document := range m.Documents {
+		document.UploadMonth = uploadMonth(document.SourceURL)
+		path := filepath.Join(dir, document.Filename)
+		if !fileExists(path) {
+			continue
+		}
+		if document.PDFInfo == nil {
+			if info, err := readPDFInfo(path); err != nil {
+				log.Printf("No PDF information for %s: %v", path, err)
+			} else {
+				document.PDFInfo = &info
+			}
+		}
+		if document.Kind == "sds" && document.RevisionDate == "" {
+			if text, err := pdfText(path); err == nil {
+				if date, ok := documentRevisionDate(text); ok {
+					document.RevisionDate = date.Format("2006-01-02")
+				}
+			}
+		}
+	}
+}
+
+// published returns the best known publication date of a document: the
+// media library's upload date, then the upload folder's month, then the
+// PDF's own modification and creation dates. Dates are "2006-01-02" or,
+// from the upload folder, "2006-01".
+func (d *Document) published() string {
+	switch {
+	case len(d.Uploaded) >= 10:
+		return d.Uploaded[:10]
+	case d.UploadMonth != "":
+		return d.UploadMonth
+	case d.PDFInfo != nil && len(d.PDFInfo.Modified) >= 10:
+		return d.PDFInfo.Modified[:10]
+	case d.PDFInfo != nil && len(d.PDFInfo.Created) >= 10:
+		return d.PDFInfo.Created[:10]
+	}
+	return ""
+}
+
+// documentsCommand implements "documents": list the documents with their
+// dates, oldest first, optionally only those published in a date range
+func documentsCommand(args []string) {
+	flags := flag.NewFlagSet("documents", flag.ExitOnError)
+	kind := flags.String("type", "", "only documents of this kind: sds, tds or other")
+	since := flags.String("since", "", "only documents published on or after this date, e.g. 2023-01")
+	before := flags.String("before", "", "only documents published before this date, e.g. 2020-01-01")
+	sortBy := flags.String("sort", "age", "order by age (oldest first), newest or name")
+	asJSON := flags.Bool("json", false, "print the documents as JSON")
+	dir := flags.String("dir", "PDFs", "directory of downloaded PDFs")
+	manifestFile := flags.String("manifest", manifestPath, "manifest written by the crawler")
+	flags.Parse(args)
+
+	manifest := loadManifest(*manifestFile)
+	// Files downloaded before the manifest existed are listed too
+	files, _ := filepath.Glob(filepath.Join(*dir, "*.pdf"))
+	for _, path := range files {
+		filename := getFilename(path)
+		if manifest.Documents[filename] == nil {
+			manifest.Documents[filename] = &Document{Filename: filename, Kind: documentKind(filename), PartNumber: partNumberFromFilename(filename)}
+		}
+	}
+	manifest.addDocumentDates(*dir)
+
+	var documents []*Document
+	for _, document := range manifest.Documents {
+		published := document.published()
+		if *kind != "" && document.Kind != *kind {
+			continue
+		}
+		if *since != "" && (published == "" || published < *since) {
+			continue
+		}
+		if *before != "" && (published == "" || published >= *before) {
+			continue
+		}
+		documents = append(documents, document)
+	}
+	sort.Slice(documents, func(i, j int) bool {
+		a, b := documents[i].published(), documents[j].published()
+		switch {
+		case *sortBy == "name" || a == b:
+			return documents[i].Filename < documents[j].Filename
+		case a == "" || b == "": // Undated documents last
+			return b == ""
+		case *sortBy == "newest":
+			return a > b
+		}
+		return a < b
+	})
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(documents)
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "PUBLISHED\tUPLOADED\tCREATED\tMODIFIED\tTYPE\tFILE\tPRODUCER")
+	for _, document := range documents {
+		info := PDFInfo{}
+		if document.PDFInfo != nil {
+			info = *document.PDFInfo
+		}
+		uploaded := document.UploadMonth
+		if len(document.Uploaded) >= 10 {
+			uploaded = document.Uploaded[:10]
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", orDash(document.published()), orDash(uploaded),
+			orDash(shortDate(info.Created)), orDash(shortDate(info.Modified)), document.Kind, document.Filename, info.Producer)
+	}
+	writer.Flush()
+}
+
+// shortDate keeps the date part of an RFC 3339 time
+func shortDate(value string) string {
+	if len(value) >= 10 {
+		return value[:10]
+	}
+	return value
+}
+
+// orDash stands in for an empty column
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
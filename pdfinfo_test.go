@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePDFDate(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string // RFC 3339, empty when the date is invalid
+	}{
+		{"D:20230510142201-05'00'", "2023-05-10T14:22:01-05:00"},
+		{"D:20230510142201+05'30'", "2023-05-10T14:22:01+05:30"},
+		{"D:20230510142201+05'30", "2023-05-10T14:22:01+05:30"},
+		{"D:20230510142201+02", "2023-05-10T14:22:01+02:00"},
+		{"D:20230510142201Z", "2023-05-10T14:22:01Z"},
+		{"D:20230510142201Z00'00'", "2023-05-10T14:22:01Z"},
+		{"D:20230510142201", "2023-05-10T14:22:01Z"}, // No zone: UTC
+		{"20230510142201-07'00'", "2023-05-10T14:22:01-07:00"},
+		{" D:20230510142201Z ", "2023-05-10T14:22:01Z"},
+		// Partial dates
+		{"D:202305101422", "2023-05-10T14:22:00Z"},
+		{"D:20230510", "2023-05-10T00:00:00Z"},
+		{"D:202305", "2023-05-01T00:00:00Z"},
+		{"D:2023", "2023-01-01T00:00:00Z"},
+		// Invalid dates and times
+		{"D:20230231120000Z", ""},
+		{"D:20230431", ""},
+		{"D:20230500", ""},
+		{"D:20231301", ""},
+		{"D:20230510250000", ""},
+		{"D:20230510126100", ""},
+		{"D:202", ""},
+		{"Wed May 10 14:22:01 2023", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		date, ok := parsePDFDate(test.raw)
+		got := ""
+		if ok {
+			got = date.Format(time.RFC3339)
+		}
+		if got != test.want {
+			t.Errorf("parsePDFDate(%q) = %q, want %q", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestUploadMonth(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://cam2.com/wp-content/uploads/2023/05/80565_082_SDS.pdf", "2023-05"},
+		{"http://www.cam2.com/wp-content/uploads/2019/11/Synavex-TDS-1.pdf", "2019-11"},
+		{"https://cam2.com/wp-content/uploads/80565_082_SDS.pdf", ""},
+		{"https://cam2.com/wp-content/uploads/2023/5/80565_082_SDS.pdf", ""},
+		{"https://cam2.com/product/cam2-synavex-5w-30/", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := uploadMonth(test.url); got != test.want {
+			t.Errorf("uploadMonth(%q) = %q, want %q", test.url, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,1546 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// The PDF reader below only understands as much of the PDF format as is
+// needed to pull text out of the data sheets in PDFs/. It locates objects by
+// scanning the file instead of trusting the cross-reference table, which
+// keeps it working on the linearized and incrementally updated files that
+// Word and Acrobat produce.
+
+// pdfName is a PDF name object without its leading slash
+type pdfName string
+
+// pdfString holds the raw bytes of a literal or hex string
+type pdfString string
+
+// pdfRef is an indirect reference such as "12 0 R"
+type pdfRef struct {
+	num, gen int
+}
+
+// pdfDict is a PDF dictionary keyed by name without the slash
+type pdfDict map[string]any
+
+// pdfStream is a stream object with its undecoded data
+type pdfStream struct {
+	dict pdfDict
+	raw  []byte
+	ref  pdfRef
+}
+
+// pdfOperator is a bare keyword found while lexing a content stream
+type pdfOperator string
+
+// pdfDocument is a parsed PDF file
+type pdfDocument struct {
+	objects map[int]any   // Object number → value
+	crypt   *pdfDecryptor // Non-nil when the file is encrypted
+	trailer pdfDict       // Trailer or cross-reference stream dictionary
+	fonts   map[pdfRef]*pdfFont
+}
+
+// pdfLexer tokenizes PDF object syntax and content streams
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+// isPDFWhitespace reports whether b is a PDF whitespace character
+func isPDFWhitespace(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\r' || b == '\t' || b == '\f' || b == 0
+}
+
+// isPDFDelimiter reports whether b ends a regular token
+func isPDFDelimiter(b byte) bool {
+	return strings.IndexByte("()<>[]{}/%", b) >= 0
+}
+
+// skipSpace moves past whitespace and comments
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		b := l.data[l.pos]
+		if isPDFWhitespace(b) {
+			l.pos++
+			continue
+		}
+		if b == '%' { // Comment runs to the end of the line
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+// errPDFEnd signals that the lexer ran out of input
+var errPDFEnd = errors.New("unexpected end of PDF data")
+
+// next returns the next token: a value, a pdfOperator, or one of the
+// delimiter operators "[", "]", "<<" and ">>"
+func (l *pdfLexer) next() (any, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, errPDFEnd
+	}
+	b := l.data[l.pos]
+	switch {
+	case b == '/':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.data) && !isPDFWhitespace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		return pdfName(decodeNameEscapes(string(l.data[start:l.pos]))), nil
+	case b == '(':
+		return l.readLiteralString(), nil
+	case b == '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return pdfOperator("<<"), nil
+		}
+		return l.readHexString(), nil
+	case b == '>':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return pdfOperator(">>"), nil
+		}
+		l.pos++
+		return pdfOperator(">"), nil
+	case b == '[' || b == ']' || b == '{' || b == '}':
+		l.pos++
+		return pdfOperator(string(b)), nil
+	case b == ')':
+		l.pos++
+		return pdfOperator(")"), nil
+	}
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFWhitespace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+	if number, err := strconv.ParseFloat(word, 64); err == nil {
+		return number, nil
+	}
+	switch word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfOperator(word), nil
+}
+
+// decodeNameEscapes expands #xx sequences inside a name
+func decodeNameEscapes(name string) string {
+	if !strings.Contains(name, "#") {
+		return name
+	}
+	var out strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if value, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				out.WriteByte(byte(value))
+				i += 2
+				continue
+			}
+		}
+		out.WriteByte(name[i])
+	}
+	return out.String()
+}
+
+// readLiteralString reads a (...) string honoring escapes and nesting
+func (l *pdfLexer) readLiteralString() pdfString {
+	l.pos++ // Skip the opening parenthesis
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		b := l.data[l.pos]
+		l.pos++
+		switch b {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString(out)
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return pdfString(out)
+			}
+			escaped := l.data[l.pos]
+			l.pos++
+			switch escaped {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r': // Line continuation
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if escaped >= '0' && escaped <= '7' { // Up to three octal digits
+					value := int(escaped - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						value = value*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(value))
+				} else {
+					out = append(out, escaped)
+				}
+			}
+			continue
+		}
+		out = append(out, b)
+	}
+	return pdfString(out)
+}
+
+// readHexString reads a <...> string
+func (l *pdfLexer) readHexString() pdfString {
+	l.pos++ // Skip the opening angle bracket
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		if !isPDFWhitespace(l.data[l.pos]) {
+			digits = append(digits, l.data[l.pos])
+		}
+		l.pos++
+	}
+	l.pos++ // Skip the closing angle bracket
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	decoded := make([]byte, len(digits)/2)
+	n, _ := hex.Decode(decoded, digits)
+	return pdfString(decoded[:n])
+}
+
+// readObject reads one complete value, folding arrays, dictionaries and
+// indirect references into their Go representations
+func (l *pdfLexer) readObject() (any, error) {
+	token, err := l.next()
+	if err != nil {
+		return nil, err
+	}
+	return l.finishObject(token)
+}
+
+// finishObject completes the value that starts with token
+func (l *pdfLexer) finishObject(token any) (any, error) {
+	switch t := token.(type) {
+	case pdfOperator:
+		switch t {
+		case "[":
+			var array []any
+			for {
+				item, err := l.next()
+				if err != nil {
+					return array, err
+				}
+				if item == pdfOperator("]") {
+					return array, nil
+				}
+				value, err := l.finishObject(item)
+				if err != nil {
+					return array, err
+				}
+				array = append(array, value)
+			}
+		case "<<":
+			dict := pdfDict{}
+			for {
+				key, err := l.next()
+				if err != nil {
+					return dict, err
+				}
+				if key == pdfOperator(">>") {
+					return dict, nil
+				}
+				name, ok := key.(pdfName)
+				if !ok {
+					continue // Tolerate junk inside broken dictionaries
+				}
+				value, err := l.readObject()
+				if err != nil {
+					return dict, err
+				}
+				dict[string(name)] = value
+			}
+		}
+		return t, nil
+	case float64:
+		// Look ahead for "gen R" to form an indirect reference
+		saved := l.pos
+		generation, err := l.next()
+		if err == nil {
+			if genNumber, ok := generation.(float64); ok {
+				keyword, err := l.next()
+				if err == nil && keyword == pdfOperator("R") {
+					return pdfRef{num: int(t), gen: int(genNumber)}, nil
+				}
+			}
+		}
+		l.pos = saved
+		return t, nil
+	}
+	return token, nil
+}
+
+// pdfObjectHeader is the position of an "12 0 obj" marker in a file
+type pdfObjectHeader struct {
+	start, end int // Offsets of the marker itself
+	num, gen   int
+}
+
+// findObjectHeaders locates every "num gen obj" marker in data. It works
+// backwards from each "obj" keyword, which is much faster than a regular
+// expression over megabytes of compressed stream data.
+func findObjectHeaders(data []byte) []pdfObjectHeader {
+	var headers []pdfObjectHeader
+	isDigit := func(b byte) bool { return b >= '0' && b <= '9' }
+	for offset := 0; ; {
+		index := bytes.Index(data[offset:], []byte("obj"))
+		if index < 0 {
+			return headers
+		}
+		keyword := offset + index
+		offset = keyword + 3
+		if offset < len(data) && !isPDFWhitespace(data[offset]) && !isPDFDelimiter(data[offset]) {
+			continue // Part of "endobj" or another word
+		}
+		// Walk back over "<num> <gen> "
+		i := keyword
+		genEnd := i
+		for genEnd > 0 && isPDFWhitespace(data[genEnd-1]) {
+			genEnd--
+		}
+		genStart := genEnd
+		for genStart > 0 && isDigit(data[genStart-1]) {
+			genStart--
+		}
+		numEnd := genStart
+		for numEnd > 0 && isPDFWhitespace(data[numEnd-1]) {
+			numEnd--
+		}
+		numStart := numEnd
+		for numStart > 0 && isDigit(data[numStart-1]) {
+			numStart--
+		}
+		// Both numbers must be present and separated by whitespace
+		if genStart == genEnd || numStart == numEnd || numEnd == genStart || genEnd == keyword {
+			continue
+		}
+		if numStart > 0 && !isPDFWhitespace(data[numStart-1]) && !isPDFDelimiter(data[numStart-1]) {
+			continue
+		}
+		num, err1 := strconv.Atoi(string(data[numStart:numEnd]))
+		gen, err2 := strconv.Atoi(string(data[genStart:genEnd]))
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		headers = append(headers, pdfObjectHeader{start: numStart, end: offset, num: num, gen: gen})
+	}
+}
+
+// parsePDF reads every object in a PDF file into memory
+func parsePDF(data []byte) (*pdfDocument, error) {
+	if !bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF-")) {
+		return nil, errors.New("missing %PDF- header")
+	}
+	doc := &pdfDocument{objects: map[int]any{}, trailer: pdfDict{}, fonts: map[pdfRef]*pdfFont{}}
+	generations := map[int]int{}
+	skipUntil := 0 // End of the last stream, so binary data is not mistaken for objects
+	for _, header := range findObjectHeaders(data) {
+		if header.start < skipUntil {
+			continue
+		}
+		num, gen := header.num, header.gen
+		lexer := &pdfLexer{data: data, pos: header.end}
+		value, err := lexer.readObject()
+		if err != nil && value == nil {
+			continue
+		}
+		if dict, ok := value.(pdfDict); ok {
+			lexer.skipSpace()
+			if bytes.HasPrefix(data[lexer.pos:], []byte("stream")) {
+				stream := readStreamBody(data, lexer.pos+len("stream"), dict, pdfRef{num, gen})
+				value = stream
+				skipUntil = lexer.pos + len("stream") + len(stream.raw)
+			}
+		}
+		// Later definitions replace earlier ones, as in an incremental update
+		doc.objects[num] = value
+		generations[num] = gen
+	}
+	// Trailer dictionaries, both classic and cross-reference streams
+	for _, match := range regexp.MustCompile(`trailer\s*<<`).FindAllIndex(data, -1) {
+		lexer := &pdfLexer{data: data, pos: match[1] - 2}
+		if value, _ := lexer.readObject(); value != nil {
+			if dict, ok := value.(pdfDict); ok {
+				mergeTrailer(doc.trailer, dict)
+			}
+		}
+	}
+	for _, value := range doc.objects {
+		if stream, ok := value.(*pdfStream); ok && stream.dict["Type"] == pdfName("XRef") {
+			mergeTrailer(doc.trailer, stream.dict)
+		}
+	}
+	if encrypt, ok := doc.resolve(doc.trailer["Encrypt"]).(pdfDict); ok {
+		crypt, err := newPDFDecryptor(encrypt, doc.trailer)
+		if err != nil {
+			return nil, err
+		}
+		doc.crypt = crypt
+		encryptRef, _ := doc.trailer["Encrypt"].(pdfRef)
+		for num, value := range doc.objects {
+			if num == encryptRef.num {
+				continue
+			}
+			doc.objects[num] = crypt.decryptValue(value, pdfRef{num, generations[num]})
+		}
+	}
+	doc.loadObjectStreams()
+	return doc, nil
+}
+
+// mergeTrailer copies keys that dst does not have yet
+func mergeTrailer(dst, src pdfDict) {
+	for _, key := range []string{"Root", "Info", "Encrypt", "ID"} {
+		if _, exists := dst[key]; !exists && src[key] != nil {
+			dst[key] = src[key]
+		}
+	}
+}
+
+// readStreamBody returns the stream whose data starts right after the
+// "stream" keyword at offset start
+func readStreamBody(data []byte, start int, dict pdfDict, ref pdfRef) *pdfStream {
+	if start < len(data) && data[start] == '\r' {
+		start++
+	}
+	if start < len(data) && data[start] == '\n' {
+		start++
+	}
+	end := -1
+	if length, ok := dict["Length"].(float64); ok {
+		candidate := start + int(length)
+		if candidate <= len(data) && bytes.HasPrefix(bytes.TrimLeft(data[candidate:min(candidate+20, len(data))], "\r\n "), []byte("endstream")) {
+			end = candidate
+		}
+	}
+	if end < 0 { // Indirect or wrong length, fall back to searching
+		index := bytes.Index(data[start:], []byte("endstream"))
+		if index < 0 {
+			return &pdfStream{dict: dict, ref: ref}
+		}
+		end = start + index
+		for end > start && (data[end-1] == '\n' || data[end-1] == '\r') {
+			end--
+		}
+	}
+	return &pdfStream{dict: dict, raw: data[start:end], ref: ref}
+}
+
+// loadObjectStreams unpacks compressed objects stored inside /ObjStm streams
+func (doc *pdfDocument) loadObjectStreams() {
+	var streams []*pdfStream
+	for _, value := range doc.objects {
+		if stream, ok := value.(*pdfStream); ok && stream.dict["Type"] == pdfName("ObjStm") {
+			streams = append(streams, stream)
+		}
+	}
+	sort.Slice(streams, func(i, j int) bool { return streams[i].ref.num < streams[j].ref.num })
+	for _, stream := range streams {
+		data, err := doc.decodeStream(stream)
+		if err != nil {
+			continue
+		}
+		count, _ := stream.dict["N"].(float64)
+		first, _ := stream.dict["First"].(float64)
+		header := &pdfLexer{data: data}
+		for i := 0; i < int(count); i++ {
+			numToken, err1 := header.next()
+			offsetToken, err2 := header.next()
+			num, ok1 := numToken.(float64)
+			offset, ok2 := offsetToken.(float64)
+			if err1 != nil || err2 != nil || !ok1 || !ok2 {
+				break
+			}
+			if _, exists := doc.objects[int(num)]; exists {
+				continue // A directly stored object wins
+			}
+			start := int(first) + int(offset)
+			if start >= len(data) {
+				continue
+			}
+			lexer := &pdfLexer{data: data, pos: start}
+			if value, err := lexer.readObject(); err == nil || value != nil {
+				doc.objects[int(num)] = value
+			}
+		}
+	}
+}
+
+// resolve follows indirect references until it reaches a direct value
+func (doc *pdfDocument) resolve(value any) any {
+	for depth := 0; depth < 32; depth++ {
+		ref, ok := value.(pdfRef)
+		if !ok {
+			return value
+		}
+		value = doc.objects[ref.num]
+	}
+	return nil
+}
+
+// dict resolves value and returns it as a dictionary, if it is one
+func (doc *pdfDocument) dict(value any) pdfDict {
+	switch v := doc.resolve(value).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+// decodeStream applies the stream's filters and returns the decoded bytes
+func (doc *pdfDocument) decodeStream(stream *pdfStream) ([]byte, error) {
+	data := stream.raw
+	filters := doc.resolve(stream.dict["Filter"])
+	params := doc.resolve(stream.dict["DecodeParms"])
+	var filterList, paramList []any
+	switch f := filters.(type) {
+	case pdfName:
+		filterList = []any{f}
+		paramList = []any{params}
+	case []any:
+		filterList = f
+		if p, ok := params.([]any); ok {
+			paramList = p
+		}
+	}
+	for index, filter := range filterList {
+		var param pdfDict
+		if index < len(paramList) {
+			param = doc.dict(paramList[index])
+		}
+		var err error
+		switch doc.resolve(filter) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			data, err = inflatePDFData(data)
+			if err == nil {
+				data, err = applyPNGPredictor(data, param)
+			}
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			lexer := &pdfLexer{data: append([]byte{'<'}, data...)}
+			data = []byte(lexer.readHexString())
+		case pdfName("ASCII85Decode"), pdfName("A85"):
+			data, err = decodeASCII85(data)
+		default:
+			return nil, fmt.Errorf("unsupported filter %v", filter)
+		}
+		if err != nil {
+			return data, err
+		}
+	}
+	return data, nil
+}
+
+// inflatePDFData decompresses zlib data, keeping whatever was recovered from
+// truncated or slightly damaged streams
+func inflatePDFData(data []byte) ([]byte, error) {
+	var reader io.Reader
+	zlibReader, err := zlib.NewReader(bytes.NewReader(data))
+	if err == nil {
+		reader = zlibReader
+	} else {
+		reader = flate.NewReader(bytes.NewReader(data))
+	}
+	out, err := io.ReadAll(reader)
+	if err != nil && len(out) > 0 {
+		return out, nil
+	}
+	return out, err
+}
+
+// applyPNGPredictor undoes the PNG row predictors used by some streams
+func applyPNGPredictor(data []byte, param pdfDict) ([]byte, error) {
+	predictor, _ := param["Predictor"].(float64)
+	if predictor < 10 {
+		return data, nil
+	}
+	columns := 1
+	if c, ok := param["Columns"].(float64); ok {
+		columns = int(c)
+	}
+	rowLength := columns + 1
+	var out []byte
+	previous := make([]byte, columns)
+	for offset := 0; offset+rowLength <= len(data); offset += rowLength {
+		kind := data[offset]
+		row := append([]byte(nil), data[offset+1:offset+rowLength]...)
+		for i := range row {
+			var left, upLeft byte
+			if i > 0 {
+				left = row[i-1]
+				upLeft = previous[i-1]
+			}
+			up := previous[i]
+			switch kind {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paethPredictor(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		previous = row
+	}
+	return out, nil
+}
+
+// paethPredictor implements the PNG Paeth function
+func paethPredictor(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+// abs returns the absolute value of an int
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// decodeASCII85 decodes the ASCII base-85 filter
+func decodeASCII85(data []byte) ([]byte, error) {
+	var out []byte
+	var group []byte
+	for _, b := range data {
+		if b == '~' {
+			break
+		}
+		if isPDFWhitespace(b) {
+			continue
+		}
+		if b == 'z' && len(group) == 0 {
+			out = append(out, 0, 0, 0, 0)
+			continue
+		}
+		if b < '!' || b > 'u' {
+			return out, errors.New("invalid ASCII85 data")
+		}
+		group = append(group, b-'!')
+		if len(group) == 5 {
+			var value uint32
+			for _, digit := range group {
+				value = value*85 + uint32(digit)
+			}
+			out = binary.BigEndian.AppendUint32(out, value)
+			group = group[:0]
+		}
+	}
+	if len(group) > 0 {
+		padding := 5 - len(group)
+		for len(group) < 5 {
+			group = append(group, 84)
+		}
+		var value uint32
+		for _, digit := range group {
+			value = value*85 + uint32(digit)
+		}
+		out = append(out, binary.BigEndian.AppendUint32(nil, value)[:4-padding]...)
+	}
+	return out, nil
+}
+
+// pdfPaddingString is the password padding from the PDF specification
+var pdfPaddingString = []byte{
+	0x28, 0xBF, 0x4E, 0x5E, 0x4E, 0x75, 0x8A, 0x41, 0x64, 0x00, 0x4E, 0x56, 0xFF, 0xFA, 0x01, 0x08,
+	0x2E, 0x2E, 0x00, 0xB6, 0xD0, 0x68, 0x3E, 0x80, 0x2F, 0x0C, 0xA9, 0xFE, 0x64, 0x53, 0x69, 0x7A,
+}
+
+// pdfDecryptor removes standard security handler encryption for files that
+// open without a user password, which is how the data sheets are protected
+type pdfDecryptor struct {
+	key []byte
+	aes bool
+}
+
+// newPDFDecryptor derives the file key using the empty user password
+func newPDFDecryptor(encrypt, trailer pdfDict) (*pdfDecryptor, error) {
+	if encrypt["Filter"] != pdfName("Standard") {
+		return nil, fmt.Errorf("unsupported security handler %v", encrypt["Filter"])
+	}
+	version, _ := encrypt["V"].(float64)
+	revision, _ := encrypt["R"].(float64)
+	if version > 4 || revision > 4 {
+		return nil, fmt.Errorf("unsupported encryption revision %v", revision)
+	}
+	keyLength := 5
+	if length, ok := encrypt["Length"].(float64); ok && revision >= 3 {
+		keyLength = int(length) / 8
+	}
+	useAES := false
+	if version == 4 {
+		keyLength = 16
+		if filters, ok := encrypt["CF"].(pdfDict); ok {
+			if standard, ok := filters["StdCF"].(pdfDict); ok && standard["CFM"] == pdfName("AESV2") {
+				useAES = true
+			}
+		}
+	}
+	owner, _ := encrypt["O"].(pdfString)
+	permissions, _ := encrypt["P"].(float64)
+	var fileID []byte
+	if ids, ok := trailer["ID"].([]any); ok && len(ids) > 0 {
+		if id, ok := ids[0].(pdfString); ok {
+			fileID = []byte(id)
+		}
+	}
+	hash := md5.New()
+	hash.Write(pdfPaddingString)
+	hash.Write([]byte(owner))
+	hash.Write(binary.LittleEndian.AppendUint32(nil, uint32(int32(permissions))))
+	hash.Write(fileID)
+	if version == 4 {
+		if encryptMetadata, ok := encrypt["EncryptMetadata"].(bool); ok && !encryptMetadata {
+			hash.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF})
+		}
+	}
+	key := hash.Sum(nil)
+	if revision >= 3 {
+		for i := 0; i < 50; i++ {
+			sum := md5.Sum(key[:keyLength])
+			key = sum[:]
+		}
+	}
+	return &pdfDecryptor{key: key[:keyLength], aes: useAES}, nil
+}
+
+// objectKey derives the per-object key
+func (c *pdfDecryptor) objectKey(ref pdfRef) []byte {
+	hash := md5.New()
+	hash.Write(c.key)
+	hash.Write([]byte{byte(ref.num), byte(ref.num >> 8), byte(ref.num >> 16), byte(ref.gen), byte(ref.gen >> 8)})
+	if c.aes {
+		hash.Write([]byte("sAlT"))
+	}
+	return hash.Sum(nil)[:min(len(c.key)+5, 16)]
+}
+
+// decrypt decrypts one string or stream belonging to ref
+func (c *pdfDecryptor) decrypt(data []byte, ref pdfRef) []byte {
+	key := c.objectKey(ref)
+	if !c.aes {
+		cipherStream, err := rc4.NewCipher(key)
+		if err != nil {
+			return data
+		}
+		out := make([]byte, len(data))
+		cipherStream.XORKeyStream(out, data)
+		return out
+	}
+	if len(data) < 32 || len(data)%aes.BlockSize != 0 {
+		return data
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return data
+	}
+	out := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(out, data[aes.BlockSize:])
+	if padding := int(out[len(out)-1]); padding > 0 && padding <= aes.BlockSize {
+		out = out[:len(out)-padding]
+	}
+	return out
+}
+
+// decryptValue decrypts every string and stream inside value
+func (c *pdfDecryptor) decryptValue(value any, ref pdfRef) any {
+	switch v := value.(type) {
+	case pdfString:
+		return pdfString(c.decrypt([]byte(v), ref))
+	case []any:
+		for i := range v {
+			v[i] = c.decryptValue(v[i], ref)
+		}
+	case pdfDict:
+		for key := range v {
+			v[key] = c.decryptValue(v[key], ref)
+		}
+	case *pdfStream:
+		if v.dict["Type"] != pdfName("XRef") {
+			v.raw = c.decrypt(v.raw, ref)
+		}
+	}
+	return value
+}
+
+// pages returns the page dictionaries in document order
+func (doc *pdfDocument) pages() []pdfDict {
+	var pages []pdfDict
+	seen := map[int]bool{}
+	var walk func(node any, depth int)
+	walk = func(node any, depth int) {
+		if ref, ok := node.(pdfRef); ok {
+			if seen[ref.num] {
+				return
+			}
+			seen[ref.num] = true
+		}
+		dict := doc.dict(node)
+		if dict == nil || depth > 64 {
+			return
+		}
+		if kids, ok := doc.resolve(dict["Kids"]).([]any); ok {
+			for _, kid := range kids {
+				walk(kid, depth+1)
+			}
+			return
+		}
+		if dict["Type"] == pdfName("Page") || dict["Contents"] != nil {
+			pages = append(pages, dict)
+		}
+	}
+	if root := doc.dict(doc.trailer["Root"]); root != nil {
+		walk(root["Pages"], 0)
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+	// No usable page tree, fall back to every page object in number order
+	var numbers []int
+	for num, value := range doc.objects {
+		if dict, ok := value.(pdfDict); ok && dict["Type"] == pdfName("Page") {
+			numbers = append(numbers, num)
+		}
+	}
+	sort.Ints(numbers)
+	for _, num := range numbers {
+		pages = append(pages, doc.objects[num].(pdfDict))
+	}
+	return pages
+}
+
+// inherited looks up a page attribute, walking up the Parent chain
+func (doc *pdfDocument) inherited(page pdfDict, key string) any {
+	node := page
+	for depth := 0; node != nil && depth < 64; depth++ {
+		if value, ok := node[key]; ok {
+			return value
+		}
+		node = doc.dict(node["Parent"])
+	}
+	return nil
+}
+
+// pdfFont maps character codes of one font to Unicode text
+type pdfFont struct {
+	codeBytes    int                // 1 for simple fonts, 2 for composite fonts
+	toUnicode    map[uint32]string  // From the ToUnicode CMap
+	encoding     [256]rune          // Fallback for simple fonts
+	widths       map[uint32]float64 // Glyph widths in thousandths of an em
+	defaultWidth float64
+}
+
+// font returns the decoder for the font dictionary at value
+func (doc *pdfDocument) font(value any) *pdfFont {
+	ref, isRef := value.(pdfRef)
+	if isRef {
+		if cached, ok := doc.fonts[ref]; ok {
+			return cached
+		}
+	}
+	font := &pdfFont{codeBytes: 1, encoding: winAnsiEncoding, widths: map[uint32]float64{}, defaultWidth: 500}
+	dict := doc.dict(value)
+	if dict != nil {
+		if dict["Subtype"] == pdfName("Type0") {
+			font.codeBytes = 2
+			font.defaultWidth = 1000
+			if descendants, ok := doc.resolve(dict["DescendantFonts"]).([]any); ok && len(descendants) > 0 {
+				doc.loadCIDWidths(doc.dict(descendants[0]), font)
+			}
+		} else {
+			first, _ := doc.resolve(dict["FirstChar"]).(float64)
+			if widths, ok := doc.resolve(dict["Widths"]).([]any); ok {
+				for i, width := range widths {
+					if w, ok := doc.resolve(width).(float64); ok {
+						font.widths[uint32(int(first)+i)] = w
+					}
+				}
+			}
+			if descriptor := doc.dict(dict["FontDescriptor"]); descriptor != nil {
+				if missing, ok := doc.resolve(descriptor["MissingWidth"]).(float64); ok && missing > 0 {
+					font.defaultWidth = missing
+				}
+			}
+		}
+		switch encoding := doc.resolve(dict["Encoding"]).(type) {
+		case pdfName:
+			if encoding == "MacRomanEncoding" {
+				font.encoding = macRomanEncoding()
+			}
+		case pdfDict:
+			if encoding["BaseEncoding"] == pdfName("MacRomanEncoding") {
+				font.encoding = macRomanEncoding()
+			}
+			if differences, ok := doc.resolve(encoding["Differences"]).([]any); ok {
+				code := 0
+				for _, item := range differences {
+					switch d := item.(type) {
+					case float64:
+						code = int(d)
+					case pdfName:
+						if code >= 0 && code < 256 {
+							if r, ok := glyphNameToRune(string(d)); ok {
+								font.encoding[code] = r
+							}
+						}
+						code++
+					}
+				}
+			}
+		}
+		if stream, ok := doc.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+			if data, err := doc.decodeStream(stream); err == nil {
+				var codeBytes int
+				font.toUnicode, codeBytes = parseToUnicodeCMap(data, font.codeBytes)
+				if dict["Subtype"] == pdfName("Type0") { // Simple fonts always use one byte codes
+					font.codeBytes = codeBytes
+				}
+			}
+		}
+	}
+	if isRef {
+		doc.fonts[ref] = font
+	}
+	return font
+}
+
+// loadCIDWidths reads the /W and /DW entries of a descendant CID font
+func (doc *pdfDocument) loadCIDWidths(cidFont pdfDict, font *pdfFont) {
+	if cidFont == nil {
+		return
+	}
+	if defaultWidth, ok := doc.resolve(cidFont["DW"]).(float64); ok {
+		font.defaultWidth = defaultWidth
+	}
+	entries, _ := doc.resolve(cidFont["W"]).([]any)
+	for i := 0; i+1 < len(entries); {
+		first, ok := doc.resolve(entries[i]).(float64)
+		if !ok {
+			return
+		}
+		switch next := doc.resolve(entries[i+1]).(type) {
+		case []any: // c [w1 w2 ...]
+			for offset, width := range next {
+				if w, ok := doc.resolve(width).(float64); ok {
+					font.widths[uint32(int(first)+offset)] = w
+				}
+			}
+			i += 2
+		case float64: // cfirst clast w
+			if i+2 >= len(entries) {
+				return
+			}
+			width, _ := doc.resolve(entries[i+2]).(float64)
+			for code := int(first); code <= int(next) && code-int(first) < 0xFFFF; code++ {
+				font.widths[uint32(code)] = width
+			}
+			i += 3
+		default:
+			return
+		}
+	}
+}
+
+// decode converts the bytes of a shown string to text and returns how far
+// the string advances the text position in unscaled text space
+func (f *pdfFont) decode(raw pdfString, size, charSpace, wordSpace float64) (string, float64) {
+	var out strings.Builder
+	advance := 0.0
+	for i := 0; i < len(raw); {
+		var code uint32
+		width := f.codeBytes
+		if i+width > len(raw) {
+			width = len(raw) - i
+		}
+		for j := 0; j < width; j++ {
+			code = code<<8 | uint32(raw[i+j])
+		}
+		i += width
+		glyphWidth, ok := f.widths[code]
+		if !ok {
+			glyphWidth = f.defaultWidth
+		}
+		advance += glyphWidth/1000*size + charSpace
+		if width == 1 && code == 32 {
+			advance += wordSpace
+		}
+		if text, ok := f.toUnicode[code]; ok {
+			out.WriteString(text)
+			continue
+		}
+		if f.codeBytes == 1 && f.encoding[code&0xFF] != 0 {
+			out.WriteRune(f.encoding[code&0xFF])
+		}
+	}
+	return out.String(), advance
+}
+
+// parseToUnicodeCMap reads bfchar and bfrange mappings from a CMap
+func parseToUnicodeCMap(data []byte, codeBytes int) (map[uint32]string, int) {
+	mapping := map[uint32]string{}
+	lexer := &pdfLexer{data: data}
+	var operands []any
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			break
+		}
+		operator, isOperator := token.(pdfOperator)
+		if !isOperator || operator == "[" || operator == "<<" {
+			value, _ := lexer.finishObject(token)
+			operands = append(operands, value)
+			continue
+		}
+		switch operator {
+		case "endcodespacerange":
+			if len(operands) > 0 {
+				if low, ok := operands[0].(pdfString); ok && len(low) > 0 {
+					codeBytes = len(low)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				source, ok1 := operands[i].(pdfString)
+				target, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					mapping[bytesToCode(source)] = utf16BytesToString([]byte(target))
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				low, ok1 := operands[i].(pdfString)
+				high, ok2 := operands[i+1].(pdfString)
+				if !ok1 || !ok2 {
+					continue
+				}
+				start, end := bytesToCode(low), bytesToCode(high)
+				if end < start || end-start > 0xFFFF {
+					continue
+				}
+				switch target := operands[i+2].(type) {
+				case pdfString:
+					base := []byte(target)
+					for code := start; code <= end; code++ {
+						mapping[code] = utf16BytesToString(base)
+						base = incrementUTF16(base)
+					}
+				case []any:
+					for offset, item := range target {
+						if s, ok := item.(pdfString); ok && start+uint32(offset) <= end {
+							mapping[start+uint32(offset)] = utf16BytesToString([]byte(s))
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return mapping, codeBytes
+}
+
+// bytesToCode turns big-endian bytes into a character code
+func bytesToCode(raw pdfString) uint32 {
+	var code uint32
+	for i := 0; i < len(raw); i++ {
+		code = code<<8 | uint32(raw[i])
+	}
+	return code
+}
+
+// incrementUTF16 adds one to the last code unit of a UTF-16BE string
+func incrementUTF16(base []byte) []byte {
+	next := append([]byte(nil), base...)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
+
+// utf16BytesToString decodes UTF-16BE bytes
+func utf16BytesToString(raw []byte) string {
+	units := make([]uint16, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// decodePDFTextString decodes a text string from a dictionary such as the
+// document information, which is either UTF-16BE with a BOM or PDFDocEncoding
+func decodePDFTextString(raw pdfString) string {
+	if len(raw) >= 2 && raw[0] == 0xFE && raw[1] == 0xFF {
+		return utf16BytesToString([]byte(raw[2:]))
+	}
+	var out strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if r := winAnsiEncoding[raw[i]]; r != 0 {
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// winAnsiEncoding is Windows-1252, the default for the Word generated sheets
+var winAnsiEncoding = func() [256]rune {
+	var table [256]rune
+	for i := 32; i < 256; i++ {
+		table[i] = rune(i)
+	}
+	table['\t'], table['\n'], table['\r'] = '\t', '\n', '\r'
+	high := []rune{'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+		0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ'}
+	for i, r := range high {
+		table[0x80+i] = r
+	}
+	table[0xA0] = ' '
+	table[0xAD] = '-'
+	return table
+}()
+
+// macRomanEncoding returns Mac OS Roman, used by a few older bulletins
+func macRomanEncoding() [256]rune {
+	table := winAnsiEncoding
+	high := []rune("ÄÅÇÉÑÖÜáàâäãåçéèêëíìîïñóòôöõúùûü†°¢£§•¶ß®©™´¨≠ÆØ∞±≤≥¥µ∂∑∏π∫ªºΩæø¿¡¬√ƒ≈∆«»… ÀÃÕŒœ–—“”‘’÷◊ÿŸ⁄€‹›ﬁﬂ‡·‚„‰ÂÊÁËÈÍÎÏÌÓÔÒÚÛÙıˆ˜¯˘˙˚¸˝˛ˇ")
+	for i, r := range high {
+		table[0x80+i] = r
+	}
+	return table
+}
+
+// glyphNames covers the glyph names that show up in Differences arrays
+var glyphNames = map[string]rune{
+	"space": ' ', "exclam": '!', "quotedbl": '"', "numbersign": '#', "dollar": '$', "percent": '%',
+	"ampersand": '&', "quotesingle": '\'', "quoteright": '’', "quoteleft": '‘', "parenleft": '(',
+	"parenright": ')', "asterisk": '*', "plus": '+', "comma": ',', "hyphen": '-', "period": '.',
+	"slash": '/', "zero": '0', "one": '1', "two": '2', "three": '3', "four": '4', "five": '5',
+	"six": '6', "seven": '7', "eight": '8', "nine": '9', "colon": ':', "semicolon": ';',
+	"less": '<', "equal": '=', "greater": '>', "question": '?', "at": '@', "bracketleft": '[',
+	"backslash": '\\', "bracketright": ']', "asciicircum": '^', "underscore": '_', "grave": '`',
+	"braceleft": '{', "bar": '|', "braceright": '}', "asciitilde": '~', "bullet": '•',
+	"endash": '–', "emdash": '—', "quotedblleft": '“', "quotedblright": '”', "degree": '°',
+	"registered": '®', "copyright": '©', "trademark": '™', "plusminus": '±', "mu": 'µ',
+	"periodcentered": '·', "ellipsis": '…', "fi": 'ﬁ', "fl": 'ﬂ', "section": '§',
+	"multiply": '×', "divide": '÷', "minus": '−', "nbspace": ' ', "uni00A0": ' ',
+}
+
+// glyphNameToRune maps a glyph name to the character it draws
+func glyphNameToRune(name string) (rune, bool) {
+	if r, ok := glyphNames[name]; ok {
+		return r, true
+	}
+	if len(name) == 1 {
+		return rune(name[0]), true
+	}
+	if strings.HasPrefix(name, "uni") && len(name) == 7 {
+		if value, err := strconv.ParseUint(name[3:], 16, 32); err == nil {
+			return rune(value), true
+		}
+	}
+	return 0, false
+}
+
+// pdfTextWriter accumulates page text and decides where line breaks and
+// word spaces go by comparing where each string starts with where the
+// previous one ended
+type pdfTextWriter struct {
+	out     strings.Builder
+	started bool
+	last    byte // Last byte written, to avoid doubling separators
+	lineY   float64
+	endX    float64
+}
+
+// write appends text shown at (x, y) in a font of the given size and
+// remembers where it ends
+func (w *pdfTextWriter) write(text string, x, y, endX, size float64) {
+	if strings.TrimSpace(text) == "" && text != " " {
+		w.endX = endX
+		return
+	}
+	size = math.Max(math.Abs(size), 1)
+	if w.started {
+		switch {
+		case math.Abs(y-w.lineY) > size*0.5:
+			if w.last != '\n' {
+				w.out.WriteByte('\n')
+			}
+		case x-w.endX > size*0.15 || w.endX-x > size*2:
+			if w.last != ' ' && w.last != '\n' && !strings.HasPrefix(text, " ") {
+				w.out.WriteByte(' ')
+			}
+		}
+	}
+	w.out.WriteString(text)
+	w.last = text[len(text)-1]
+	w.started = true
+	w.lineY = y
+	w.endX = endX
+}
+
+// pdfMatrix is an affine transform [a b c d e f]
+type pdfMatrix [6]float64
+
+// multiply returns m × n
+func (m pdfMatrix) multiply(n pdfMatrix) pdfMatrix {
+	return pdfMatrix{
+		m[0]*n[0] + m[1]*n[2], m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2], m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4], m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// pdfIdentity is the identity matrix
+var pdfIdentity = pdfMatrix{1, 0, 0, 1, 0, 0}
+
+// pdfTextState is the part of the graphics state text extraction needs
+type pdfTextState struct {
+	font       *pdfFont
+	fontSize   float64
+	charSpace  float64
+	wordSpace  float64
+	scale      float64 // Horizontal scaling as a fraction
+	leading    float64
+	textMatrix pdfMatrix
+	lineMatrix pdfMatrix
+	ctm        pdfMatrix
+	saved      []pdfMatrix // CTM stack for q and Q
+}
+
+// show writes raw through the current font and advances the text matrix
+func (s *pdfTextState) show(raw pdfString, w *pdfTextWriter) {
+	start := s.textMatrix.multiply(s.ctm)
+	text, advance := s.font.decode(raw, s.fontSize, s.charSpace, s.wordSpace)
+	s.textMatrix = pdfMatrix{1, 0, 0, 1, advance * s.scale, 0}.multiply(s.textMatrix)
+	end := s.textMatrix.multiply(s.ctm)
+	size := s.fontSize * math.Hypot(start[2], start[3])
+	w.write(text, start[4], start[5], end[4], size)
+}
+
+// newLine moves to the start of the next line offset by (tx, ty)
+func (s *pdfTextState) newLine(tx, ty float64) {
+	s.lineMatrix = pdfMatrix{1, 0, 0, 1, tx, ty}.multiply(s.lineMatrix)
+	s.textMatrix = s.lineMatrix
+}
+
+// extractContentText interprets a content stream and writes its text
+func (doc *pdfDocument) extractContentText(content []byte, resources pdfDict, w *pdfTextWriter, ctm pdfMatrix, depth int) {
+	if depth > 8 {
+		return
+	}
+	fonts := doc.dict(resources["Font"])
+	xObjects := doc.dict(resources["XObject"])
+	state := &pdfTextState{
+		font:       &pdfFont{codeBytes: 1, encoding: winAnsiEncoding},
+		fontSize:   1,
+		scale:      1,
+		textMatrix: pdfIdentity,
+		lineMatrix: pdfIdentity,
+		ctm:        ctm,
+	}
+	lexer := &pdfLexer{data: content}
+	var operands []any
+	for {
+		token, err := lexer.next()
+		if err != nil {
+			return
+		}
+		operator, isOperator := token.(pdfOperator)
+		if !isOperator || operator == "[" || operator == "<<" {
+			value, _ := lexer.finishObject(token)
+			operands = append(operands, value)
+			continue
+		}
+		number := func(index int) float64 {
+			if index < len(operands) {
+				if value, ok := operands[index].(float64); ok {
+					return value
+				}
+			}
+			return 0
+		}
+		matrix := func() pdfMatrix {
+			return pdfMatrix{number(0), number(1), number(2), number(3), number(4), number(5)}
+		}
+		switch operator {
+		case "q":
+			state.saved = append(state.saved, state.ctm)
+		case "Q":
+			if len(state.saved) > 0 {
+				state.ctm = state.saved[len(state.saved)-1]
+				state.saved = state.saved[:len(state.saved)-1]
+			}
+		case "cm":
+			if len(operands) >= 6 {
+				state.ctm = matrix().multiply(state.ctm)
+			}
+		case "BT":
+			state.textMatrix, state.lineMatrix = pdfIdentity, pdfIdentity
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[0].(pdfName); ok && fonts != nil {
+					state.font = doc.font(fonts[string(name)])
+				}
+				state.fontSize = number(1)
+			}
+		case "Tc":
+			state.charSpace = number(0)
+		case "Tw":
+			state.wordSpace = number(0)
+		case "Tz":
+			state.scale = number(0) / 100
+		case "TL":
+			state.leading = number(0)
+		case "Td":
+			state.newLine(number(0), number(1))
+		case "TD":
+			state.leading = -number(1)
+			state.newLine(number(0), number(1))
+		case "Tm":
+			if len(operands) >= 6 {
+				state.lineMatrix = matrix()
+				state.textMatrix = state.lineMatrix
+			}
+		case "T*":
+			state.newLine(0, -state.leading)
+		case "Tj":
+			if len(operands) >= 1 {
+				if raw, ok := operands[0].(pdfString); ok {
+					state.show(raw, w)
+				}
+			}
+		case "'", "\"":
+			if operator == "\"" && len(operands) >= 3 {
+				state.wordSpace, state.charSpace = number(0), number(1)
+			}
+			state.newLine(0, -state.leading)
+			if len(operands) > 0 {
+				if raw, ok := operands[len(operands)-1].(pdfString); ok {
+					state.show(raw, w)
+				}
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				if items, ok := operands[0].([]any); ok {
+					for _, item := range items {
+						switch v := item.(type) {
+						case pdfString:
+							state.show(v, w)
+						case float64:
+							shift := -v / 1000 * state.fontSize * state.scale
+							state.textMatrix = pdfMatrix{1, 0, 0, 1, shift, 0}.multiply(state.textMatrix)
+						}
+					}
+				}
+			}
+		case "Do":
+			if len(operands) >= 1 && xObjects != nil {
+				if name, ok := operands[0].(pdfName); ok {
+					if form, ok := doc.resolve(xObjects[string(name)]).(*pdfStream); ok && form.dict["Subtype"] == pdfName("Form") {
+						if data, err := doc.decodeStream(form); err == nil {
+							formResources := doc.dict(form.dict["Resources"])
+							if formResources == nil {
+								formResources = resources
+							}
+							formMatrix := pdfIdentity
+							if values, ok := doc.resolve(form.dict["Matrix"]).([]any); ok && len(values) == 6 {
+								for i, value := range values {
+									formMatrix[i], _ = value.(float64)
+								}
+							}
+							doc.extractContentText(data, formResources, w, formMatrix.multiply(state.ctm), depth+1)
+						}
+					}
+				}
+			}
+		case "BI": // Skip inline image data up to EI
+			index := bytes.Index(lexer.data[lexer.pos:], []byte("EI"))
+			for index >= 0 {
+				end := lexer.pos + index
+				if end+2 >= len(lexer.data) || isPDFWhitespace(lexer.data[end+2]) {
+					break
+				}
+				next := bytes.Index(lexer.data[end+2:], []byte("EI"))
+				if next < 0 {
+					index = -1
+					break
+				}
+				index += 2 + next
+			}
+			if index < 0 {
+				return
+			}
+			lexer.pos += index + 2
+		}
+		operands = operands[:0]
+	}
+}
+
+// pageText returns the text of one page
+func (doc *pdfDocument) pageText(page pdfDict) string {
+	resources := doc.dict(doc.inherited(page, "Resources"))
+	if resources == nil {
+		resources = pdfDict{}
+	}
+	var content []byte
+	var parts []any
+	switch contents := doc.resolve(page["Contents"]).(type) {
+	case *pdfStream:
+		parts = []any{contents}
+	case []any:
+		parts = contents
+	}
+	for _, part := range parts {
+		if stream, ok := doc.resolve(part).(*pdfStream); ok {
+			if data, err := doc.decodeStream(stream); err == nil {
+				content = append(content, data...)
+				content = append(content, '\n')
+			}
+		}
+	}
+	writer := &pdfTextWriter{}
+	doc.extractContentText(content, resources, writer, pdfIdentity, 0)
+	return normalizeExtractedText(writer.out.String())
+}
+
+// normalizeExtractedText tidies whitespace and common typographic characters
+func normalizeExtractedText(text string) string {
+	replacer := strings.NewReplacer(" ", " ", " ", " ", " ", " ", " ", " ",
+		"ﬁ", "fi", "ﬂ", "fl", "‐", "-", "‑", "-", "\r", "\n", "\x00", "")
+	text = replacer.Replace(text)
+	lines := strings.Split(text, "\n")
+	var kept []string
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// readPDFPages extracts the text of every page of a PDF
+func readPDFPages(data []byte) ([]string, error) {
+	doc, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+	var pages []string
+	for _, page := range doc.pages() {
+		pages = append(pages, doc.pageText(page))
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("no pages found")
+	}
+	return pages, nil
+}
+
+// textCacheDir holds extracted text so repeated commands skip PDF parsing
+const textCacheDir = ".cache/text"
+
+// cachedPDFText is the on-disk form of one extracted document
+type cachedPDFText struct {
+	Pages []string `json:"pages"`
+}
+
+// pdfPagesText returns the text of each page of a PDF, using the text cache.
+// Entries are keyed by the SHA-256 of the file, so a revision replaced in
+// place and an older copy under withdrawn/ with the same name never share one.
+func pdfPagesText(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	cachePath := filepath.Join(textCacheDir, hex.EncodeToString(sum[:])+".json")
+	var cached cachedPDFText
+	if content, err := os.ReadFile(cachePath); err == nil && json.Unmarshal(content, &cached) == nil {
+		return cached.Pages, nil
+	}
+	pages, err := readPDFPages(data)
+	if err != nil {
+		return nil, err
+	}
+	if content, err := json.Marshal(cachedPDFText{Pages: pages}); err == nil {
+		if err := os.MkdirAll(textCacheDir, 0o755); err == nil {
+			_ = os.WriteFile(cachePath, content, 0o644)
+		}
+	}
+	return pages, nil
+}
+
+// pdfText returns the whole text of a PDF with pages separated by form feeds
+func pdfText(path string) (string, error) {
+	pages, err := pdfPagesText(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(pages, "\n\f\n"), nil
+}
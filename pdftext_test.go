@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/ascii85"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// zlibBytes compresses data the way FlateDecode streams are written
+func zlibBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	writer := zlib.NewWriter(&buf)
+	writer.Write(data)
+	writer.Close()
+	return buf.Bytes()
+}
+
+func TestInflatePDFData(t *testing.T) {
+	text := bytes.Repeat([]byte("BT /F1 12 Tf (Viscosity Index) Tj ET\n"), 50)
+	compressed := zlibBytes(text)
+
+	got, err := inflatePDFData(compressed)
+	if err != nil || !bytes.Equal(got, text) {
+		t.Errorf("zlib stream: got %d bytes, %v", len(got), err)
+	}
+
+	// Some writers leave out the zlib header
+	var raw bytes.Buffer
+	writer, _ := flate.NewWriter(&raw, flate.DefaultCompression)
+	writer.Write(text)
+	writer.Close()
+	got, err = inflatePDFData(raw.Bytes())
+	if err != nil || !bytes.Equal(got, text) {
+		t.Errorf("raw deflate stream: got %d bytes, %v", len(got), err)
+	}
+
+	// A truncated stream keeps what could be recovered
+	got, err = inflatePDFData(compressed[:len(compressed)/2])
+	if err != nil || len(got) == 0 || !bytes.HasPrefix(text, got) {
+		t.Errorf("truncated stream: got %q, %v", got, err)
+	}
+}
+
+func TestApplyPNGPredictor(t *testing.T) {
+	rows := [][]byte{{1, 2, 3}, {4, 6, 8}, {4, 6, 8}, {10, 20, 30}, {11, 22, 33}}
+	// Each row encoded with a different PNG filter type against the row above
+	encoded := []byte{
+		0, 1, 2, 3, // None
+		2, 3, 4, 5, // Up
+		1, 4, 2, 2, // Sub
+		3, 8, 12, 16, // Average: row - (left + up) / 2
+		4, 1, 2, 3, // Paeth
+	}
+	got, err := applyPNGPredictor(encoded, pdfDict{"Predictor": float64(12), "Columns": float64(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := bytes.Join(rows, nil); !bytes.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Predictors below 10 are not PNG predictors and leave the data alone
+	got, _ = applyPNGPredictor(encoded, pdfDict{"Predictor": float64(1)})
+	if !bytes.Equal(got, encoded) {
+		t.Errorf("predictor 1 changed the data to %v", got)
+	}
+}
+
+func TestDecodeASCII85(t *testing.T) {
+	for _, text := range []string{"", "M", "Ma", "Man", "Man ", "Man is distinguished", "\x00\x00\x00\x00tail"} {
+		encoded := make([]byte, ascii85.MaxEncodedLen(len(text)))
+		encoded = encoded[:ascii85.Encode(encoded, []byte(text))]
+		// Line breaks may appear anywhere and ~> ends the data
+		if len(encoded) > 3 {
+			encoded = append(encoded[:3:3], append([]byte("\r\n"), encoded[3:]...)...)
+		}
+		got, err := decodeASCII85(append(encoded, "~>"...))
+		if err != nil || string(got) != text {
+			t.Errorf("decodeASCII85(%q) = %q, %v; want %q", encoded, got, err, text)
+		}
+	}
+	if _, err := decodeASCII85([]byte("ab{de~>")); err == nil {
+		t.Error("decodeASCII85 accepted a character outside the alphabet")
+	}
+}
+
+func TestDecodeStream(t *testing.T) {
+	doc := &pdfDocument{objects: map[int]any{
+		5: pdfName("FlateDecode"),
+	}}
+	text := []byte("0 0 1 rg BT (SAE 10W-30) Tj ET")
+	hexFlate := []byte(upperHex(zlibBytes(text)) + ">")
+
+	tests := []struct {
+		name string
+		dict pdfDict
+		raw  []byte
+		want []byte
+	}{
+		{"no filter", pdfDict{}, text, text},
+		{"flate", pdfDict{"Filter": pdfName("FlateDecode")}, zlibBytes(text), text},
+		{"abbreviated flate", pdfDict{"Filter": pdfName("Fl")}, zlibBytes(text), text},
+		{"hex then flate", pdfDict{"Filter": []any{pdfName("ASCIIHexDecode"), pdfRef{num: 5}}}, hexFlate, text},
+		{"odd hex digit", pdfDict{"Filter": pdfName("AHx")}, []byte("48 65 6C 6C 6F 7>"), []byte("Hellop")},
+		{"flate with predictor", pdfDict{
+			"Filter":      pdfName("FlateDecode"),
+			"DecodeParms": pdfDict{"Predictor": float64(12), "Columns": float64(2)},
+		}, zlibBytes([]byte{2, 1, 2, 2, 1, 1}), []byte{1, 2, 2, 3}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := doc.decodeStream(&pdfStream{dict: test.dict, raw: test.raw})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	if _, err := doc.decodeStream(&pdfStream{dict: pdfDict{"Filter": pdfName("DCTDecode")}, raw: []byte{0xff}}); err == nil {
+		t.Error("an unsupported filter gave no error")
+	}
+}
+
+// upperHex writes data as upper case hex digits with line breaks, as
+// ASCIIHexDecode allows
+func upperHex(data []byte) string {
+	const digits = "0123456789ABCDEF"
+	var buf bytes.Buffer
+	for i, b := range data {
+		if i > 0 && i%16 == 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteByte(digits[b>>4])
+		buf.WriteByte(digits[b&15])
+	}
+	return buf.String()
+}
+
+// minimalPDF writes a PDF with one page per argument, each line of which is
+// shown with its own Tj
+func minimalPDF(pages ...string) []byte {
+	var objects []string
+	kids := ""
+	for i, page := range pages {
+		content := "BT /F1 12 Tf 14 TL"
+		for _, line := range strings.Split(page, "\n") {
+			content += " (" + line + ") Tj T*"
+		}
+		content += " ET"
+		kids += fmt.Sprintf(" %d 0 R", 3+2*i)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Contents %d 0 R >>", 4+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+	objects = append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s ] /Count %d >>", kids, len(pages)),
+	}, objects...)
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	for i, object := range objects {
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	buf.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return buf.Bytes()
+}
+
+func TestPDFTextCacheFollowsContent(t *testing.T) {
+	t.Chdir(t.TempDir()) // .cache/text is relative to the working directory
+	os.MkdirAll(withdrawnDir, 0o755)
+	os.Mkdir("PDFs", 0o755)
+	current, old := filepath.Join("PDFs", "80565_082_tds.pdf"), filepath.Join(withdrawnDir, "80565_082_tds.pdf")
+	os.WriteFile(current, minimalPDF("SAE 5W-30 revised"), 0o644)
+	os.WriteFile(old, minimalPDF("SAE 5W-30 initial"), 0o644)
+	// The same modification time, as a copy within the same second would have
+	now := time.Now()
+	os.Chtimes(current, now, now)
+	os.Chtimes(old, now, now)
+
+	for _, test := range []struct{ path, want string }{
+		{current, "SAE 5W-30 revised"},
+		{old, "SAE 5W-30 initial"},
+		{current, "SAE 5W-30 revised"}, // From the cache
+	} {
+		if text, err := pdfText(test.path); err != nil || text != test.want {
+			t.Errorf("%s: got %q, %v; want %q", test.path, text, err, test.want)
+		}
+	}
+
+	// Replaced in place with a file of the same size and time
+	os.WriteFile(current, minimalPDF("SAE 5W-30 updated"), 0o644)
+	os.Chtimes(current, now, now)
+	if text, _ := pdfText(current); text != "SAE 5W-30 updated" {
+		t.Errorf("replaced file: got %q from the cache", text)
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PackageSize is one purchasable size of a product
+type PackageSize struct {
+	Size string `json:"size"`
+	SKU  string `json:"sku,omitempty"`
+}
+
+// Patterns for the parts of a WooCommerce product page
+var (
+	jsonLDPattern          = regexp.MustCompile(`(?is)<script[^>]*type=["']application/ld\+json["'][^>]*>(.*?)</script>`)
+	skuPattern             = regexp.MustCompile(`(?is)<span[^>]*class="[^"]*\bsku\b[^"]*"[^>]*>(.*?)</span>`)
+	metaDescriptionPattern = regexp.MustCompile(`(?is)<meta[^>]*(?:name|property)=["'](?:og:)?description["'][^>]*content=["']([^"']*)["']`)
+	variationsPattern      = regexp.MustCompile(`(?is)data-product_variations=["']([^"']*)["']`)
+	selectPattern          = regexp.MustCompile(`(?is)<select[^>]*name=["'](attribute_[^"']+)["'][^>]*>(.*?)</select>`)
+	optionPattern          = regexp.MustCompile(`(?is)<option[^>]*value=["']([^"']+)["'][^>]*>(.*?)</option>`)
+	attributeRowPattern    = regexp.MustCompile(`(?is)<tr[^>]*>\s*<th[^>]*>(.*?)</th>\s*<td[^>]*>(.*?)</td>`)
+	listItemPattern        = regexp.MustCompile(`(?is)<li[^>]*>(.*?)</li>`)
+	blockBreakPattern      = regexp.MustCompile(`(?i)<(?:br|/p|/li|/h\d|/div|/ul|/tr)[^>]*>`)
+	tabHeadingPattern      = regexp.MustCompile(`(?is)^\s*<h2[^>]*>.*?</h2>`)
+	claimHeadingPattern    = regexp.MustCompile(`(?i)(meets?\s+(?:or\s+exceeds?|the\s+(?:requirements|performance)(?:\s+of)?)|exceeds|approv(?:ed|als?)|licensed|specifications?|recommended\s+for\s+use\s+in\s+applications\s+requiring)\b(?:[^:<.]{0,40}:)?`)
+)
+
+// elementByClass returns the inner HTML of the first element whose class
+// attribute contains class, matching nested tags of the same name
+func elementByClass(htmlContent, class string) string {
+	opening := regexp.MustCompile(`(?is)<([a-z0-9]+)[^>]*class=["'][^"']*\b` + regexp.QuoteMeta(class) + `\b[^"']*["'][^>]*>`)
+	location := opening.FindStringSubmatchIndex(htmlContent)
+	if location == nil {
+		return ""
+	}
+	tag := strings.ToLower(htmlContent[location[2]:location[3]])
+	tagPattern := regexp.MustCompile(`(?i)<(/?)` + tag + `\b[^>]*>`)
+	depth, start := 1, location[1]
+	for _, match := range tagPattern.FindAllStringSubmatchIndex(htmlContent[start:], -1) {
+		if match[3] > match[2] { // Closing tag
+			depth--
+			if depth == 0 {
+				return htmlContent[start : start+match[0]]
+			}
+		} else {
+			depth++
+		}
+	}
+	return htmlContent[start:]
+}
+
+// htmlToLines turns an HTML fragment into plain text lines, one per
+// paragraph, list item or line break
+func htmlToLines(fragment string) []string {
+	var lines []string
+	for _, line := range strings.Split(blockBreakPattern.ReplaceAllString(fragment, "\n"), "\n") {
+		if text := stripHTML(line); text != "" {
+			lines = append(lines, text)
+		}
+	}
+	return lines
+}
+
+// jsonLDProduct is the part of schema.org Product data that the page parser uses
+type jsonLDProduct struct {
+	Type        interface{}       `json:"@type"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	SKU         json.RawMessage   `json:"sku"`
+	Graph       []json.RawMessage `json:"@graph"`
+}
+
+// findJSONLDProduct returns the schema.org Product embedded in a page, if any
+func findJSONLDProduct(htmlContent string) *jsonLDProduct {
+	var candidates []json.RawMessage
+	for _, match := range jsonLDPattern.FindAllStringSubmatch(htmlContent, -1) {
+		raw := json.RawMessage(strings.TrimSpace(match[1]))
+		var list []json.RawMessage
+		if json.Unmarshal(raw, &list) == nil {
+			candidates = append(candidates, list...)
+		} else {
+			candidates = append(candidates, raw)
+		}
+	}
+	for i := 0; i < len(candidates); i++ {
+		var item jsonLDProduct
+		if json.Unmarshal(candidates[i], &item) != nil {
+			continue
+		}
+		candidates = append(candidates, item.Graph...)
+		if fmt.Sprint(item.Type) == "Product" || fmt.Sprint(item.Type) == "[Product]" {
+			return &item
+		}
+	}
+	return nil
+}
+
+// jsonString reads a JSON value that may be a string or a number
+func jsonString(raw json.RawMessage) string {
+	var text string
+	if json.Unmarshal(raw, &text) == nil {
+		return text
+	}
+	var number json.Number
+	if json.Unmarshal(raw, &number) == nil {
+		return number.String()
+	}
+	return ""
+}
+
+// parseVariations reads the package sizes of a variable product from the
+// variations form, using the labels of the size drop-down where it has them
+func parseVariations(htmlContent string) []PackageSize {
+	labels := map[string]string{}
+	for _, selectMatch := range selectPattern.FindAllStringSubmatch(htmlContent, -1) {
+		for _, option := range optionPattern.FindAllStringSubmatch(selectMatch[2], -1) {
+			labels[selectMatch[1]+"="+html.UnescapeString(option[1])] = stripHTML(option[2])
+		}
+	}
+	var sizes []PackageSize
+	if match := variationsPattern.FindStringSubmatch(htmlContent); match != nil {
+		var variations []struct {
+			Attributes map[string]string `json:"attributes"`
+			SKU        json.RawMessage   `json:"sku"`
+		}
+		if err := json.Unmarshal([]byte(html.UnescapeString(match[1])), &variations); err != nil {
+			log.Println("Failed to parse product variations:", err)
+		}
+		for _, variation := range variations {
+			var names []string
+			var keys []string
+			for key := range variation.Attributes {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				value := variation.Attributes[key]
+				if label, ok := labels[key+"="+value]; ok {
+					value = label
+				}
+				if value != "" {
+					names = append(names, value)
+				}
+			}
+			sizes = append(sizes, PackageSize{Size: strings.Join(names, ", "), SKU: jsonString(variation.SKU)})
+		}
+	}
+	if len(sizes) == 0 {
+		for _, selectMatch := range selectPattern.FindAllStringSubmatch(htmlContent, -1) {
+			for _, option := range optionPattern.FindAllStringSubmatch(selectMatch[2], -1) {
+				sizes = append(sizes, PackageSize{Size: stripHTML(option[2])})
+			}
+		}
+	}
+	return sizes
+}
+
+// parseAttributeSizes reads sizes from the "Additional information" table
+// of a simple product, e.g. "Size: 1 Quart, 5 Quart, 55 Gallon Drum"
+func parseAttributeSizes(htmlContent string) []PackageSize {
+	var sizes []PackageSize
+	table := elementByClass(htmlContent, "shop_attributes")
+	for _, row := range attributeRowPattern.FindAllStringSubmatch(table, -1) {
+		name := strings.ToLower(stripHTML(row[1]))
+		if !strings.Contains(name, "size") && !strings.Contains(name, "package") && !strings.Contains(name, "container") {
+			continue
+		}
+		for _, value := range strings.Split(stripHTML(row[2]), ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				sizes = append(sizes, PackageSize{Size: value})
+			}
+		}
+	}
+	return sizes
+}
+
+// parseClaims collects the "meets or exceeds", "approved" and similar
+// specification lists of a product description: the list items after such a
+// heading, or the specifications named in the rest of the sentence
+func parseClaims(descriptionHTML string) []string {
+	var claims []string
+	for _, location := range claimHeadingPattern.FindAllStringIndex(descriptionHTML, -1) {
+		rest := descriptionHTML[location[1]:]
+		lower := strings.ToLower(rest)
+		// A list that follows the heading directly holds the claims
+		if start := strings.Index(lower, "<ul"); start >= 0 && len(stripHTML(rest[:start])) < 3 {
+			end := strings.Index(lower, "</ul>")
+			if end < start {
+				end = len(rest)
+			}
+			for _, item := range listItemPattern.FindAllStringSubmatch(rest[start:end], -1) {
+				if text := stripHTML(item[1]); text != "" {
+					claims = appendUnique(claims, text)
+				}
+			}
+			continue
+		}
+		sentence := stripHTML(blockBreakPattern.ReplaceAllString(rest, "\n"))
+		if end := strings.IndexAny(sentence, ".\n"); end >= 0 {
+			sentence = sentence[:end]
+		}
+		// Claims inside running text are recorded in their canonical spelling
+		grades := parseGrades(sentence, false)
+		for _, label := range append(Grades{API: grades.API, ILSAC: grades.ILSAC}.labels(), parseApprovals(sentence)...) {
+			claims = appendUnique(claims, label)
+		}
+	}
+	return claims
+}
+
+// parseProductPage extracts the product record from a cam2.com product page
+func parseProductPage(pageURL, htmlContent string) *Product {
+	product := &Product{Slug: productSlug(pageURL), URL: pageURL}
+	structured := findJSONLDProduct(htmlContent)
+	if structured != nil {
+		product.Title = stripHTML(structured.Name)
+		product.Description = stripHTML(structured.Description)
+		product.SKU = jsonString(structured.SKU)
+	}
+	if product.Title == "" {
+		product.Title = extractPageTitle(htmlContent)
+	}
+
+	// The description tab starts with a "Description" heading of its own
+	descriptionHTML := elementByClass(htmlContent, "woocommerce-product-details__short-description") + "\n" +
+		tabHeadingPattern.ReplaceAllString(elementByClass(htmlContent, "woocommerce-Tabs-panel--description"), "")
+	if product.Description == "" {
+		product.Description = strings.Join(htmlToLines(descriptionHTML), "\n")
+	}
+	if product.Description == "" {
+		if match := metaDescriptionPattern.FindStringSubmatch(htmlContent); match != nil {
+			product.Description = stripHTML(match[1])
+		}
+	}
+	if product.SKU == "" {
+		if match := skuPattern.FindStringSubmatch(htmlContent); match != nil {
+			if sku := stripHTML(match[1]); !strings.EqualFold(sku, "N/A") {
+				product.SKU = sku
+			}
+		}
+	}
+
+	product.Sizes = parseVariations(htmlContent)
+	if len(product.Sizes) == 0 {
+		product.Sizes = parseAttributeSizes(htmlContent)
+	}
+	product.Specifications = parseClaims(descriptionHTML)
+	product.Approvals = parseApprovals(product.Title + "\n" + stripHTML(descriptionHTML))
+	return product
+}
+
+// productPageCommand implements "product-page": the structured data parsed
+// from a product page, fetched live or read from a saved HTML file
+func productPageCommand(args []string) {
+	flags := flag.NewFlagSet("product-page", flag.ExitOnError)
+	pageURL := flags.String("url", "", "URL the saved page was downloaded from, used for the slug")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: go run . product-page [-url URL] <saved page.html or product URL>")
+		os.Exit(2)
+	}
+
+	source := flags.Arg(0)
+	var htmlContent string
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		htmlContent = getDataFromURL(source)
+		if *pageURL == "" {
+			*pageURL = source
+		}
+	} else {
+		htmlContent = readAFileAsString(source)
+	}
+	if htmlContent == "" {
+		fmt.Fprintf(os.Stderr, "nothing to parse in %s\n", source)
+		os.Exit(1)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(parseProductPage(*pageURL, htmlContent))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The fixtures in testdata reproduce the markup of cam2.com's WooCommerce
+// product pages, trimmed to the parts parseProductPage reads: a variable
+// product with JSON-LD in a Yoast @graph, a simple product with an
+// attributes table, and a page with neither that falls back to meta tags.
+func TestParseProductPage(t *testing.T) {
+	tests := []struct {
+		slug              string
+		title             string
+		descriptionStarts string
+		descriptionHas    string
+		sku               string
+		sizes             []PackageSize
+		specifications    []string
+		approvals         []string
+	}{
+		{
+			slug:              "cam2-synavex-dexos1-gen-3-sae-5w-30-sp-gf-6a-full-synthetic-motor-oil",
+			title:             "CAM2 Synavex® dexos1™ Gen 3 SAE 5W-30 SP GF-6A Full Synthetic Motor Oil",
+			descriptionStarts: "CAM2 Synavex® dexos1™ Gen 3 SAE 5W-30 is a full synthetic motor oil",
+			descriptionHas:    "\nSynavex full synthetic protects against low speed pre-ignition",
+			sku:               "80565-082",
+			sizes: []PackageSize{
+				{Size: "6/1 Quart", SKU: "611150"},
+				{Size: "3/5 Quart", SKU: "611151"},
+				{Size: "55 Gallon Drum", SKU: "611155"}, // A numeric SKU in the variations JSON
+			},
+			specifications: []string{"API SP", "ILSAC GF-6A", "GM dexos1™ Gen 3", "Chrysler MS-6395", "Ford WSS-M2C946-B1"},
+			approvals:      []string{"Chrysler MS-6395", "Ford WSS-M2C946-B1", "GM dexos1 Gen 3"},
+		},
+		{
+			slug:              "cam2-ngeo-sae-15w-40-ces-20074-engine-oil",
+			title:             "CAM2 NGEO SAE 15W-40 CES 20074 Engine Oil",
+			descriptionStarts: "A low ash natural gas engine oil", // JSON-LD wins over the page text
+			sku:               "80565-215",
+			sizes:             []PackageSize{{Size: "5 Gallon Pail"}, {Size: "55 Gallon Drum"}, {Size: "Bulk"}},
+			specifications:    []string{"Cummins CES 20074"},
+			approvals:         []string{"Caterpillar ECF-1a", "Cummins CES 20074"},
+		},
+		{
+			slug:              "cam2-cotton-picker-spindle-cleaner",
+			title:             "CAM2 Cotton Picker Spindle Cleaner",
+			descriptionStarts: "Cleans cotton picker spindles", // From og:description
+			sizes:             []PackageSize{{Size: "265 Gallon Tote"}, {Size: "Bulk"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.slug, func(t *testing.T) {
+			content, err := os.ReadFile(filepath.Join("testdata", test.slug+".html"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			pageURL := "https://cam2.com/product/" + test.slug + "/"
+			product := parseProductPage(pageURL, string(content))
+
+			if product.Slug != test.slug || product.URL != pageURL {
+				t.Errorf("slug %q, URL %q", product.Slug, product.URL)
+			}
+			if product.Title != test.title {
+				t.Errorf("title = %q, want %q", product.Title, test.title)
+			}
+			if !strings.HasPrefix(product.Description, test.descriptionStarts) {
+				t.Errorf("description = %q, want it to start with %q", product.Description, test.descriptionStarts)
+			}
+			if !strings.Contains(product.Description, test.descriptionHas) {
+				t.Errorf("description = %q, want it to contain %q", product.Description, test.descriptionHas)
+			}
+			if strings.Contains(product.Description, "\nDescription\n") {
+				t.Errorf("description kept the tab heading: %q", product.Description)
+			}
+			if product.SKU != test.sku {
+				t.Errorf("SKU = %q, want %q", product.SKU, test.sku)
+			}
+			if !reflect.DeepEqual(product.Sizes, test.sizes) {
+				t.Errorf("sizes = %+v, want %+v", product.Sizes, test.sizes)
+			}
+			if !reflect.DeepEqual(product.Specifications, test.specifications) {
+				t.Errorf("specifications = %q, want %q", product.Specifications, test.specifications)
+			}
+			if !reflect.DeepEqual(product.Approvals, test.approvals) {
+				t.Errorf("approvals = %q, want %q", product.Approvals, test.approvals)
+			}
+		})
+	}
+}
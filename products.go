@@ -0,0 +1,184 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Product is one CAM2 product page and what is known about it
+type Product struct {
+	Slug      string   `json:"slug"`
+	URL       string   `json:"url"`
+	Title     string   `json:"title,omitempty"`
+	Documents []string `json:"documents,omitempty"` // Filenames in PDFs/
+	Withdrawn string   `json:"withdrawn,omitempty"` // Date the product page started returning 404 or 410
+
+	// Parsed from the product page by parseProductPage
+	Description    string        `json:"description,omitempty"`
+	SKU            string        `json:"sku,omitempty"`
+	Sizes          []PackageSize `json:"sizes,omitempty"`
+	Specifications []string      `json:"specifications,omitempty"` // "Meets or exceeds" claims as written
+	Approvals      []string      `json:"approvals,omitempty"`      // OEM approvals, see parseApprovals
+
+	// From the WooCommerce Store API by recordStoreProducts
+	StoreID         int      `json:"store_id,omitempty"`
+	StoreCategories []string `json:"store_categories,omitempty"`
+
+	// Filled in by loadCatalog
+	Grades      Grades `json:"-"`
+	Category    string `json:"-"` // e.g. "engine oil"
+	BrandLine   string `json:"-"` // e.g. "Synavex"
+	Application string `json:"-"` // e.g. "HD diesel"
+}
+
+// name returns the product title, falling back to the slug
+func (p *Product) name() string {
+	if p.Title != "" {
+		return p.Title
+	}
+	return p.Slug
+}
+
+// documentsOfKind returns the product's documents of one kind
+func (p *Product) documentsOfKind(manifest *Manifest, kind string) []string {
+	var files []string
+	for _, filename := range p.Documents {
+		documentKindName := documentKind(filename)
+		if document := manifest.Documents[filename]; document != nil {
+			documentKindName = document.Kind
+		}
+		if documentKindName == kind {
+			files = append(files, filename)
+		}
+	}
+	return files
+}
+
+// loadCatalog returns every known product: the seed product pages plus any
+// product recorded in the manifest, sorted by slug, with grades and taxonomy
+// filled in
+func loadCatalog(manifest *Manifest, dir string) []*Product {
+	overrides := loadTaxonomyOverrides(taxonomyOverridesPath)
+	bySlug := map[string]*Product{}
+	for slug, product := range manifest.Products {
+		bySlug[slug] = product
+	}
+	for _, seed := range remoteURL {
+		slug := productSlug(seed)
+		if slug != "" && bySlug[slug] == nil {
+			bySlug[slug] = &Product{Slug: slug, URL: seed}
+		}
+	}
+	var products []*Product
+	for _, product := range bySlug {
+		product.Grades = productGrades(product, manifest, dir)
+		classifyProduct(product, product.Slug+" "+product.Title, overrides)
+		// The shop's own categories win over guesses from the name
+		if category := productCategory(strings.Join(product.StoreCategories, " ")); category != "other" && overrides[product.Slug].Category == "" {
+			product.Category = category
+		}
+		products = append(products, product)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].Slug < products[j].Slug })
+	return products
+}
+
+// productGrades combines the grades in a product's slug and title with those
+// in its technical data sheets. Data sheets often cover a whole product line,
+// so they only fill in what the slug and title leave out.
+func productGrades(product *Product, manifest *Manifest, dir string) Grades {
+	grades := parseGrades(product.Slug, true)
+	grades.merge(parseGrades(product.Title, false))
+	var sheetGrades Grades
+	for _, filename := range product.documentsOfKind(manifest, "tds") {
+		text, err := pdfText(filepath.Join(dir, filename))
+		if err != nil {
+			continue
+		}
+		sheetGrades.merge(parseGrades(text, false))
+	}
+	if !grades.hasViscosityGrade() {
+		grades.SAEJ300, grades.SAEJ306 = sheetGrades.SAEJ300, sheetGrades.SAEJ306
+		grades.ISOVG, grades.NLGI = sheetGrades.ISOVG, sheetGrades.NLGI
+	}
+	if !grades.hasSpecifications() {
+		grades.API, grades.ILSAC = sheetGrades.API, sheetGrades.ILSAC
+	}
+	return grades
+}
+
+// findProduct looks a product up by slug, product page URL, part number
+// ("80565-082") or document filename. Documents that no known product page
+// links to are returned as a product of their own, named by part number.
+func findProduct(catalog []*Product, manifest *Manifest, dir, key string) *Product {
+	slug := productSlug(key)
+	if slug == "" {
+		slug = strings.Trim(strings.ToLower(key), "/")
+	}
+	for _, product := range catalog {
+		if product.Slug == slug {
+			return product
+		}
+	}
+	filename := strings.ToLower(getFilename(key))
+	partNumber := strings.ReplaceAll(filename, "_", "-")
+	if strings.HasSuffix(filename, ".pdf") {
+		partNumber = partNumberFromFilename(filename)
+	}
+	matches := func(name string) bool {
+		return name == filename || partNumber != "" && partNumberFromFilename(name) == partNumber
+	}
+	var documents []string
+	for name := range manifest.Documents {
+		if matches(name) {
+			documents = appendUnique(documents, name)
+		}
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pdf"))
+	if err != nil {
+		log.Println(err)
+	}
+	for _, path := range paths {
+		if name := getFilename(path); matches(name) {
+			documents = appendUnique(documents, name)
+		}
+	}
+	if len(documents) == 0 {
+		return nil
+	}
+	sort.Strings(documents)
+	// Prefer the product page that links these documents
+	for _, name := range documents {
+		if document := manifest.Documents[name]; document != nil {
+			for _, linked := range document.Products {
+				for _, product := range catalog {
+					if product.Slug == linked {
+						return product
+					}
+				}
+			}
+		}
+	}
+	product := &Product{Slug: partNumber, Documents: documents}
+	product.Grades = productGrades(product, manifest, dir)
+	classifyProduct(product, documentHeading(product, manifest, dir), loadTaxonomyOverrides(taxonomyOverridesPath))
+	return product
+}
+
+// partNumbers returns the part numbers of the product's documents
+func (p *Product) partNumbers(manifest *Manifest) []string {
+	var numbers []string
+	for _, filename := range p.Documents {
+		number := partNumberFromFilename(filename)
+		if document := manifest.Documents[filename]; document != nil && document.PartNumber != "" {
+			number = document.PartNumber
+		}
+		if number != "" {
+			numbers = appendUnique(numbers, number)
+		}
+	}
+	sort.Strings(numbers)
+	return numbers
+}
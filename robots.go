@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// botName is the product token the crawler identifies itself with and looks
+// for in robots.txt
+const botName = "cam2-com-documentation"
+
+// userAgent is sent with every request; the URL says who runs the crawler
+var userAgent = botName + "/1.0 (+https://github.com/Tech-Trailblazers/cam2-com-documentation)"
+
+// errDisallowed is returned for requests robots.txt does not allow
+var errDisallowed = errors.New("disallowed by robots.txt")
+
+// robotsRule is one Allow or Disallow line
+type robotsRule struct {
+	allow   bool
+	pattern string
+	match   *regexp.Regexp
+}
+
+// robotsGroup is the rules for one set of user agents
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// robotsPolicy is what robots.txt says about this crawler on one host
+type robotsPolicy struct {
+	disallowAll bool // robots.txt could not be fetched, see RFC 9309 section 2.3.1.4
+	rules       []robotsRule
+	crawlDelay  time.Duration
+}
+
+// robotsPatternRegexp turns a robots.txt path pattern with * and $ into a
+// regular expression anchored at the start of the path
+func robotsPatternRegexp(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expression := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expression += "$"
+	}
+	return regexp.MustCompile(expression)
+}
+
+// productToken is the name of a User-Agent without its version and
+// comment, e.g. cam2-com-documentation, which robots.txt groups name
+func productToken(agent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(agent), " ")
+	token, _, _ = strings.Cut(token, "/")
+	return token
+}
+
+// parseRobots reads robots.txt and returns the rules of the group that
+// names the product token, matched without regard to case as RFC 9309
+// section 2.2.1 asks, or else of the * group
+func parseRobots(content, token string) robotsPolicy {
+	token = strings.ToLower(token)
+	var groups []*robotsGroup
+	var current *robotsGroup
+	inAgents := false // Consecutive user-agent lines share a group
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		name, value = strings.ToLower(strings.TrimSpace(name)), strings.TrimSpace(value)
+		switch name {
+		case "user-agent":
+			if current == nil || !inAgents {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			inAgents = true
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil || value == "" { // An empty Disallow allows everything
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{allow: name == "allow", pattern: value, match: robotsPatternRegexp(value)})
+		case "crawl-delay":
+			inAgents = false
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && current != nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		default:
+			inAgents = false
+		}
+	}
+
+	var matched, wildcard []*robotsGroup
+	for _, group := range groups {
+		for _, agent := range group.agents {
+			switch {
+			case agent == "*":
+				wildcard = append(wildcard, group)
+			case agent == token:
+				matched = append(matched, group)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		matched = wildcard
+	}
+	var policy robotsPolicy
+	for _, group := range matched {
+		policy.rules = append(policy.rules, group.rules...)
+		if group.crawlDelay > policy.crawlDelay {
+			policy.crawlDelay = group.crawlDelay
+		}
+	}
+	return policy
+}
+
+// allowed reports whether a path (with its query) may be fetched: the
+// longest matching rule wins, and Allow wins a tie
+func (p robotsPolicy) allowed(path string) bool {
+	if p.disallowAll {
+		return false
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	best, allow := -1, true
+	for _, rule := range p.rules {
+		if !rule.match.MatchString(path) {
+			continue
+		}
+		if length := len(rule.pattern); length > best || (length == best && rule.allow) {
+			best, allow = length, rule.allow
+		}
+	}
+	return allow
+}
+
+// robotsTransport checks every request against the robots.txt of its host
+// and spaces requests to a host by its Crawl-delay
+type robotsTransport struct {
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	policies map[string]*robotsPolicy
+	last     map[string]time.Time // Host → when its last request was sent
+	skipped  []string             // Disallowed URLs, for the report
+}
+
+// newRobotsTransport wraps next with robots.txt compliance
+func newRobotsTransport(next http.RoundTripper) *robotsTransport {
+	return &robotsTransport{next: next, policies: map[string]*robotsPolicy{}, last: map[string]time.Time{}}
+}
+
+// useRobots puts robots.txt compliance in front of httpTransport, so every
+// request that follows keeps to it; report the returned transport when done
+func useRobots() *robotsTransport {
+	robots := newRobotsTransport(httpTransport)
+	httpTransport = robots
+	return robots
+}
+
+// policy returns the robots.txt policy of a host, fetching it the first time
+func (t *robotsTransport) policy(scheme, host string) *robotsPolicy {
+	key := scheme + "://" + host
+	t.mu.Lock()
+	policy := t.policies[key]
+	t.mu.Unlock()
+	if policy != nil {
+		return policy
+	}
+
+	policy = &robotsPolicy{}
+	robotsURL := key + "/robots.txt"
+	request, err := http.NewRequest("GET", robotsURL, nil)
+	if err == nil {
+		request.Header.Set("User-Agent", userAgent)
+		var response *http.Response
+		// A client, since robots.txt redirects are to be followed
+		client := &http.Client{Timeout: time.Minute, Transport: t.next}
+		response, err = client.Do(request)
+		if err == nil {
+			content, readErr := io.ReadAll(io.LimitReader(response.Body, 500*1024))
+			response.Body.Close()
+			switch {
+			case readErr != nil:
+				err = readErr
+			case response.StatusCode >= 200 && response.StatusCode <= 299:
+				*policy = parseRobots(string(content), productToken(userAgent))
+				log.Printf("Read %s: %d rules, crawl delay %s", robotsURL, len(policy.rules), policy.crawlDelay)
+			case response.StatusCode >= 400 && response.StatusCode <= 499:
+				log.Printf("No robots.txt at %s (%s); everything is allowed", key, response.Status)
+			default:
+				err = fmt.Errorf("%s", response.Status)
+			}
+		}
+	}
+	if err != nil {
+		// An unreachable robots.txt means nothing may be crawled
+		log.Printf("Could not read %s (%v); not crawling %s", robotsURL, err, key)
+		policy.disallowAll = true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if existing := t.policies[key]; existing != nil {
+		return existing
+	}
+	t.policies[key] = policy
+	return policy
+}
+
+func (t *robotsTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	policy := t.policy(request.URL.Scheme, request.URL.Host)
+	if !policy.allowed(request.URL.RequestURI()) {
+		t.mu.Lock()
+		t.skipped = appendUnique(t.skipped, request.URL.String())
+		t.mu.Unlock()
+		return nil, errDisallowed
+	}
+	// Wait out the Crawl-delay since the last request to the host
+	t.mu.Lock()
+	wait := time.Until(t.last[request.URL.Host].Add(policy.crawlDelay))
+	if wait < 0 {
+		wait = 0
+	}
+	t.last[request.URL.Host] = time.Now().Add(wait)
+	t.mu.Unlock()
+	time.Sleep(wait)
+	return t.next.RoundTrip(request)
+}
+
+// report logs the URLs robots.txt kept the crawler from fetching
+func (t *robotsTransport) report() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.skipped) == 0 {
+		return
+	}
+	sort.Strings(t.skipped)
+	log.Printf("Skipped %d URLs disallowed by robots.txt:", len(t.skipped))
+	for _, skipped := range t.skipped {
+		log.Println("  ", skipped)
+	}
+}
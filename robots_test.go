@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProductToken(t *testing.T) {
+	for agent, want := range map[string]string{
+		userAgent:                            "cam2-com-documentation",
+		"MyBot/2.1 (+mailto:me@example.com)": "MyBot",
+		"plainbot":                           "plainbot",
+	} {
+		if got := productToken(agent); got != want {
+			t.Errorf("productToken(%q) = %q, want %q", agent, got, want)
+		}
+	}
+}
+
+func TestParseRobots(t *testing.T) {
+	const robots = `# Shared rules
+User-agent: *
+Disallow: /wp-admin/
+Allow: /wp-admin/admin-ajax.php
+
+User-agent: cam2-com
+User-agent: documentation
+Disallow: /
+
+User-agent: CAM2-COM-Documentation
+Disallow: /cart/
+Disallow: /*?add-to-cart=
+Allow: /wp-content/uploads/*.pdf$
+Disallow: /wp-content/uploads/
+Crawl-delay: 2.5
+`
+	tests := []struct {
+		token string
+		path  string
+		want  bool
+	}{
+		// The crawler's own group, matched whatever its case, and not the
+		// groups whose names are only part of the token
+		{"cam2-com-documentation", "/product/cam2-ngeo/", true},
+		{"cam2-com-documentation", "/cart/", false},
+		{"cam2-com-documentation", "/product/cam2-ngeo/?add-to-cart=611150", false},
+		{"cam2-com-documentation", "/wp-content/uploads/2024/05/80565_215_SDS.pdf", true},
+		{"cam2-com-documentation", "/wp-content/uploads/2024/05/bottle.jpg", false},
+		{"cam2-com-documentation", "/wp-admin/", true},
+		// Another crawler falls back to *
+		{"otherbot", "/wp-admin/", false},
+		{"otherbot", "/wp-admin/admin-ajax.php", true},
+		{"otherbot", "/cart/", true},
+		{"cam2-com", "/product/cam2-ngeo/", false},
+	}
+	for _, test := range tests {
+		policy := parseRobots(robots, test.token)
+		if got := policy.allowed(test.path); got != test.want {
+			t.Errorf("%s may fetch %s: %v, want %v", test.token, test.path, got, test.want)
+		}
+	}
+	if delay := parseRobots(robots, "cam2-com-documentation").crawlDelay; delay.Seconds() != 2.5 {
+		t.Errorf("crawl delay %s, want 2.5s", delay)
+	}
+}
+
+func TestRobotsTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			io.WriteString(w, "User-agent: *\nDisallow: /wp-json/\n")
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newRobotsTransport(http.DefaultTransport)}
+	response, err := client.Get(server.URL + "/product/cam2-ngeo/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if _, err := client.Get(server.URL + "/wp-json/wp/v2/media"); !errors.Is(err, errDisallowed) {
+		t.Errorf("disallowed request gave %v", err)
+	}
+
+	// A host whose robots.txt fails is not crawled at all
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	if _, err := client.Get(failing.URL + "/product/cam2-ngeo/"); !errors.Is(err, errDisallowed) {
+		t.Errorf("request to a host without a readable robots.txt gave %v", err)
+	}
+}
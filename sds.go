@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sdsSectionCount is the number of numbered sections in a GHS safety data sheet
+const sdsSectionCount = 16
+
+// SafetyDataSheet holds what is parsed from one safety data sheet
+type SafetyDataSheet struct {
+	File         string     `json:"file"`
+	ProductName  string     `json:"product_name,omitempty"`  // Often a whole product line
+	RevisionDate string     `json:"revision_date,omitempty"` // YYYY-MM-DD
+	Hazards      SDSHazards `json:"hazards"`
+
+	// Sections[1] to Sections[16] hold the text of each section; Sections[0]
+	// holds whatever comes before section 1, which is often the label
+	// elements of section 2 because of how the PDFs are laid out
+	Sections [sdsSectionCount + 1]string `json:"-"`
+}
+
+// SDSHazards is the GHS hazard classification of a product
+type SDSHazards struct {
+	SignalWord string   `json:"signal_word,omitempty"` // "Danger", "Warning" or "" when not classified
+	Classes    []string `json:"classes,omitempty"`     // e.g. "Skin Irrit. 2 (H315)"
+	Statements []string `json:"statements,omitempty"`  // Hazard statement codes, e.g. "H304"
+}
+
+// Patterns for the parts of a safety data sheet
+var (
+	sdsProductNamePattern = regexp.MustCompile(`(?im)^[ \t]*(?:product|trade)\s+name\s*:[ \t]*(.+)$`)
+	sdsSectionPattern     = regexp.MustCompile(`(?im)^[ \t]*section[ \t]*(\d{1,2})[ \t]*[:.]`)
+	signalWordPattern     = regexp.MustCompile(`(?im)signal\s+word[^:\n]*(?::[ \t]*(.*))?$`)
+	hazardCodePattern     = regexp.MustCompile(`\bH[2-4]\d{2}\b`)
+	hazardClassPattern    = regexp.MustCompile(`^(.*[A-Za-z].*?)[\s,]+(H[2-4]\d{2})$`)
+	revisionDatePattern   = regexp.MustCompile(`(?i)\b(?:revision\s+date(?:\s+other\s+information)?|date\s+of\s+revision|revised(?:\s+on)?|effective\s+date|issue\s+date|date\s+of\s+issue|date\s+issued)\b[\s:.]*(\d{1,2}[/.-]\d{1,2}/?[/.-]\d{2,4}|\d{1,2}/\d{4}|\d{4}[/.-]\d{1,2}[/.-]\d{1,2}|[A-Za-z]{3,9}\.?\s+\d{1,2},?\s+\d{4}|\d{1,2}[-\s][A-Za-z]{3,9}\.?[-\s]\d{4})`)
+	pageHeaderDatePattern = regexp.MustCompile(`(?m)^(\d{1,2}[/.]\d{1,2}[/.]\d{4})(?:\s+EN\b|$)`)
+	numericDatePattern    = regexp.MustCompile(`^(\d{1,2})[/.-](\d{1,2})[/.-](\d{2}|\d{4})$`)
+	yearFirstDatePattern  = regexp.MustCompile(`^(\d{4})[/.-](\d{1,2})[/.-](\d{1,2})$`)
+)
+
+// documentDateLayouts are the date formats seen on CAM2 data sheets, after
+// parseDocumentDate has rewritten numeric dates as month/day/year
+var documentDateLayouts = []string{
+	"1/2/2006", "1/2/06", "1/2006", "2006-01-02",
+	"January 2, 2006", "January 2 2006", "Jan 2, 2006", "Jan 2 2006", "Jan. 2, 2006",
+	"2-Jan-2006", "2 Jan 2006", "2 January 2006", "2-January-2006", "2 Jan. 2006",
+}
+
+// parseDocumentDate parses a date as printed on a data sheet. Numeric dates
+// are month first, as CAM2 prints them, unless the first number is over 12;
+// dates that start with the year are year, month, day.
+func parseDocumentDate(value string) (time.Time, bool) {
+	value = strings.Join(strings.Fields(strings.ReplaceAll(value, "//", "/")), " ")
+	if match := numericDatePattern.FindStringSubmatch(value); match != nil {
+		month, day := match[1], match[2]
+		if first, _ := strconv.Atoi(month); first > 12 {
+			month, day = day, month
+		}
+		value = month + "/" + day + "/" + match[3]
+	} else if match := yearFirstDatePattern.FindStringSubmatch(value); match != nil {
+		value = match[2] + "/" + match[3] + "/" + match[1]
+	}
+	for _, layout := range documentDateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// documentRevisionDate finds the revision date of a data sheet: an explicit
+// "Revision Date" field first, then the date in the page header
+func documentRevisionDate(text string) (time.Time, bool) {
+	date, _, ok := locateRevisionDate(text)
+	return date, ok
+}
+
+// locateRevisionDate is documentRevisionDate that also says where the date
+// was found: "section 16" or another section, "heading" for the part before
+// section 1, or "page header"
+func locateRevisionDate(text string) (time.Time, string, bool) {
+	for _, match := range revisionDatePattern.FindAllStringSubmatchIndex(text, -1) {
+		if date, ok := parseDocumentDate(text[match[2]:match[3]]); ok {
+			return date, sectionAt(text, match[0]), true
+		}
+	}
+	if match := pageHeaderDatePattern.FindStringSubmatch(text); match != nil {
+		date, ok := parseDocumentDate(match[1])
+		return date, "page header", ok
+	}
+	return time.Time{}, "", false
+}
+
+// sectionAt names the SDS section that contains the given offset of text
+func sectionAt(text string, offset int) string {
+	length := 0
+	for number, section := range splitSDSSections(text) {
+		length += len(section)
+		if offset < length {
+			if number == 0 {
+				return "heading"
+			}
+			return fmt.Sprintf("section %d", number)
+		}
+	}
+	return "heading"
+}
+
+// splitSDSSections splits the text of a safety data sheet at its numbered
+// section headings. Headings must appear in increasing order, which skips
+// references such as "see section 8" that happen to start a line.
+func splitSDSSections(text string) [sdsSectionCount + 1]string {
+	var sections [sdsSectionCount + 1]string
+	current, start := 0, 0
+	for _, match := range sdsSectionPattern.FindAllStringSubmatchIndex(text, -1) {
+		number := 0
+		for _, digit := range text[match[2]:match[3]] {
+			number = number*10 + int(digit-'0')
+		}
+		if number <= current || number > sdsSectionCount {
+			continue
+		}
+		sections[current] += text[start:match[0]]
+		current, start = number, match[0]
+	}
+	sections[current] += text[start:]
+	return sections
+}
+
+// parseHazards reads the GHS classification from the label elements and
+// section 2; ingredient rows, which carry their own classification, are skipped
+func parseHazards(sections [sdsSectionCount + 1]string) SDSHazards {
+	var hazards SDSHazards
+	region := sections[0] + "\n" + sections[2]
+	if match := signalWordPattern.FindStringSubmatch(region); match != nil {
+		word := strings.ToLower(match[1])
+		switch {
+		case strings.Contains(word, "danger"):
+			hazards.SignalWord = "Danger"
+		case strings.Contains(word, "warning"):
+			hazards.SignalWord = "Warning"
+		}
+	}
+	for _, line := range strings.Split(region, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if strings.Contains(strings.ToUpper(line), "CAS") {
+			continue
+		}
+		for _, code := range hazardCodePattern.FindAllString(line, -1) {
+			hazards.Statements = appendUnique(hazards.Statements, code)
+		}
+		match := hazardClassPattern.FindStringSubmatch(line)
+		if match == nil || hazardCodePattern.MatchString(match[1]) || strings.Contains(strings.ToLower(match[1]), "statement") {
+			continue
+		}
+		class := strings.TrimRight(match[1], " ,")
+		hazards.Classes = appendUnique(hazards.Classes, class+" ("+match[2]+")")
+	}
+	sort.Strings(hazards.Statements)
+	sort.Strings(hazards.Classes)
+	return hazards
+}
+
+// parseSDSText parses the text of a safety data sheet
+func parseSDSText(file, text string) SafetyDataSheet {
+	sheet := SafetyDataSheet{File: file, Sections: splitSDSSections(text)}
+	sheet.Hazards = parseHazards(sheet.Sections)
+	if match := sdsProductNamePattern.FindStringSubmatch(text); match != nil {
+		sheet.ProductName = strings.TrimSpace(match[1])
+	}
+	if date, ok := documentRevisionDate(text); ok {
+		sheet.RevisionDate = date.Format("2006-01-02")
+	}
+	return sheet
+}
+
+// loadSafetyDataSheet reads and parses one safety data sheet
+func loadSafetyDataSheet(path string) (SafetyDataSheet, error) {
+	text, err := pdfText(path)
+	if err != nil {
+		return SafetyDataSheet{}, err
+	}
+	return parseSDSText(getFilename(path), text), nil
+}
+
+// hazardStatementText is the GHS wording of the hazard statements, including
+// the optional categories adopted in the US (H303, H316, H320, H401...)
+var hazardStatementText = map[string]string{
+	"H200": "Unstable explosive",
+	"H220": "Extremely flammable gas",
+	"H221": "Flammable gas",
+	"H222": "Extremely flammable aerosol",
+	"H223": "Flammable aerosol",
+	"H224": "Extremely flammable liquid and vapour",
+	"H225": "Highly flammable liquid and vapour",
+	"H226": "Flammable liquid and vapour",
+	"H227": "Combustible liquid",
+	"H228": "Flammable solid",
+	"H229": "Pressurized container: may burst if heated",
+	"H240": "Heating may cause an explosion",
+	"H241": "Heating may cause a fire or explosion",
+	"H242": "Heating may cause a fire",
+	"H250": "Catches fire spontaneously if exposed to air",
+	"H251": "Self-heating; may catch fire",
+	"H252": "Self-heating in large quantities; may catch fire",
+	"H260": "In contact with water releases flammable gases which may ignite spontaneously",
+	"H261": "In contact with water releases flammable gas",
+	"H270": "May cause or intensify fire; oxidizer",
+	"H271": "May cause fire or explosion; strong oxidizer",
+	"H272": "May intensify fire; oxidizer",
+	"H280": "Contains gas under pressure; may explode if heated",
+	"H281": "Contains refrigerated gas; may cause cryogenic burns or injury",
+	"H290": "May be corrosive to metals",
+	"H300": "Fatal if swallowed",
+	"H301": "Toxic if swallowed",
+	"H302": "Harmful if swallowed",
+	"H303": "May be harmful if swallowed",
+	"H304": "May be fatal if swallowed and enters airways",
+	"H305": "May be harmful if swallowed and enters airways",
+	"H310": "Fatal in contact with skin",
+	"H311": "Toxic in contact with skin",
+	"H312": "Harmful in contact with skin",
+	"H313": "May be harmful in contact with skin",
+	"H314": "Causes severe skin burns and eye damage",
+	"H315": "Causes skin irritation",
+	"H316": "Causes mild skin irritation",
+	"H317": "May cause an allergic skin reaction",
+	"H318": "Causes serious eye damage",
+	"H319": "Causes serious eye irritation",
+	"H320": "Causes eye irritation",
+	"H330": "Fatal if inhaled",
+	"H331": "Toxic if inhaled",
+	"H332": "Harmful if inhaled",
+	"H333": "May be harmful if inhaled",
+	"H334": "May cause allergy or asthma symptoms or breathing difficulties if inhaled",
+	"H335": "May cause respiratory irritation",
+	"H336": "May cause drowsiness or dizziness",
+	"H340": "May cause genetic defects",
+	"H341": "Suspected of causing genetic defects",
+	"H350": "May cause cancer",
+	"H351": "Suspected of causing cancer",
+	"H360": "May damage fertility or the unborn child",
+	"H361": "Suspected of damaging fertility or the unborn child",
+	"H362": "May cause harm to breast-fed children",
+	"H370": "Causes damage to organs",
+	"H371": "May cause damage to organs",
+	"H372": "Causes damage to organs through prolonged or repeated exposure",
+	"H373": "May cause damage to organs through prolonged or repeated exposure",
+	"H400": "Very toxic to aquatic life",
+	"H401": "Toxic to aquatic life",
+	"H402": "Harmful to aquatic life",
+	"H410": "Very toxic to aquatic life with long lasting effects",
+	"H411": "Toxic to aquatic life with long lasting effects",
+	"H412": "Harmful to aquatic life with long lasting effects",
+	"H413": "May cause long lasting harmful effects to aquatic life",
+	"H420": "Harms public health and the environment by destroying ozone in the upper atmosphere",
+}
+
+// describeHazardStatement returns "H304 May be fatal if swallowed…" for a code
+func describeHazardStatement(code string) string {
+	if text, ok := hazardStatementText[code]; ok {
+		return code + " " + text
+	}
+	return code
+}
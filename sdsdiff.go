@@ -0,0 +1,440 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// sdsSectionTitles are the headings of the GHS sections; index 0 is the text
+// before section 1, which usually holds the label elements
+var sdsSectionTitles = [sdsSectionCount + 1]string{
+	"Label elements", "Identification", "Hazard identification", "Composition/information on ingredients",
+	"First-aid measures", "Fire-fighting measures", "Accidental release measures", "Handling and storage",
+	"Exposure controls/personal protection", "Physical and chemical properties", "Stability and reactivity",
+	"Toxicological information", "Ecological information", "Disposal considerations", "Transport information",
+	"Regulatory information", "Other information",
+}
+
+// Patterns for the parts of a revision that are compared on their own
+var (
+	pageCounterPattern    = regexp.MustCompile(`\b\d+\s*/\s*\d+\s*$`)
+	casNumberPattern      = regexp.MustCompile(`\b\d{2,7}-\d{2}-\d\b`)
+	exposureLimitPattern  = regexp.MustCompile(`(?i)\b(?:TLV|PEL|REL|TWA|STEL|ceiling|IDLH|ppm)\b|mg/m[3³]`)
+	sdsSectionHeadingLine = regexp.MustCompile(`(?i)^section\s*\d{1,2}\s*[:.]`)
+)
+
+// diffLine is one line of a section diff: ' ' unchanged, '-' removed, '+' added
+type diffLine struct {
+	Op   byte
+	Text string
+}
+
+// SectionDiff is the change to one SDS section
+type SectionDiff struct {
+	Number  int
+	Title   string
+	Added   int
+	Removed int
+	Lines   []diffLine // Changed lines only
+}
+
+// IngredientChange is a section 3 ingredient that was added, removed or
+// changed, identified by CAS number
+type IngredientChange struct {
+	CAS string
+	Old string // Whole row in the old revision, empty when added
+	New string // Whole row in the new revision, empty when removed
+}
+
+// SDSDiff is the semantic difference between two revisions of a safety
+// data sheet
+type SDSDiff struct {
+	Old, New              SafetyDataSheet
+	SignalWordChanged     bool
+	HazardsAdded          []string // Hazard statements with their wording
+	HazardsRemoved        []string
+	ClassesAdded          []string
+	ClassesRemoved        []string
+	Ingredients           []IngredientChange
+	ExposureLimitsAdded   []string
+	ExposureLimitsRemoved []string
+	Sections              []SectionDiff // Changed sections only
+	UnchangedSections     int
+}
+
+// runningLines returns the page header and footer lines of a document:
+// lines, ignoring a trailing page counter, found on more than half the pages
+func runningLines(pages []string) map[string]bool {
+	running := map[string]bool{}
+	if len(pages) < 2 {
+		return running
+	}
+	counts := map[string]int{}
+	for _, page := range pages {
+		seen := map[string]bool{}
+		for _, line := range strings.Split(page, "\n") {
+			key := pageCounterPattern.ReplaceAllString(strings.TrimSpace(line), "")
+			if key != "" && !seen[key] {
+				seen[key] = true
+				counts[key]++
+			}
+		}
+	}
+	for line, count := range counts {
+		if count*2 > len(pages) && !sdsSectionHeadingLine.MatchString(line) {
+			running[line] = true
+		}
+	}
+	return running
+}
+
+// loadSDSRevision reads a safety data sheet with its page headers and
+// footers removed, since they carry the revision date and page numbers
+func loadSDSRevision(path string) (SafetyDataSheet, error) {
+	pages, err := pdfPagesText(path)
+	if err != nil {
+		return SafetyDataSheet{}, err
+	}
+	sheet := parseSDSText(getFilename(path), strings.Join(pages, "\n\f\n"))
+	running := runningLines(pages)
+	var kept []string
+	for _, page := range pages {
+		for _, line := range strings.Split(page, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !running[pageCounterPattern.ReplaceAllString(line, "")] {
+				kept = append(kept, line)
+			}
+		}
+	}
+	sheet.Sections = splitSDSSections(strings.Join(kept, "\n"))
+	return sheet, nil
+}
+
+// sectionLines splits a section into its non-empty lines
+func sectionLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// diffLines compares two lists of lines by their longest common subsequence
+func diffLines(old, new []string) []diffLine {
+	// common[i][j] is the length of the common subsequence of old[i:] and new[j:]
+	common := make([][]int, len(old)+1)
+	for i := range common {
+		common[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(old) && j < len(new) {
+		switch {
+		case old[i] == new[j]:
+			lines = append(lines, diffLine{' ', old[i]})
+			i++
+			j++
+		case common[i+1][j] >= common[i][j+1]:
+			lines = append(lines, diffLine{'-', old[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', new[j]})
+			j++
+		}
+	}
+	for ; i < len(old); i++ {
+		lines = append(lines, diffLine{'-', old[i]})
+	}
+	for ; j < len(new); j++ {
+		lines = append(lines, diffLine{'+', new[j]})
+	}
+	return lines
+}
+
+// difference returns the values of a that are not in b
+func difference(a, b []string) []string {
+	var only []string
+	for _, value := range a {
+		if !containsString(b, value) {
+			only = append(only, value)
+		}
+	}
+	return only
+}
+
+// ingredientRows maps the CAS numbers of section 3 to their rows
+func ingredientRows(section string) map[string]string {
+	rows := map[string]string{}
+	for _, line := range sectionLines(section) {
+		for _, cas := range casNumberPattern.FindAllString(line, -1) {
+			if _, ok := rows[cas]; !ok {
+				rows[cas] = line
+			}
+		}
+	}
+	return rows
+}
+
+// exposureLimitLines returns the lines of section 8 that state a limit
+func exposureLimitLines(section string) []string {
+	var lines []string
+	for _, line := range sectionLines(section) {
+		if exposureLimitPattern.MatchString(line) {
+			lines = appendUnique(lines, line)
+		}
+	}
+	return lines
+}
+
+// describeHazards gives hazard statement codes their wording
+func describeHazards(codes []string) []string {
+	var described []string
+	for _, code := range codes {
+		described = append(described, describeHazardStatement(code))
+	}
+	return described
+}
+
+// diffSafetyDataSheets compares two revisions of a safety data sheet
+func diffSafetyDataSheets(old, new SafetyDataSheet) SDSDiff {
+	report := SDSDiff{Old: old, New: new}
+	report.SignalWordChanged = old.Hazards.SignalWord != new.Hazards.SignalWord
+	report.HazardsAdded = describeHazards(difference(new.Hazards.Statements, old.Hazards.Statements))
+	report.HazardsRemoved = describeHazards(difference(old.Hazards.Statements, new.Hazards.Statements))
+	report.ClassesAdded = difference(new.Hazards.Classes, old.Hazards.Classes)
+	report.ClassesRemoved = difference(old.Hazards.Classes, new.Hazards.Classes)
+
+	oldRows, newRows := ingredientRows(old.Sections[3]), ingredientRows(new.Sections[3])
+	var numbers []string
+	for cas := range oldRows {
+		numbers = append(numbers, cas)
+	}
+	for cas := range newRows {
+		if _, ok := oldRows[cas]; !ok {
+			numbers = append(numbers, cas)
+		}
+	}
+	sort.Strings(numbers)
+	for _, cas := range numbers {
+		if oldRows[cas] != newRows[cas] {
+			report.Ingredients = append(report.Ingredients, IngredientChange{CAS: cas, Old: oldRows[cas], New: newRows[cas]})
+		}
+	}
+	oldLimits, newLimits := exposureLimitLines(old.Sections[8]), exposureLimitLines(new.Sections[8])
+	report.ExposureLimitsAdded = difference(newLimits, oldLimits)
+	report.ExposureLimitsRemoved = difference(oldLimits, newLimits)
+
+	for number := range old.Sections {
+		section := SectionDiff{Number: number, Title: sdsSectionTitles[number]}
+		for _, line := range diffLines(sectionLines(old.Sections[number]), sectionLines(new.Sections[number])) {
+			switch line.Op {
+			case '+':
+				section.Added++
+			case '-':
+				section.Removed++
+			default:
+				continue
+			}
+			section.Lines = append(section.Lines, line)
+		}
+		if section.Added+section.Removed > 0 {
+			report.Sections = append(report.Sections, section)
+		} else {
+			report.UnchangedSections++
+		}
+	}
+	return report
+}
+
+// changed reports whether the revisions differ at all
+func (d SDSDiff) changed() bool {
+	return len(d.Sections) > 0 || d.SignalWordChanged || len(d.HazardsAdded)+len(d.HazardsRemoved) > 0
+}
+
+// orNone prints a missing value
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+// writeSDSDiffText prints the diff for a terminal
+func writeSDSDiffText(w io.Writer, d SDSDiff) {
+	fmt.Fprintf(w, "--- %s (revised %s)\n", d.Old.File, orNone(d.Old.RevisionDate))
+	fmt.Fprintf(w, "+++ %s (revised %s)\n", d.New.File, orNone(d.New.RevisionDate))
+	if !d.changed() {
+		fmt.Fprintln(w, "\nNo differences in the text of the 16 sections.")
+		return
+	}
+	if d.SignalWordChanged {
+		fmt.Fprintf(w, "\nSignal word: %s → %s\n", orNone(d.Old.Hazards.SignalWord), orNone(d.New.Hazards.SignalWord))
+	}
+	if len(d.HazardsAdded)+len(d.HazardsRemoved)+len(d.ClassesAdded)+len(d.ClassesRemoved) > 0 {
+		fmt.Fprintln(w, "\nHazard classification:")
+		for _, statement := range d.HazardsAdded {
+			fmt.Fprintf(w, "  + %s\n", statement)
+		}
+		for _, statement := range d.HazardsRemoved {
+			fmt.Fprintf(w, "  - %s\n", statement)
+		}
+		for _, class := range d.ClassesAdded {
+			fmt.Fprintf(w, "  + class %s\n", class)
+		}
+		for _, class := range d.ClassesRemoved {
+			fmt.Fprintf(w, "  - class %s\n", class)
+		}
+	}
+	if len(d.Ingredients) > 0 {
+		fmt.Fprintln(w, "\nComposition changed (section 3):")
+		for _, change := range d.Ingredients {
+			switch {
+			case change.Old == "":
+				fmt.Fprintf(w, "  + CAS %s: %s\n", change.CAS, change.New)
+			case change.New == "":
+				fmt.Fprintf(w, "  - CAS %s: %s\n", change.CAS, change.Old)
+			default:
+				fmt.Fprintf(w, "  ~ CAS %s: %s\n           → %s\n", change.CAS, change.Old, change.New)
+			}
+		}
+	}
+	if len(d.ExposureLimitsAdded)+len(d.ExposureLimitsRemoved) > 0 {
+		fmt.Fprintln(w, "\nExposure limits changed (section 8):")
+		for _, line := range d.ExposureLimitsRemoved {
+			fmt.Fprintf(w, "  - %s\n", line)
+		}
+		for _, line := range d.ExposureLimitsAdded {
+			fmt.Fprintf(w, "  + %s\n", line)
+		}
+	}
+	fmt.Fprintf(w, "\n%d sections changed, %d unchanged:\n", len(d.Sections), d.UnchangedSections)
+	for _, section := range d.Sections {
+		fmt.Fprintf(w, "\n@@ Section %d: %s (+%d -%d) @@\n", section.Number, section.Title, section.Added, section.Removed)
+		for _, line := range section.Lines {
+			fmt.Fprintf(w, "%c %s\n", line.Op, line.Text)
+		}
+	}
+}
+
+// sdsDiffTemplate renders the diff as a standalone HTML page
+var sdsDiffTemplate = template.Must(template.New("sdsdiff").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Old.File}} → {{.New.File}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.added { background: #dcfce7; }
+.removed { background: #fee2e2; text-decoration: line-through; }
+pre { white-space: pre-wrap; }
+h3 { margin-bottom: 0.3em; }
+</style>
+</head>
+<body>
+<h1>Safety data sheet changes</h1>
+<p>Old: {{.Old.File}}, revised {{if .Old.RevisionDate}}{{.Old.RevisionDate}}{{else}}(no date){{end}}<br>
+New: {{.New.File}}, revised {{if .New.RevisionDate}}{{.New.RevisionDate}}{{else}}(no date){{end}}</p>
+{{if .SignalWordChanged}}<h2>Signal word</h2>
+<p><span class="removed">{{if .Old.Hazards.SignalWord}}{{.Old.Hazards.SignalWord}}{{else}}none{{end}}</span> → <span class="added">{{if .New.Hazards.SignalWord}}{{.New.Hazards.SignalWord}}{{else}}none{{end}}</span></p>
+{{end}}{{if or .HazardsAdded .HazardsRemoved .ClassesAdded .ClassesRemoved}}<h2>Hazard classification</h2>
+<ul>
+{{range .HazardsAdded}}<li class="added">{{.}}</li>
+{{end}}{{range .HazardsRemoved}}<li class="removed">{{.}}</li>
+{{end}}{{range .ClassesAdded}}<li class="added">{{.}}</li>
+{{end}}{{range .ClassesRemoved}}<li class="removed">{{.}}</li>
+{{end}}</ul>
+{{end}}{{if .Ingredients}}<h2>Composition (section 3)</h2>
+<table>
+<tr><th>CAS</th><th>Old</th><th>New</th></tr>
+{{range .Ingredients}}<tr><td>{{.CAS}}</td><td class="removed">{{.Old}}</td><td class="added">{{.New}}</td></tr>
+{{end}}</table>
+{{end}}{{if or .ExposureLimitsAdded .ExposureLimitsRemoved}}<h2>Exposure limits (section 8)</h2>
+<ul>
+{{range .ExposureLimitsRemoved}}<li class="removed">{{.}}</li>
+{{end}}{{range .ExposureLimitsAdded}}<li class="added">{{.}}</li>
+{{end}}</ul>
+{{end}}<h2>Sections</h2>
+{{if .Sections}}<p>{{len .Sections}} sections changed, {{.UnchangedSections}} unchanged.</p>
+{{range .Sections}}<h3>Section {{.Number}}: {{.Title}} (+{{.Added}} −{{.Removed}})</h3>
+<pre>{{range .Lines}}{{if eq .Op 43}}<span class="added">+ {{.Text}}</span>{{else}}<span class="removed">- {{.Text}}</span>{{end}}
+{{end}}</pre>
+{{end}}{{else}}<p>No differences in the text of the 16 sections.</p>
+{{end}}</body>
+</html>
+`))
+
+// diffCommand implements "diff": what changed between two revisions of a
+// safety data sheet, section by section
+func diffCommand(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := flags.String("format", "text", "output format: text or html")
+	output := flags.String("o", "", "write the report to this file instead of standard output")
+	dir := flags.String("dir", "PDFs/", "directory to look in for files given by name only")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: go run . diff [flags] <old revision.pdf> <new revision.pdf>")
+		os.Exit(2)
+	}
+	// Checked before -o creates or truncates the output file
+	if *format != "text" && *format != "html" {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		os.Exit(2)
+	}
+
+	var sheets []SafetyDataSheet
+	for _, path := range flags.Args() {
+		if !fileExists(path) && fileExists(filepath.Join(*dir, path)) {
+			path = filepath.Join(*dir, path)
+		}
+		sheet, err := loadSDSRevision(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to read %s: %v\n", path, err)
+			os.Exit(2)
+		}
+		sheets = append(sheets, sheet)
+	}
+	report := diffSafetyDataSheets(sheets[0], sheets[1])
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer file.Close()
+		w = file
+	}
+	switch *format {
+	case "text":
+		writeSDSDiffText(w, report)
+	case "html":
+		if err := sdsDiffTemplate.Execute(w, report); err != nil {
+			log.Println(err)
+		}
+	}
+	// Like diff(1), the exit status tells scripts whether anything changed
+	if report.changed() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// searchIndexPath is the inverted index over the text of the PDFs; it is
+// stored with gob because it is much larger than the other caches
+const searchIndexPath = ".cache/search.idx"
+
+// SearchIndex maps every word in the downloaded PDFs to where it occurs
+type SearchIndex struct {
+	Documents map[string]*IndexedDocument // Keyed by filename
+	Terms     map[string][]IndexPosting
+}
+
+// IndexedDocument records the version of a file that was indexed, so that
+// only new and replaced files are read again
+type IndexedDocument struct {
+	Kind    string
+	Size    int64
+	ModTime int64
+	Pages   int
+}
+
+// IndexPosting lists the positions of a word on one page of a document;
+// Positions count words from the top of the page
+type IndexPosting struct {
+	File      string
+	Page      int // 1-based
+	Section   int // SDS section, 0 before section 1 and in other documents
+	Positions []int
+}
+
+// pageToken is one word of a page and where it is in the text
+type pageToken struct {
+	term       string
+	start, end int
+}
+
+// tokenizePage splits text into lowercase words of letters and digits, so
+// "107-21-1" is three words and matches the phrase query "107-21-1"
+func tokenizePage(text string) []pageToken {
+	var tokens []pageToken
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start < 0 {
+			start = i
+		} else if !word && start >= 0 {
+			tokens = append(tokens, pageToken{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, pageToken{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+// sectionBoundary is where an SDS section starts on a page
+type sectionBoundary struct {
+	offset  int
+	section int
+}
+
+// pageSections finds the section headings on one page of a safety data
+// sheet, continuing from the section the previous page ended in. Like
+// splitSDSSections it only accepts headings in increasing order.
+func pageSections(text string, current int) []sectionBoundary {
+	boundaries := []sectionBoundary{{0, current}}
+	for _, match := range sdsSectionPattern.FindAllStringSubmatchIndex(text, -1) {
+		number, _ := strconv.Atoi(text[match[2]:match[3]])
+		if number <= current || number > sdsSectionCount {
+			continue
+		}
+		current = number
+		boundaries = append(boundaries, sectionBoundary{match[0], number})
+	}
+	return boundaries
+}
+
+// loadSearchIndex reads the index, returning an empty one if it is missing
+// or unreadable
+func loadSearchIndex(path string) *SearchIndex {
+	index := &SearchIndex{Documents: map[string]*IndexedDocument{}, Terms: map[string][]IndexPosting{}}
+	file, err := os.Open(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Println(err)
+		}
+		return index
+	}
+	defer file.Close()
+	if err := gob.NewDecoder(file).Decode(index); err != nil {
+		log.Printf("Failed to read %s, rebuilding it: %v", path, err)
+		return &SearchIndex{Documents: map[string]*IndexedDocument{}, Terms: map[string][]IndexPosting{}}
+	}
+	if index.Documents == nil {
+		index.Documents = map[string]*IndexedDocument{}
+	}
+	if index.Terms == nil {
+		index.Terms = map[string][]IndexPosting{}
+	}
+	return index
+}
+
+// save writes the index through a temporary file
+func (index *SearchIndex) save(path string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Println(err)
+		return
+	}
+	temporaryPath := path + ".tmp"
+	file, err := os.Create(temporaryPath)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := gob.NewEncoder(file).Encode(index); err != nil {
+		log.Println(err)
+		file.Close()
+		return
+	}
+	if err := file.Close(); err != nil {
+		log.Println(err)
+		return
+	}
+	if err := os.Rename(temporaryPath, path); err != nil {
+		log.Println(err)
+	}
+}
+
+// remove drops every posting of a document
+func (index *SearchIndex) remove(filename string) {
+	for term, postings := range index.Terms {
+		kept := postings[:0]
+		for _, posting := range postings {
+			if posting.File != filename {
+				kept = append(kept, posting)
+			}
+		}
+		if len(kept) == 0 {
+			delete(index.Terms, term)
+		} else {
+			index.Terms[term] = kept
+		}
+	}
+	delete(index.Documents, filename)
+}
+
+// add indexes the pages of a document, replacing any earlier version of it
+func (index *SearchIndex) add(filename string, info fs.FileInfo, pages []string) {
+	if index.Documents[filename] != nil {
+		index.remove(filename)
+	}
+	kind := documentKind(filename)
+	index.Documents[filename] = &IndexedDocument{Kind: kind, Size: info.Size(), ModTime: info.ModTime().Unix(), Pages: len(pages)}
+	section := 0
+	for pageNumber, text := range pages {
+		boundaries := []sectionBoundary{{0, 0}}
+		if kind == "sds" {
+			boundaries = pageSections(text, section)
+			section = boundaries[len(boundaries)-1].section
+		}
+		postings := map[string]*IndexPosting{}
+		var order []string
+		boundary := 0
+		for position, token := range tokenizePage(text) {
+			for boundary+1 < len(boundaries) && boundaries[boundary+1].offset <= token.start {
+				boundary++
+			}
+			key := token.term + "\x00" + strconv.Itoa(boundaries[boundary].section)
+			posting := postings[key]
+			if posting == nil {
+				posting = &IndexPosting{File: filename, Page: pageNumber + 1, Section: boundaries[boundary].section}
+				postings[key] = posting
+				order = append(order, key)
+			}
+			posting.Positions = append(posting.Positions, position)
+		}
+		for _, key := range order {
+			term := key[:strings.IndexByte(key, 0)]
+			index.Terms[term] = append(index.Terms[term], *postings[key])
+		}
+	}
+}
+
+// refresh brings the index up to date with the PDFs in dir: new and replaced
+// files are indexed, deleted ones are dropped. It reports whether anything
+// changed.
+func (index *SearchIndex) refresh(dir string) bool {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pdf"))
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	changed := false
+	present := map[string]bool{}
+	for _, path := range paths {
+		filename := getFilename(path)
+		present[filename] = true
+		info, err := os.Stat(path)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if indexed := index.Documents[filename]; indexed != nil && indexed.Size == info.Size() && indexed.ModTime == info.ModTime().Unix() {
+			continue
+		}
+		pages, err := pdfPagesText(path)
+		if err != nil {
+			// Image-only and broken files are recorded so they are not retried
+			log.Printf("Failed to index %s: %v", filename, err)
+		}
+		index.add(filename, info, pages)
+		changed = true
+	}
+	for filename := range index.Documents {
+		if !present[filename] {
+			index.remove(filename)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// updateSearchIndex indexes the PDFs that were added or replaced in dir since
+// the index was last updated
+func updateSearchIndex(dir string) {
+	index := loadSearchIndex(searchIndexPath)
+	if index.refresh(dir) {
+		index.save(searchIndexPath)
+	}
+}
+
+// parseSearchQuery splits a query into phrases: text in double quotes, or a
+// whole argument with spaces in it, is one phrase; other words stand alone.
+// Every phrase is a list of index terms.
+func parseSearchQuery(args []string) [][]string {
+	var phrases [][]string
+	addPhrase := func(text string, split bool) {
+		var terms []string
+		for _, token := range tokenizePage(text) {
+			terms = append(terms, token.term)
+		}
+		if split {
+			for _, term := range terms {
+				phrases = append(phrases, []string{term})
+			}
+		} else if len(terms) > 0 {
+			phrases = append(phrases, terms)
+		}
+	}
+	for _, arg := range args {
+		if !strings.Contains(arg, `"`) {
+			addPhrase(arg, false)
+			continue
+		}
+		for i, part := range strings.Split(arg, `"`) {
+			addPhrase(part, i%2 == 0) // Odd parts were inside quotes
+		}
+	}
+	return phrases
+}
+
+// SearchResult is one page that matches every phrase of a query
+type SearchResult struct {
+	File     string   `json:"file"`
+	Kind     string   `json:"kind"`
+	Page     int      `json:"page"`
+	Sections []int    `json:"sections,omitempty"` // SDS sections the matches are in
+	Matches  int      `json:"matches"`
+	Snippets []string `json:"snippets"`
+}
+
+// pageMatch is where phrases start on one page
+type pageMatch struct {
+	starts   map[int]int // Word position → SDS section
+	complete int         // Number of phrases found so far
+}
+
+// phraseStarts returns, for each page, the positions where a phrase starts
+// and the section of its first word
+func (index *SearchIndex) phraseStarts(phrase []string) map[string]map[int]int {
+	// Positions of each word of the phrase, by file and page
+	positions := make([]map[string]map[int]int, len(phrase))
+	for i, term := range phrase {
+		positions[i] = map[string]map[int]int{}
+		for _, posting := range index.Terms[term] {
+			key := posting.File + "\x00" + strconv.Itoa(posting.Page)
+			if positions[i][key] == nil {
+				positions[i][key] = map[int]int{}
+			}
+			for _, position := range posting.Positions {
+				positions[i][key][position] = posting.Section
+			}
+		}
+	}
+	starts := map[string]map[int]int{}
+	for key, first := range positions[0] {
+		for position, section := range first {
+			found := true
+			for i := 1; i < len(phrase) && found; i++ {
+				_, found = positions[i][key][position+i]
+			}
+			if found {
+				if starts[key] == nil {
+					starts[key] = map[int]int{}
+				}
+				starts[key][position] = section
+			}
+		}
+	}
+	return starts
+}
+
+// snippet returns the text around a word of a page, with the phrase marked.
+// It is empty when the page text no longer has the phrase at position.
+func snippet(text string, tokens []pageToken, position, length int) string {
+	const context = 60
+	if length < 1 || position < 0 || position >= len(tokens) {
+		return ""
+	}
+	last := position + length - 1
+	if last >= len(tokens) {
+		last = len(tokens) - 1
+	}
+	start, end := tokens[position].start, tokens[last].end
+	from, to := start-context, end+context
+	if from < 0 {
+		from = 0
+	}
+	if to > len(text) {
+		to = len(text)
+	}
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+	result := text[from:start] + "[" + text[start:end] + "]" + text[end:to]
+	result = strings.Join(strings.Fields(result), " ")
+	if from > 0 {
+		result = "…" + result
+	}
+	if to < len(text) {
+		result += "…"
+	}
+	return result
+}
+
+// search returns the pages that contain every phrase, restricted to one kind
+// of document and one SDS section when those are given, most matches first
+func (index *SearchIndex) search(phrases [][]string, kind string, section int, dir string) []SearchResult {
+	pages := map[string]*pageMatch{}
+	for i, phrase := range phrases {
+		for key, starts := range index.phraseStarts(phrase) {
+			filename := key[:strings.IndexByte(key, 0)]
+			if kind != "" && index.Documents[filename].Kind != kind {
+				continue
+			}
+			if section > 0 {
+				for position, found := range starts {
+					if found != section {
+						delete(starts, position)
+					}
+				}
+				if len(starts) == 0 {
+					continue
+				}
+			}
+			match := pages[key]
+			if match == nil {
+				if i > 0 {
+					continue // Missing an earlier phrase
+				}
+				match = &pageMatch{starts: map[int]int{}}
+				pages[key] = match
+			}
+			if match.complete != i {
+				continue
+			}
+			match.complete++
+			for position, found := range starts {
+				match.starts[position] = found
+			}
+		}
+	}
+
+	var results []SearchResult
+	for key, match := range pages {
+		if match.complete != len(phrases) {
+			continue
+		}
+		filename := key[:strings.IndexByte(key, 0)]
+		page, _ := strconv.Atoi(key[len(filename)+1:])
+		result := SearchResult{File: filename, Kind: index.Documents[filename].Kind, Page: page, Matches: len(match.starts)}
+		var positions []int
+		for position, found := range match.starts {
+			positions = append(positions, position)
+			if found > 0 && !containsInt(result.Sections, found) {
+				result.Sections = append(result.Sections, found)
+			}
+		}
+		sort.Ints(positions)
+		sort.Ints(result.Sections)
+		// Snippets are cut from the cached page text at the first matches
+		if texts, err := pdfPagesText(filepath.Join(dir, filename)); err == nil && page <= len(texts) {
+			text := texts[page-1]
+			tokens := tokenizePage(text)
+			lengths := map[int]int{}
+			for _, phrase := range phrases {
+				for _, position := range findPhrase(phrase, tokens) {
+					lengths[position] = len(phrase)
+				}
+			}
+			// Hits the cached text no longer has, because the index is
+			// older than it, are skipped
+			for _, position := range positions {
+				if len(result.Snippets) == 2 {
+					break
+				}
+				if excerpt := snippet(text, tokens, position, lengths[position]); excerpt != "" {
+					result.Snippets = append(result.Snippets, excerpt)
+				}
+			}
+		}
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Matches != results[j].Matches {
+			return results[i].Matches > results[j].Matches
+		}
+		if results[i].File != results[j].File {
+			return results[i].File < results[j].File
+		}
+		return results[i].Page < results[j].Page
+	})
+	return results
+}
+
+// findPhrase returns where a phrase starts in the words of one page, for
+// sizing the highlighted part of a snippet
+func findPhrase(phrase []string, tokens []pageToken) []int {
+	var starts []int
+	for position := 0; position+len(phrase) <= len(tokens); position++ {
+		found := true
+		for i, term := range phrase {
+			if tokens[position+i].term != term {
+				found = false
+				break
+			}
+		}
+		if found {
+			starts = append(starts, position)
+		}
+	}
+	return starts
+}
+
+// containsInt reports whether list contains value
+func containsInt(list []int, value int) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// parseInterspersed parses flags that may come before or after the
+// positional arguments, so "search glycol --type sds" works
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// searchCommand implements "search": full-text search of the downloaded PDFs
+func searchCommand(args []string) {
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	kind := flags.String("type", "", "only search documents of this kind: sds, tds or other")
+	section := flags.Int("section", 0, "only search this section of safety data sheets, e.g. 8 for exposure controls")
+	limit := flags.Int("n", 20, "maximum number of pages to show")
+	asJSON := flags.Bool("json", false, "print the results as JSON")
+	rebuild := flags.Bool("rebuild", false, "rebuild the index from scratch")
+	dir := flags.String("dir", "PDFs/", "directory of downloaded PDFs")
+	query := parseInterspersed(flags, args)
+	phrases := parseSearchQuery(query)
+	if len(phrases) == 0 {
+		fmt.Fprintln(os.Stderr, `usage: go run . search [flags] <words or "a phrase">...`)
+		os.Exit(2)
+	}
+	if *section < 0 || *section > sdsSectionCount {
+		fmt.Fprintf(os.Stderr, "an SDS section is between 1 and %d\n", sdsSectionCount)
+		os.Exit(2)
+	}
+	if *section > 0 {
+		*kind = "sds"
+	}
+
+	index := loadSearchIndex(searchIndexPath)
+	if *rebuild {
+		index = &SearchIndex{Documents: map[string]*IndexedDocument{}, Terms: map[string][]IndexPosting{}}
+	}
+	if index.refresh(*dir) {
+		index.save(searchIndexPath)
+	}
+	results := index.search(phrases, strings.ToLower(*kind), *section, *dir)
+	total := len(results)
+	if *limit > 0 && len(results) > *limit {
+		results = results[:*limit]
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(results)
+		return
+	}
+	for _, result := range results {
+		location := fmt.Sprintf("%s, page %d", result.File, result.Page)
+		if len(result.Sections) > 0 {
+			var sections []string
+			for _, number := range result.Sections {
+				sections = append(sections, strconv.Itoa(number))
+			}
+			location += ", section " + strings.Join(sections, ", ")
+		}
+		fmt.Printf("%s (%d)\n", location, result.Matches)
+		for _, text := range result.Snippets {
+			fmt.Printf("    %s\n", text)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d pages match in %d indexed documents\n", total, len(index.Documents))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnippet(t *testing.T) {
+	text := "Kinematic viscosity at 40 °C is 46 cSt"
+	tokens := tokenizePage(text)
+	position := findPhrase([]string{"40", "c"}, tokens)
+	if len(position) != 1 {
+		t.Fatalf("phrase found at %v", position)
+	}
+	if got, want := snippet(text, tokens, position[0], 2), "Kinematic viscosity at [40 °C] is 46 cSt"; got != want {
+		t.Errorf("snippet = %q, want %q", got, want)
+	}
+	// An index older than the cached text can point at positions the text
+	// no longer has a phrase at, or past its end
+	for _, hit := range []struct{ position, length int }{{3, 0}, {len(tokens), 1}, {-1, 1}} {
+		if got := snippet(text, tokens, hit.position, hit.length); got != "" {
+			t.Errorf("snippet(%d, %d) = %q, want none", hit.position, hit.length, got)
+		}
+	}
+}
+
+// Pages of the fixture documents; the SDS runs its sections across pages
+const (
+	antifreezeSDSPage1 = "SAFETY DATA SHEET\nSECTION 1: Identification\nCAM2 Antifreeze Coolant\nSECTION 3: Composition\nEthylene glycol 107-21-1 90-100%"
+	antifreezeSDSPage2 = "Diethylene glycol 111-46-6 1-5%\nSECTION 8: Exposure controls\nEthylene glycol ceiling limit 100 mg/m3"
+	coolantTDSPage     = "CAM2 Global Coolant\nA glycol based coolant free of ethylene oxide and silicates"
+)
+
+// resultPages lists the file and page of each result
+func resultPages(results []SearchResult) []string {
+	var pages []string
+	for _, result := range results {
+		pages = append(pages, fmt.Sprintf("%s:%d", result.File, result.Page))
+	}
+	sort.Strings(pages)
+	return pages
+}
+
+func TestSearchIndex(t *testing.T) {
+	t.Chdir(t.TempDir()) // The index and text cache live in .cache/
+	dir := "PDFs"
+	os.Mkdir(dir, 0o755)
+	write := func(filename string, modified time.Time, pages ...string) {
+		path := filepath.Join(dir, filename)
+		if err := os.WriteFile(path, minimalPDF(pages...), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, modified, modified)
+	}
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	write("antifreeze_sds.pdf", start, antifreezeSDSPage1, antifreezeSDSPage2)
+	write("coolant_tds.pdf", start, coolantTDSPage)
+
+	index := loadSearchIndex(searchIndexPath)
+	if !index.refresh(dir) || len(index.Documents) != 2 {
+		t.Fatalf("first refresh indexed %d documents", len(index.Documents))
+	}
+	if index.refresh(dir) {
+		t.Error("refresh without changes reported a change")
+	}
+	search := func(kind string, section int, args ...string) []SearchResult {
+		return index.search(parseSearchQuery(args), kind, section, dir)
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		kind    string
+		section int
+		want    []string
+	}{
+		{"words", []string{"ethylene", "glycol"}, "", 0, []string{"antifreeze_sds.pdf:1", "antifreeze_sds.pdf:2", "coolant_tds.pdf:1"}},
+		// "Diethylene glycol" and "ethylene oxide" are not the phrase
+		{"phrase", []string{`"ethylene glycol"`}, "", 0, []string{"antifreeze_sds.pdf:1", "antifreeze_sds.pdf:2"}},
+		{"phrase as one argument", []string{"ethylene glycol"}, "", 0, []string{"antifreeze_sds.pdf:1", "antifreeze_sds.pdf:2"}},
+		{"CAS number", []string{"107-21-1"}, "", 0, []string{"antifreeze_sds.pdf:1"}},
+		{"kind", []string{"glycol"}, "tds", 0, []string{"coolant_tds.pdf:1"}},
+		{"section", []string{`"ethylene glycol"`}, "", 8, []string{"antifreeze_sds.pdf:2"}},
+		// Section 3 carries over onto the second page
+		{"section across pages", []string{"glycol"}, "", 3, []string{"antifreeze_sds.pdf:1", "antifreeze_sds.pdf:2"}},
+		{"section of a phrase elsewhere", []string{`"ethylene glycol"`}, "", 1, nil},
+		{"missing word", []string{"glycol", "propylene"}, "", 0, nil},
+	}
+	for _, test := range tests {
+		if got := resultPages(search(test.kind, test.section, test.args...)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+	results := search("", 8, `"ethylene glycol"`)
+	if len(results) != 1 || !reflect.DeepEqual(results[0].Sections, []int{8}) || len(results[0].Snippets) != 1 ||
+		!strings.Contains(results[0].Snippets[0], "[Ethylene glycol] ceiling limit") {
+		t.Errorf("section 8 result: %+v", results)
+	}
+
+	// Added, replaced and removed files
+	later := start.Add(24 * time.Hour)
+	write("heavy-duty-coolant_sds.pdf", later, "SECTION 3: Composition\nEthylene glycol 107-21-1")
+	write("coolant_tds.pdf", later, "CAM2 Global Coolant\nEthylene glycol based")
+	os.Remove(filepath.Join(dir, "antifreeze_sds.pdf"))
+	if !index.refresh(dir) {
+		t.Fatal("refresh missed the changes")
+	}
+	if got, want := resultPages(search("", 0, `"ethylene glycol"`)), []string{"coolant_tds.pdf:1", "heavy-duty-coolant_sds.pdf:1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after the changes: got %v, want %v", got, want)
+	}
+	if got := search("", 0, "silicates"); len(got) != 0 {
+		t.Errorf("words of the replaced version still found: %v", resultPages(got))
+	}
+	if _, ok := index.Documents["antifreeze_sds.pdf"]; ok || len(index.Terms["diethylene"]) != 0 {
+		t.Error("the removed file is still indexed")
+	}
+
+	// The saved index reads back the same
+	index.save(searchIndexPath)
+	if loaded := loadSearchIndex(searchIndexPath); !reflect.DeepEqual(loaded, index) || loaded.refresh(dir) {
+		t.Error("the saved index differs or is out of date")
+	}
+}
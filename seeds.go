@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	canonicalPattern  = regexp.MustCompile(`(?is)<link[^>]*rel=["']canonical["'][^>]*>`)
+	hrefPattern       = regexp.MustCompile(`(?is)href=["']([^"']*)["']`)
+	seedPrefixPattern = regexp.MustCompile(`^(?:ca2|cam-2|cam_2|cma2|cam22)-`)
+)
+
+// SeedCheck is what requesting one seed URL found
+type SeedCheck struct {
+	Seed      string   `json:"seed"`
+	Status    int      `json:"status"` // 0 when no response arrived
+	Error     string   `json:"error,omitempty"`
+	Redirects []string `json:"redirects,omitempty"` // Each URL redirected to, in order
+	FinalURL  string   `json:"final_url"`
+	Canonical string   `json:"canonical,omitempty"`
+	PDFs      int      `json:"pdfs"`
+	Proposed  string   `json:"proposed,omitempty"` // Seed to use instead
+	Problems  []string `json:"problems,omitempty"`
+}
+
+// ok reports whether the seed needs no attention
+func (c *SeedCheck) ok() bool {
+	return len(c.Problems) == 0
+}
+
+// fetchSeed requests a seed, following and recording redirects
+func fetchSeed(client *http.Client, seed string) (check *SeedCheck, body string) {
+	check = &SeedCheck{Seed: seed, FinalURL: seed}
+	request, err := http.NewRequest("GET", seed, nil)
+	if err != nil {
+		check.Error = err.Error()
+		return check, ""
+	}
+	request.Header.Set("User-Agent", userAgent)
+
+	// Copy the client so the redirect hook only sees this request
+	seedClient := *client
+	seedClient.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after %d redirects", len(via))
+		}
+		check.Redirects = append(check.Redirects, next.URL.String())
+		return nil
+	}
+	response, err := seedClient.Do(request)
+	if err != nil {
+		check.Error = err.Error()
+		return check, ""
+	}
+	defer response.Body.Close()
+	check.Status = response.StatusCode
+	check.FinalURL = response.Request.URL.String()
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		check.Error = err.Error()
+	}
+	return check, string(content)
+}
+
+// canonicalURL returns the target of a page's <link rel="canonical">,
+// resolved against the page URL
+func canonicalURL(pageURL, htmlContent string) string {
+	tag := canonicalPattern.FindString(htmlContent)
+	if tag == "" {
+		return ""
+	}
+	match := hrefPattern.FindStringSubmatch(tag)
+	if match == nil {
+		return ""
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	reference, err := url.Parse(strings.TrimSpace(html.UnescapeString(match[1])))
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(reference).String()
+}
+
+// sameURL compares URLs ignoring a trailing slash
+func sameURL(a, b string) bool {
+	return strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+}
+
+// correctedSlugURL rewrites a product URL whose slug starts with a
+// misspelling of "cam2-", such as ca2- or cam-2-; it returns "" when the
+// slug looks right
+func correctedSlugURL(seed string) string {
+	slug := productSlug(seed)
+	if slug == "" || !seedPrefixPattern.MatchString(slug) {
+		return ""
+	}
+	corrected := seedPrefixPattern.ReplaceAllString(slug, "cam2-")
+	return strings.Replace(seed, "/"+slug, "/"+corrected, 1)
+}
+
+// checkSeed requests a seed and works out what is wrong with it and which
+// URL should replace it
+func checkSeed(client *http.Client, seed string) *SeedCheck {
+	check, body := fetchSeed(client, seed)
+	switch {
+	case check.Status == 0:
+		check.Problems = append(check.Problems, "no response")
+	case check.Status < 200 || check.Status > 299:
+		check.Problems = append(check.Problems, fmt.Sprintf("status %d", check.Status))
+	default:
+		check.Canonical = canonicalURL(check.FinalURL, body)
+		check.PDFs = len(removeDuplicatesFromSlice(extractPDFUrls(body)))
+		if check.PDFs == 0 {
+			check.Problems = append(check.Problems, "no PDF links")
+		}
+	}
+	if len(check.Redirects) > 0 {
+		check.Problems = append(check.Problems, fmt.Sprintf("%d redirect(s)", len(check.Redirects)))
+	}
+
+	// Prefer what the site says the page is called over a guess
+	switch {
+	case check.Canonical != "" && !sameURL(check.Canonical, seed):
+		check.Proposed = check.Canonical
+		check.Problems = append(check.Problems, "canonical URL differs")
+	case check.Status >= 200 && check.Status <= 299 && !sameURL(check.FinalURL, seed):
+		check.Proposed = check.FinalURL
+	}
+	if corrected := correctedSlugURL(seed); corrected != "" {
+		check.Problems = append(check.Problems, "misspelled slug prefix")
+		if check.Proposed == "" {
+			// Only propose the guess when the corrected page exists
+			guess, _ := fetchSeed(client, corrected)
+			if guess.Status >= 200 && guess.Status <= 299 {
+				check.Proposed = guess.FinalURL
+			} else {
+				log.Printf("Corrected seed %s does not exist either (status %d)", corrected, guess.Status)
+			}
+		}
+	}
+	return check
+}
+
+// checkSeedsCommand implements "check-seeds": request every seed and report
+// broken ones, redirects, canonical URLs, misspelled slugs and pages
+// without PDF links, with the seeds to use instead
+func checkSeedsCommand(args []string) {
+	flags := flag.NewFlagSet("check-seeds", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print every check as JSON")
+	all := flags.Bool("all", false, "also list seeds without problems")
+	delay := flags.Duration("delay", 500*time.Millisecond, "pause between requests")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of each request")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: check-seeds [flags] [url ...]")
+		fmt.Fprintln(flags.Output(), "Checks the given URLs, or every seed of the crawler.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	seeds := flags.Args()
+	if len(seeds) == 0 {
+		seeds = remoteURL
+	}
+	client := &http.Client{Timeout: *timeout, Transport: httpTransport}
+	var checks []*SeedCheck
+	problems := 0
+	for i, seed := range seeds {
+		if i > 0 {
+			time.Sleep(*delay)
+		}
+		check := checkSeed(client, seed)
+		if !check.ok() {
+			problems++
+		}
+		checks = append(checks, check)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(checks)
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "STATUS\tPDFS\tSEED\tPROBLEMS\tPROPOSED")
+		for _, check := range checks {
+			if check.ok() && !*all {
+				continue
+			}
+			status := fmt.Sprint(check.Status)
+			if check.Status == 0 {
+				status = "-"
+			}
+			problemText := strings.Join(check.Problems, ", ")
+			if problemText == "" {
+				problemText = "ok"
+			}
+			fmt.Fprintf(writer, "%s\t%d\t%s\t%s\t%s\n", status, check.PDFs, check.Seed, problemText, check.Proposed)
+			for _, redirect := range check.Redirects {
+				fmt.Fprintf(writer, "\t\t  → %s\t\t\n", redirect)
+			}
+		}
+		writer.Flush()
+		fmt.Printf("%d of %d seeds need attention.\n", problems, len(checks))
+	}
+	if problems > 0 {
+		os.Exit(1)
+	}
+}
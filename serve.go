@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mirrorServer answers API requests from the local PDFs and manifest; it
+// never fetches anything from cam2.com
+type mirrorServer struct {
+	dir      string
+	manifest *Manifest
+	catalog  []*Product
+	index    *SearchIndex
+}
+
+// productSummary is a product in the product list
+type productSummary struct {
+	Slug      string   `json:"slug"`
+	Title     string   `json:"title,omitempty"`
+	URL       string   `json:"url,omitempty"`
+	Grades    []string `json:"grades,omitempty"`
+	Documents []string `json:"documents,omitempty"`
+	Withdrawn string   `json:"withdrawn,omitempty"` // Date the product page disappeared
+	Taxonomy
+}
+
+// DocumentMetadata is what the API reports about one PDF
+type DocumentMetadata struct {
+	Filename     string   `json:"filename"`
+	Kind         string   `json:"kind"`
+	PartNumber   string   `json:"part_number,omitempty"`
+	SourceURL    string   `json:"source_url,omitempty"`
+	Products     []string `json:"products,omitempty"`
+	Size         int64    `json:"size"`
+	Modified     string   `json:"modified"`
+	Pages        int      `json:"pages,omitempty"`
+	RevisionDate string   `json:"revision_date,omitempty"` // YYYY-MM-DD
+	Withdrawn    string   `json:"withdrawn,omitempty"`     // Date no page linked it any more
+	Download     string   `json:"download"`
+}
+
+// writeJSON sends value as an indented JSON response
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		log.Println(err)
+	}
+}
+
+// writeError sends a JSON error message
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// localPDF returns the path of a PDF in the mirror, refusing names that
+// would reach outside it
+func (s *mirrorServer) localPDF(filename string) (string, os.FileInfo, bool) {
+	filename = strings.ToLower(filename)
+	if filename != filepath.Base(filename) || !strings.HasSuffix(filename, ".pdf") {
+		return "", nil, false
+	}
+	path := filepath.Join(s.dir, filename)
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return "", nil, false
+	}
+	return path, info, true
+}
+
+// documentMetadata describes one PDF of the mirror
+func (s *mirrorServer) documentMetadata(filename string) (DocumentMetadata, bool) {
+	path, info, ok := s.localPDF(filename)
+	if !ok {
+		return DocumentMetadata{}, false
+	}
+	filename = getFilename(path)
+	metadata := DocumentMetadata{
+		Filename:   filename,
+		Kind:       documentKind(filename),
+		PartNumber: partNumberFromFilename(filename),
+		Size:       info.Size(),
+		Modified:   info.ModTime().UTC().Format(time.RFC3339),
+		Download:   "/pdf/" + filename,
+	}
+	if document := s.manifest.Documents[filename]; document != nil {
+		metadata.Kind, metadata.SourceURL, metadata.Products = document.Kind, document.SourceURL, document.Products
+		metadata.Withdrawn = document.Withdrawn
+		if document.PartNumber != "" {
+			metadata.PartNumber = document.PartNumber
+		}
+	}
+	if pages, err := pdfPagesText(path); err == nil {
+		metadata.Pages = len(pages)
+		if date, ok := documentRevisionDate(strings.Join(pages, "\n\f\n")); ok {
+			metadata.RevisionDate = date.Format("2006-01-02")
+		}
+	}
+	return metadata, true
+}
+
+// summarize lists a product with its classification
+func summarize(product *Product) productSummary {
+	return productSummary{
+		Slug:      product.Slug,
+		Title:     product.Title,
+		URL:       product.URL,
+		Grades:    product.Grades.labels(),
+		Documents: product.Documents,
+		Withdrawn: product.Withdrawn,
+		Taxonomy:  product.taxonomy(),
+	}
+}
+
+// handleProducts lists the catalog, filtered by ?category=, ?brand= and
+// ?application=
+func (s *mirrorServer) handleProducts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := Taxonomy{Category: query.Get("category"), BrandLine: query.Get("brand"), Application: query.Get("application")}
+	products := []productSummary{}
+	for _, product := range s.catalog {
+		if product.matchesTaxonomy(filter) {
+			products = append(products, summarize(product))
+		}
+	}
+	writeJSON(w, http.StatusOK, products)
+}
+
+// product looks up the product named in the request path
+func (s *mirrorServer) product(w http.ResponseWriter, r *http.Request) *Product {
+	product := findProduct(s.catalog, s.manifest, s.dir, r.PathValue("key"))
+	if product == nil {
+		writeError(w, http.StatusNotFound, "no product or document matches "+strconv.Quote(r.PathValue("key")))
+	}
+	return product
+}
+
+// handleProduct returns one product, by slug or part number, with the
+// metadata of its documents and what the product page says about it
+func (s *mirrorServer) handleProduct(w http.ResponseWriter, r *http.Request) {
+	product := s.product(w, r)
+	if product == nil {
+		return
+	}
+	documents := []DocumentMetadata{}
+	for _, filename := range product.Documents {
+		if metadata, ok := s.documentMetadata(filename); ok {
+			documents = append(documents, metadata)
+		}
+	}
+	writeJSON(w, http.StatusOK, struct {
+		productSummary
+		Description    string             `json:"description,omitempty"`
+		SKU            string             `json:"sku,omitempty"`
+		Sizes          []PackageSize      `json:"sizes,omitempty"`
+		Specifications []string           `json:"specifications,omitempty"`
+		Approvals      []string           `json:"approvals,omitempty"`
+		PartNumbers    []string           `json:"part_numbers,omitempty"`
+		Documents      []DocumentMetadata `json:"documents"`
+	}{summarize(product), product.Description, product.SKU, product.Sizes, product.Specifications,
+		product.Approvals, product.partNumbers(s.manifest), documents})
+}
+
+// handleHazards returns the GHS classification from a product's safety
+// data sheets
+func (s *mirrorServer) handleHazards(w http.ResponseWriter, r *http.Request) {
+	product := s.product(w, r)
+	if product == nil {
+		return
+	}
+	sheets := []SafetyDataSheet{}
+	for _, filename := range product.documentsOfKind(s.manifest, "sds") {
+		if path, _, ok := s.localPDF(filename); ok {
+			if sheet, err := loadSafetyDataSheet(path); err == nil {
+				sheets = append(sheets, sheet)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, sheets)
+}
+
+// handleProperties returns the typical properties from a product's
+// technical data sheets, with the column that describes the product itself
+func (s *mirrorServer) handleProperties(w http.ResponseWriter, r *http.Request) {
+	product := s.product(w, r)
+	if product == nil {
+		return
+	}
+	details := loadProductDetails(product, s.manifest, s.dir)
+	sheets := []TDSSheet{}
+	for _, filename := range product.documentsOfKind(s.manifest, "tds") {
+		if path, _, ok := s.localPDF(filename); ok {
+			if text, err := pdfText(path); err == nil {
+				if columns := parseTDSText(text); len(columns) > 0 {
+					sheets = append(sheets, TDSSheet{File: filename, Columns: columns})
+				}
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Product   *TDSColumn `json:"product"` // null when no column matches the product
+		Approvals []string   `json:"approvals,omitempty"`
+		Sheets    []TDSSheet `json:"sheets"`
+	}{details.column, details.approvals, sheets})
+}
+
+// handleDocuments lists the PDFs of the mirror, filtered by ?type=
+func (s *mirrorServer) handleDocuments(w http.ResponseWriter, r *http.Request) {
+	kind := r.URL.Query().Get("type")
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.pdf"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	documents := []DocumentMetadata{}
+	for _, path := range paths {
+		if metadata, ok := s.documentMetadata(getFilename(path)); ok && (kind == "" || metadata.Kind == kind) {
+			documents = append(documents, metadata)
+		}
+	}
+	writeJSON(w, http.StatusOK, documents)
+}
+
+// handleDocument returns the metadata of one PDF
+func (s *mirrorServer) handleDocument(w http.ResponseWriter, r *http.Request) {
+	metadata, ok := s.documentMetadata(r.PathValue("filename"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "no document named "+strconv.Quote(r.PathValue("filename")))
+		return
+	}
+	writeJSON(w, http.StatusOK, metadata)
+}
+
+// handlePDF streams a PDF; http.ServeContent answers Range requests and
+// conditional requests against the ETag
+func (s *mirrorServer) handlePDF(w http.ResponseWriter, r *http.Request) {
+	path, info, ok := s.localPDF(r.PathValue("filename"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "no document named "+strconv.Quote(r.PathValue("filename")))
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// handleSearch runs a full-text search: ?q= with optional &type=, &section=
+// and &n=
+func (s *mirrorServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	phrases := parseSearchQuery([]string{query.Get("q")})
+	if len(phrases) == 0 {
+		writeError(w, http.StatusBadRequest, "missing query parameter q")
+		return
+	}
+	kind := strings.ToLower(query.Get("type"))
+	section, limit := 0, 20
+	if value := query.Get("section"); value != "" {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 || number > sdsSectionCount {
+			writeError(w, http.StatusBadRequest, "section must be a number from 1 to 16")
+			return
+		}
+		section, kind = number, "sds"
+	}
+	if value := query.Get("n"); value != "" {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 {
+			writeError(w, http.StatusBadRequest, "n must be a positive number")
+			return
+		}
+		limit = number
+	}
+	results := s.index.search(phrases, kind, section, s.dir)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	if results == nil {
+		results = []SearchResult{}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// logRequests logs each request with its status and duration
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		log.Printf("%s %s (%s)", r.Method, r.URL.RequestURI(), time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// serveCommand implements "serve": a JSON API over the local mirror
+func serveCommand(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	address := flags.String("addr", "127.0.0.1:8080", "address to listen on; the default only accepts local connections")
+	dir := flags.String("dir", "PDFs/", "directory of downloaded PDFs")
+	manifestFile := flags.String("manifest", manifestPath, "manifest written by the crawler")
+	flags.Parse(args)
+
+	server := &mirrorServer{dir: *dir, manifest: loadManifest(*manifestFile)}
+	server.catalog = loadCatalog(server.manifest, *dir)
+	server.index = loadSearchIndex(searchIndexPath)
+	if server.index.refresh(*dir) {
+		server.index.save(searchIndexPath)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/products", server.handleProducts)
+	mux.HandleFunc("GET /api/products/{key}", server.handleProduct)
+	mux.HandleFunc("GET /api/products/{key}/hazards", server.handleHazards)
+	mux.HandleFunc("GET /api/products/{key}/properties", server.handleProperties)
+	mux.HandleFunc("GET /api/documents", server.handleDocuments)
+	mux.HandleFunc("GET /api/documents/{filename}", server.handleDocument)
+	mux.HandleFunc("GET /api/search", server.handleSearch)
+	mux.HandleFunc("GET /pdf/{filename}", server.handlePDF)
+
+	log.Printf("Serving %d products and %d documents on http://%s/api/products", len(server.catalog), len(server.index.Documents), *address)
+	if err := http.ListenAndServe(*address, logRequests(mux)); err != nil {
+		log.Fatalln(err)
+	}
+}
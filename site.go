@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// siteDocument is one PDF listed on a product page
+type siteDocument struct {
+	Filename string
+	Kind     string
+	Label    string // "Safety data sheet", "Technical data sheet"...
+	Revision string // YYYY-MM-DD, empty when the sheet has no date
+	Href     string
+}
+
+// siteProduct is everything shown on one product page
+type siteProduct struct {
+	Product     *Product
+	Name        string
+	Page        string // Path of the page relative to the site root
+	Grades      []string
+	PartNumbers []string
+	Approvals   []string
+	Properties  [][2]string
+	SignalWord  string
+	Hazards     []string // Hazard statements with their wording
+	Classes     []string
+	Documents   []siteDocument
+}
+
+// siteCategory is one group of the index page
+type siteCategory struct {
+	Name     string
+	Products []*siteProduct
+}
+
+// siteSearchEntry is one product in the client-side search index
+type siteSearchEntry struct {
+	Name     string `json:"n"`
+	Page     string `json:"u"`
+	Category string `json:"c"`
+	Keywords string `json:"k"` // Grades, part numbers, specs and hazard codes
+}
+
+// documentLabels name the kinds of document on product pages
+var documentLabels = map[string]string{"sds": "Safety data sheet", "tds": "Technical data sheet", "other": "Document"}
+
+// siteProducts returns the catalog plus one product for each part number
+// whose documents no product page links to, so every PDF has a page
+func siteProducts(catalog []*Product, manifest *Manifest, dir string) []*Product {
+	products := append([]*Product{}, catalog...)
+	linked := map[string]bool{}
+	for _, product := range catalog {
+		for _, filename := range product.Documents {
+			linked[filename] = true
+		}
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pdf"))
+	if err != nil {
+		log.Println(err)
+	}
+	sort.Strings(paths)
+	seen := map[string]bool{}
+	for _, path := range paths {
+		filename := getFilename(path)
+		number := partNumberFromFilename(filename)
+		if number == "" {
+			number = strings.TrimSuffix(filename, ".pdf")
+		}
+		if linked[filename] || seen[number] {
+			continue
+		}
+		seen[number] = true
+		product := findProduct(catalog, manifest, dir, filename)
+		if product == nil || containsProduct(products, product) {
+			continue
+		}
+		product.Slug = strings.ReplaceAll(number, "_", "-")
+		product.Title = "Part " + product.Slug
+		for _, sds := range product.documentsOfKind(manifest, "sds") {
+			if sheet, err := loadSafetyDataSheet(filepath.Join(dir, sds)); err == nil && sheet.ProductName != "" {
+				product.Title = sheet.ProductName
+				break
+			}
+		}
+		products = append(products, product)
+	}
+	return products
+}
+
+// containsProduct reports whether list already holds product
+func containsProduct(list []*Product, product *Product) bool {
+	for _, item := range list {
+		if item == product {
+			return true
+		}
+	}
+	return false
+}
+
+// buildSiteProduct gathers what a product page shows
+func buildSiteProduct(product *Product, manifest *Manifest, dir, pdfBase string) *siteProduct {
+	details := loadProductDetails(product, manifest, dir)
+	page := &siteProduct{
+		Product:     product,
+		Name:        product.name(),
+		Page:        "products/" + product.Slug + ".html",
+		Grades:      product.Grades.labels(),
+		PartNumbers: details.partNumbers,
+		Approvals:   details.approvals,
+	}
+	for _, approval := range product.Approvals {
+		page.Approvals = appendUnique(page.Approvals, approval)
+	}
+	sort.Strings(page.Approvals)
+	for _, key := range []struct{ key, label string }{
+		{propertyKV40, "Viscosity at 40 °C, cSt"}, {propertyKV100, "Viscosity at 100 °C, cSt"},
+		{propertyVI, "Viscosity index"}, {propertyFlashPoint, "Flash point, °C"}, {propertyPourPoint, "Pour point, °C"},
+	} {
+		if value := formatProperty(details.column, key.key); len(value) > 0 {
+			page.Properties = append(page.Properties, [2]string{key.label, value[0]})
+		}
+	}
+	revisions := map[string]string{}
+	for _, sheet := range details.sheets {
+		revisions[sheet.File] = sheet.RevisionDate
+		if sheet.Hazards.SignalWord != "" && page.SignalWord != "Danger" {
+			page.SignalWord = sheet.Hazards.SignalWord
+		}
+		for _, code := range sheet.Hazards.Statements {
+			page.Hazards = appendUnique(page.Hazards, describeHazardStatement(code))
+		}
+		for _, class := range sheet.Hazards.Classes {
+			page.Classes = appendUnique(page.Classes, class)
+		}
+	}
+	documents := append([]string{}, product.Documents...)
+	sort.Strings(documents)
+	for _, filename := range documents {
+		kind := documentKind(filename)
+		if document := manifest.Documents[filename]; document != nil {
+			kind = document.Kind
+		}
+		revision, ok := revisions[filename]
+		if !ok {
+			if text, err := pdfText(filepath.Join(dir, filename)); err == nil {
+				if date, found := documentRevisionDate(text); found {
+					revision = date.Format("2006-01-02")
+				}
+			}
+		}
+		page.Documents = append(page.Documents, siteDocument{
+			Filename: filename,
+			Kind:     kind,
+			Label:    documentLabels[kind],
+			Revision: revision,
+			Href:     pdfBase + filename,
+		})
+	}
+	return page
+}
+
+// categoryAnchor turns a category name into a fragment identifier, e.g.
+// "aerosols & chemicals" → "aerosols-chemicals"
+func categoryAnchor(category string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(category), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "-")
+}
+
+// siteTemplateFunctions are available to the page templates
+var siteTemplateFunctions = template.FuncMap{"anchor": categoryAnchor}
+
+// siteStyle is shared by every page of the site
+const siteStyle = `body { font-family: sans-serif; margin: 0 auto; max-width: 60em; padding: 1em 2em; line-height: 1.4; }
+a { color: #1d4ed8; }
+h2 { border-bottom: 1px solid #ddd; margin-top: 2em; }
+ul.products { list-style: none; padding: 0; }
+ul.products li { padding: 0.2em 0; }
+.meta { color: #666; font-size: 0.9em; }
+.Danger { background: #b91c1c; color: #fff; padding: 0 0.4em; border-radius: 3px; }
+.Warning { background: #d97706; color: #fff; padding: 0 0.4em; border-radius: 3px; }
+.withdrawn { background: #e5e7eb; color: #374151; padding: 0 0.4em; border-radius: 3px; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+#search { font-size: 1.1em; padding: 0.4em; width: 100%; box-sizing: border-box; }
+`
+
+// siteIndexTemplate is the home page: a search box and the products by
+// category
+var siteIndexTemplate = template.Must(template.New("index").Funcs(siteTemplateFunctions).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>CAM2 safety and technical data sheets</title>
+<link rel="stylesheet" href="style.css">
+</head>
+<body>
+<h1>CAM2 safety and technical data sheets</h1>
+<p>{{len .Products}} products in {{len .Categories}} categories. Search by name, grade, specification, part number or hazard statement code.</p>
+<input id="search" type="search" placeholder="e.g. 15W-40, CK-4, 80565-124, H304" autocomplete="off">
+<ul id="results" class="products"></ul>
+<div id="categories">
+{{range .Categories}}<h2 id="{{anchor .Name}}">{{.Name}}</h2>
+<ul class="products">
+{{range .Products}}<li><a href="{{.Page}}">{{.Name}}</a>{{if .Product.Withdrawn}} <span class="withdrawn">Withdrawn {{.Product.Withdrawn}}</span>{{end}}{{if .SignalWord}} <span class="{{.SignalWord}}">{{.SignalWord}}</span>{{end}}{{if .Grades}} <span class="meta">{{range $i, $g := .Grades}}{{if $i}}, {{end}}{{$g}}{{end}}</span>{{end}}</li>
+{{end}}</ul>
+{{end}}</div>
+<script src="search-index.js"></script>
+<script src="search.js"></script>
+</body>
+</html>
+`))
+
+// siteProductTemplate is the page of one product
+var siteProductTemplate = template.Must(template.New("product").Funcs(siteTemplateFunctions).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Name}} – CAM2 data sheets</title>
+<link rel="stylesheet" href="../style.css">
+</head>
+<body>
+<p><a href="../index.html">All products</a> › <a href="../index.html#{{anchor .Product.Category}}">{{.Product.Category}}</a></p>
+<h1>{{.Name}}</h1>
+{{if .Product.Withdrawn}}<p><span class="withdrawn">Withdrawn {{.Product.Withdrawn}}</span> CAM2 no longer publishes this product; the data sheets below are the last ones it did.</p>
+{{end}}<p class="meta">{{.Product.BrandLine}} · {{.Product.Application}}{{if .PartNumbers}} · Part {{range $i, $n := .PartNumbers}}{{if $i}}, {{end}}{{$n}}{{end}}{{end}}{{if .Product.URL}} · <a href="{{.Product.URL}}">cam2.com</a>{{end}}</p>
+{{if .Product.Description}}<p>{{.Product.Description}}</p>
+{{end}}{{if .Grades}}<p><strong>Grades:</strong> {{range $i, $g := .Grades}}{{if $i}}, {{end}}{{$g}}{{end}}</p>
+{{end}}{{if .Approvals}}<p><strong>Specifications:</strong> {{range $i, $a := .Approvals}}{{if $i}}, {{end}}{{$a}}{{end}}</p>
+{{end}}
+<h2>Data sheets</h2>
+{{if .Documents}}<table>
+<tr><th>Document</th><th>Revision date</th><th>File</th></tr>
+{{range .Documents}}<tr><td>{{.Label}}</td><td>{{if .Revision}}{{.Revision}}{{else}}unknown{{end}}</td><td><a href="{{.Href}}">{{.Filename}}</a></td></tr>
+{{end}}</table>
+{{else}}<p>No data sheets have been downloaded for this product.</p>
+{{end}}
+<h2>Hazards</h2>
+{{if .SignalWord}}<p>Signal word: <span class="{{.SignalWord}}">{{.SignalWord}}</span></p>
+{{else if .Documents}}<p>The safety data sheet gives no signal word.</p>
+{{end}}{{if .Hazards}}<ul>
+{{range .Hazards}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{if .Classes}}<p class="meta">Classification: {{range $i, $c := .Classes}}{{if $i}}; {{end}}{{$c}}{{end}}</p>
+{{end}}<p class="meta">Always read the full safety data sheet before use.</p>
+{{if .Properties}}
+<h2>Typical properties</h2>
+<table>
+{{range .Properties}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// siteSearchScript filters the product list as the user types; every word
+// typed must appear in a product's name or keywords
+const siteSearchScript = `(function () {
+  var input = document.getElementById("search");
+  var results = document.getElementById("results");
+  var categories = document.getElementById("categories");
+  function normalize(text) { return text.toLowerCase().replace(/[^a-z0-9]+/g, " "); }
+  var entries = searchIndex.map(function (entry) {
+    return { entry: entry, text: " " + normalize(entry.n + " " + entry.c + " " + entry.k) + " " };
+  });
+  input.addEventListener("input", function () {
+    var words = normalize(input.value).split(" ").filter(Boolean);
+    results.innerHTML = "";
+    categories.style.display = words.length ? "none" : "";
+    if (!words.length) { return; }
+    entries.filter(function (item) {
+      return words.every(function (word) { return item.text.indexOf(" " + word) >= 0; });
+    }).slice(0, 100).forEach(function (item) {
+      var li = document.createElement("li");
+      var a = document.createElement("a");
+      a.href = item.entry.u;
+      a.textContent = item.entry.n;
+      li.appendChild(a);
+      li.appendChild(document.createTextNode(" – " + item.entry.c));
+      results.appendChild(li);
+    });
+  });
+})();
+`
+
+// writeFileIfChanged writes a file only when its content differs, so that
+// regenerating the site leaves unchanged files alone
+func writeFileIfChanged(path string, content []byte) bool {
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, content) {
+		return false
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Println(err)
+		return false
+	}
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		log.Println(err)
+		return false
+	}
+	return true
+}
+
+// siteCommand implements "site": a static website of the products and their
+// data sheets
+func siteCommand(args []string) {
+	flags := flag.NewFlagSet("site", flag.ExitOnError)
+	output := flags.String("o", "site", "directory to write the site to")
+	pdfBase := flags.String("pdf-base", "", "URL prefix of the PDFs; by default a relative path from the product pages to -dir")
+	dir := flags.String("dir", "PDFs/", "directory of downloaded PDFs")
+	manifestFile := flags.String("manifest", manifestPath, "manifest written by the crawler")
+	flags.Parse(args)
+
+	if *pdfBase == "" {
+		products, err := filepath.Abs(filepath.Join(*output, "products"))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		pdfs, err := filepath.Abs(*dir)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		relative, err := filepath.Rel(products, pdfs)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		*pdfBase = filepath.ToSlash(relative) + "/"
+	}
+
+	manifest := loadManifest(*manifestFile)
+	catalog := loadCatalog(manifest, *dir)
+	byCategory := map[string]*siteCategory{}
+	var pages []*siteProduct
+	var searchIndex []siteSearchEntry
+	for _, product := range siteProducts(catalog, manifest, *dir) {
+		page := buildSiteProduct(product, manifest, *dir, *pdfBase)
+		pages = append(pages, page)
+		category := byCategory[product.Category]
+		if category == nil {
+			category = &siteCategory{Name: product.Category}
+			byCategory[product.Category] = category
+		}
+		category.Products = append(category.Products, page)
+		keywords := append(append(append([]string{product.Slug}, page.Grades...), page.PartNumbers...), page.Approvals...)
+		if product.Withdrawn != "" {
+			keywords = append(keywords, "withdrawn")
+		}
+		for _, statement := range page.Hazards {
+			keywords = append(keywords, strings.SplitN(statement, " ", 2)[0])
+		}
+		searchIndex = append(searchIndex, siteSearchEntry{
+			Name:     page.Name,
+			Page:     page.Page,
+			Category: product.Category,
+			Keywords: strings.Join(keywords, " "),
+		})
+	}
+	// Sorting everything keeps the output the same from run to run
+	var categories []*siteCategory
+	for _, category := range byCategory {
+		sort.Slice(category.Products, func(i, j int) bool {
+			a, b := category.Products[i], category.Products[j]
+			if strings.ToLower(a.Name) != strings.ToLower(b.Name) {
+				return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+			}
+			return a.Page < b.Page
+		})
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	sort.Slice(searchIndex, func(i, j int) bool { return searchIndex[i].Page < searchIndex[j].Page })
+
+	written := 0
+	write := func(name string, content []byte) {
+		if writeFileIfChanged(filepath.Join(*output, name), content) {
+			written++
+		}
+	}
+	render := func(name string, tmpl *template.Template, data interface{}) {
+		var buffer bytes.Buffer
+		if err := tmpl.Execute(&buffer, data); err != nil {
+			log.Printf("Failed to render %s: %v", name, err)
+			return
+		}
+		write(name, buffer.Bytes())
+	}
+	render("index.html", siteIndexTemplate, struct {
+		Products   []*siteProduct
+		Categories []*siteCategory
+	}{pages, categories})
+	keep := map[string]bool{}
+	for _, page := range pages {
+		render(page.Page, siteProductTemplate, page)
+		keep[filepath.Base(page.Page)] = true
+	}
+	indexJSON, err := json.Marshal(searchIndex)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	write("search-index.js", []byte("var searchIndex = "+string(indexJSON)+";\n"))
+	write("search.js", []byte(siteSearchScript))
+	write("style.css", []byte(siteStyle))
+
+	// Pages of products that are gone are removed
+	removed := 0
+	old, err := filepath.Glob(filepath.Join(*output, "products", "*.html"))
+	if err != nil {
+		log.Println(err)
+	}
+	for _, path := range old {
+		if !keep[filepath.Base(path)] {
+			removeFile(path)
+			removed++
+		}
+	}
+	fmt.Printf("%d products in %d categories; %d files written, %d removed in %s\n", len(pages), len(categories), written, removed, *output)
+}
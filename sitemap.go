@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// crawlStatePath records the sitemap lastmod of every page as of the last
+// successful crawl
+const crawlStatePath = "crawl-state.json"
+
+// sitemapIndexes are tried in order; Yoast SEO publishes the first one and
+// WordPress itself the second
+var sitemapIndexes = []string{"/sitemap_index.xml", "/wp-sitemap.xml", "/sitemap.xml"}
+
+// sitemapDocument is either a sitemap index or a list of URLs
+type sitemapDocument struct {
+	XMLName  xml.Name `xml:""`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+	URLs []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"url"`
+}
+
+// CrawlState is what the crawler remembers between runs
+type CrawlState struct {
+	LastRun string            `json:"last_run,omitempty"` // RFC 3339 time of the last successful crawl
+	LastMod map[string]string `json:"lastmod"`            // Page URL → lastmod when it was last fetched
+}
+
+// pageKey normalizes a page URL for lookups, ignoring a trailing slash
+func pageKey(pageURL string) string {
+	return strings.TrimSuffix(strings.TrimSpace(pageURL), "/")
+}
+
+// loadCrawlState reads the crawl state, returning an empty one if it is
+// missing
+func loadCrawlState(path string) *CrawlState {
+	state := &CrawlState{LastMod: map[string]string{}}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Println(err)
+		}
+		return state
+	}
+	if err := json.Unmarshal(content, state); err != nil {
+		log.Printf("Failed to parse %s: %v", path, err)
+	}
+	if state.LastMod == nil {
+		state.LastMod = map[string]string{}
+	}
+	return state
+}
+
+// save writes the crawl state
+func (s *CrawlState) save(path string) {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		log.Println(err)
+	}
+}
+
+// readSitemaps returns the lastmod of every page in the site's sitemaps,
+// following sitemap indexes. It returns nil when the site has none.
+func readSitemaps(remoteDomainName string) map[string]string {
+	for _, index := range sitemapIndexes {
+		lastMods := map[string]string{}
+		if readSitemap(remoteDomainName+index, lastMods, 0) {
+			log.Printf("Read %d pages from %s", len(lastMods), remoteDomainName+index)
+			return lastMods
+		}
+	}
+	log.Println("No sitemap found; every page will be fetched")
+	return nil
+}
+
+// readSitemap adds the pages of one sitemap to lastMods and reports whether
+// it could be read
+func readSitemap(sitemapURL string, lastMods map[string]string, depth int) bool {
+	if depth > 3 {
+		return false
+	}
+	content := getDataFromURL(sitemapURL)
+	if content == "" {
+		return false
+	}
+	var sitemap sitemapDocument
+	if err := xml.Unmarshal([]byte(content), &sitemap); err != nil {
+		log.Printf("Failed to parse sitemap %s: %v", sitemapURL, err)
+		return false
+	}
+	switch sitemap.XMLName.Local {
+	case "sitemapindex":
+		for _, child := range sitemap.Sitemaps {
+			readSitemap(strings.TrimSpace(child.Loc), lastMods, depth+1)
+		}
+	case "urlset":
+		for _, page := range sitemap.URLs {
+			lastMods[pageKey(page.Loc)] = strings.TrimSpace(page.LastMod)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// unchanged reports whether a page can be skipped: the sitemap lists it with
+// the same lastmod as when it was last fetched
+func (s *CrawlState) unchanged(pageURL string, lastMods map[string]string) bool {
+	lastMod := lastMods[pageKey(pageURL)]
+	return lastMod != "" && s.LastMod[pageKey(pageURL)] == lastMod
+}
+
+// skip reports whether the crawl can take a page from the page cache; a
+// full crawl fetches every page
+func (s *CrawlState) skip(pageURL string, lastMods map[string]string, full bool) bool {
+	return !full && s.unchanged(pageURL, lastMods)
+}
+
+// fetched records that a page was fetched at its current lastmod. Pages the
+// sitemap does not date are forgotten, so they are fetched every time.
+func (s *CrawlState) fetched(pageURL string, lastMods map[string]string) {
+	if lastMod := lastMods[pageKey(pageURL)]; lastMod != "" {
+		s.LastMod[pageKey(pageURL)] = lastMod
+	} else {
+		delete(s.LastMod, pageKey(pageURL))
+	}
+}
+
+// finish stamps the state of a completed crawl
+func (s *CrawlState) finish() {
+	s.LastRun = time.Now().UTC().Format(time.RFC3339)
+}
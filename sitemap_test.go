@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// sitemapServer serves a site without a Yoast sitemap, whose WordPress
+// sitemap index lists a nested index, a sitemap of pages and a missing one
+func sitemapServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		site := "http://" + r.Host
+		index := func(children ...string) string {
+			content := `<?xml version="1.0" encoding="UTF-8"?><sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`
+			for _, child := range children {
+				content += "<sitemap><loc>" + site + child + "</loc></sitemap>"
+			}
+			return content + "</sitemapindex>"
+		}
+		switch r.URL.Path {
+		case "/wp-sitemap.xml":
+			fmt.Fprint(w, index("/wp-sitemap-posts-product-index.xml", "/wp-sitemap-posts-page-1.xml", "/wp-sitemap-taxonomies-1.xml"))
+		case "/wp-sitemap-posts-product-index.xml":
+			// Lists itself as well, which must not loop forever
+			fmt.Fprint(w, index("/wp-sitemap-posts-product-1.xml", "/wp-sitemap-posts-product-index.xml"))
+		case "/wp-sitemap-posts-product-1.xml":
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+<url><loc>`+site+`/product/cam2-synavex-5w-30/</loc><lastmod>2026-09-01T10:00:00+00:00</lastmod></url>
+<url><loc>`+site+`/product/cam2-super-hd-15w-40/</loc><lastmod>2026-10-15T08:30:00+00:00</lastmod></url>
+<url><loc> `+site+`/product/cam2-blue-blood-def/ </loc></url>
+</urlset>`)
+		case "/wp-sitemap-posts-page-1.xml":
+			fmt.Fprint(w, `<urlset><url><loc>`+site+`/data-sheets/</loc><lastmod>2026-08-20</lastmod></url></urlset>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestSitemapCrawlState(t *testing.T) {
+	server := sitemapServer()
+	defer server.Close()
+	site := server.URL
+
+	lastMods := readSitemaps(site)
+	want := map[string]string{
+		site + "/product/cam2-synavex-5w-30":   "2026-09-01T10:00:00+00:00",
+		site + "/product/cam2-super-hd-15w-40": "2026-10-15T08:30:00+00:00",
+		site + "/product/cam2-blue-blood-def":  "",
+		site + "/data-sheets":                  "2026-08-20",
+	}
+	if !reflect.DeepEqual(lastMods, want) {
+		t.Fatalf("got %v\nwant %v", lastMods, want)
+	}
+
+	path := filepath.Join(t.TempDir(), crawlStatePath)
+	state := loadCrawlState(path)
+	state.LastMod = map[string]string{
+		site + "/product/cam2-synavex-5w-30":     "2026-09-01T10:00:00+00:00",
+		site + "/product/cam2-super-hd-15w-40":   "2026-09-01T10:00:00+00:00",
+		site + "/product/cam2-blue-blood-def":    "2026-01-01",
+		site + "/product/cam2-magnum-80w-90-gl5": "2025-12-01",
+	}
+	tests := []struct {
+		page string
+		skip bool
+	}{
+		{site + "/product/cam2-synavex-5w-30/", true},
+		{site + "/product/cam2-super-hd-15w-40/", false},   // Newer lastmod
+		{site + "/product/cam2-blue-blood-def/", false},    // No lastmod in the sitemap
+		{site + "/product/cam2-magnum-80w-90-gl5/", false}, // Not in the sitemap any more
+		{site + "/data-sheets/", false},                    // Never fetched
+	}
+	for _, test := range tests {
+		if got := state.skip(test.page, lastMods, false); got != test.skip {
+			t.Errorf("skip(%s) = %v, want %v", test.page, got, test.skip)
+		}
+		if state.skip(test.page, lastMods, true) {
+			t.Errorf("a full crawl skipped %s", test.page)
+		}
+		// Without a sitemap nothing is known to be unchanged
+		if state.skip(test.page, nil, false) {
+			t.Errorf("skipped %s without a sitemap", test.page)
+		}
+	}
+
+	for _, test := range tests {
+		state.fetched(test.page, lastMods)
+	}
+	state.finish()
+	state.save(path)
+	saved := loadCrawlState(path)
+	if saved.LastRun == "" {
+		t.Error("finish did not stamp the run")
+	}
+	// Undated pages are forgotten so the next crawl fetches them again
+	wantState := map[string]string{
+		site + "/product/cam2-synavex-5w-30":   "2026-09-01T10:00:00+00:00",
+		site + "/product/cam2-super-hd-15w-40": "2026-10-15T08:30:00+00:00",
+		site + "/data-sheets":                  "2026-08-20",
+	}
+	if !reflect.DeepEqual(saved.LastMod, wantState) {
+		t.Errorf("saved %v\nwant %v", saved.LastMod, wantState)
+	}
+	for _, test := range tests {
+		if got, want := saved.skip(test.page, lastMods, false), want[pageKey(test.page)] != ""; got != want {
+			t.Errorf("next crawl: skip(%s) = %v, want %v", test.page, got, want)
+		}
+	}
+
+	// A site without any sitemap
+	empty := httptest.NewServer(http.NotFoundHandler())
+	defer empty.Close()
+	if lastMods := readSitemaps(empty.URL); lastMods != nil {
+		t.Errorf("no sitemap gave %v", lastMods)
+	}
+}
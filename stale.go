@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// agePattern matches an age such as "3y", "18m" or "90d"
+var agePattern = regexp.MustCompile(`^(\d+)\s*([ymd])$`)
+
+// parseAge returns the date an age before now, for ages such as "3y"
+func parseAge(age string, now time.Time) (time.Time, error) {
+	match := agePattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(age)))
+	if match == nil {
+		return time.Time{}, fmt.Errorf("invalid age %q, expected e.g. 3y, 18m or 90d", age)
+	}
+	count, _ := strconv.Atoi(match[1])
+	switch match[2] {
+	case "y":
+		return now.AddDate(-count, 0, 0), nil
+	case "m":
+		return now.AddDate(0, -count, 0), nil
+	}
+	return now.AddDate(0, 0, -count), nil
+}
+
+// StaleSheet is one row of the stale report
+type StaleSheet struct {
+	File     string   `json:"file"`
+	Product  string   `json:"product,omitempty"`
+	Revision string   `json:"revision_date,omitempty"` // YYYY-MM-DD, empty when none was found
+	FoundIn  string   `json:"found_in,omitempty"`      // e.g. "section 16" or "page header"
+	AgeDays  int      `json:"age_days,omitempty"`
+	Status   string   `json:"status"`             // "stale", "no date", "unreadable" or "current"
+	Products []string `json:"products,omitempty"` // Slugs of the pages linking it
+}
+
+// staleSheets reads the revision date of every SDS in dir and marks the
+// ones revised before cutoff
+func staleSheets(manifest *Manifest, dir string, cutoff, now time.Time) []StaleSheet {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.pdf"))
+	var report []StaleSheet
+	for _, path := range files {
+		filename := getFilename(path)
+		kind := documentKind(filename)
+		if document := manifest.Documents[filename]; document != nil {
+			kind = document.Kind
+		}
+		if kind != "sds" {
+			continue
+		}
+		row := StaleSheet{File: filename}
+		if document := manifest.Documents[filename]; document != nil {
+			row.Products = document.Products
+		}
+		text, err := pdfText(path)
+		if err != nil {
+			row.Status = "unreadable"
+			report = append(report, row)
+			continue
+		}
+		row.Product = parseSDSText(filename, text).ProductName
+		date, foundIn, ok := locateRevisionDate(text)
+		switch {
+		case !ok:
+			row.Status = "no date"
+		case date.Before(cutoff):
+			row.Status = "stale"
+		default:
+			row.Status = "current"
+		}
+		if ok {
+			row.Revision, row.FoundIn = date.Format("2006-01-02"), foundIn
+			row.AgeDays = int(now.Sub(date).Hours() / 24)
+		}
+		report = append(report, row)
+	}
+	// Undated sheets first, then the oldest
+	sort.SliceStable(report, func(i, j int) bool {
+		if (report[i].Revision == "") != (report[j].Revision == "") {
+			return report[i].Revision == ""
+		}
+		if report[i].Revision != report[j].Revision {
+			return report[i].Revision < report[j].Revision
+		}
+		return report[i].File < report[j].File
+	})
+	return report
+}
+
+// staleCommand implements "stale": safety data sheets whose revision date
+// is older than a given age, and those without a revision date
+func staleCommand(args []string) {
+	flags := flag.NewFlagSet("stale", flag.ExitOnError)
+	maxAge := flags.String("max-age", "3y", "oldest acceptable revision, e.g. 3y, 18m or 90d")
+	all := flags.Bool("all", false, "also list sheets that are not stale")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	dir := flags.String("dir", "PDFs", "directory of downloaded PDFs")
+	manifestFile := flags.String("manifest", manifestPath, "manifest written by the crawler")
+	flags.Parse(args)
+
+	now := time.Now()
+	cutoff, err := parseAge(*maxAge, now)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	report := staleSheets(loadManifest(*manifestFile), *dir, cutoff, now)
+	counts := map[string]int{}
+	var shown []StaleSheet
+	for _, row := range report {
+		counts[row.Status]++
+		if *all || row.Status != "current" {
+			shown = append(shown, row)
+		}
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(shown)
+	} else {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "STATUS\tREVISED\tAGE\tFOUND IN\tFILE\tPRODUCT")
+		for _, row := range shown {
+			age := "-"
+			if row.Revision != "" {
+				age = fmt.Sprintf("%.1fy", float64(row.AgeDays)/365.25)
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", strings.ToUpper(row.Status), orDash(row.Revision), age, orDash(row.FoundIn), row.File, row.Product)
+		}
+		writer.Flush()
+		fmt.Printf("%d safety data sheets: %d revised before %s, %d without a revision date, %d unreadable.\n",
+			len(report), counts["stale"], cutoff.Format("2006-01-02"), counts["no date"], counts["unreadable"])
+	}
+	if counts["stale"]+counts["no date"]+counts["unreadable"] > 0 {
+		os.Exit(1)
+	}
+}
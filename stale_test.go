@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		age  string
+		want string
+	}{
+		{"3y", "2023-10-18"},
+		{"18m", "2025-04-18"},
+		{"90d", "2026-07-20"},
+		{" 2Y ", "2024-10-18"},
+		{"0d", "2026-10-18"},
+	}
+	for _, test := range tests {
+		got, err := parseAge(test.age, now)
+		if err != nil {
+			t.Errorf("parseAge(%q): %v", test.age, err)
+			continue
+		}
+		if got.Format("2006-01-02") != test.want {
+			t.Errorf("parseAge(%q) = %s, want %s", test.age, got.Format("2006-01-02"), test.want)
+		}
+	}
+	for _, age := range []string{"", "3", "y", "3w", "-1y", "1.5y"} {
+		if _, err := parseAge(age, now); err == nil {
+			t.Errorf("parseAge(%q) gave no error", age)
+		}
+	}
+}
+
+func TestLocateRevisionDate(t *testing.T) {
+	const heading = "SAFETY DATA SHEET\nCAM2 Synavex 5W-30\n"
+	tests := []struct {
+		name    string
+		text    string
+		want    string // YYYY-MM-DD, empty for none
+		foundIn string
+	}{
+		{"section 16", heading + "SECTION 1: Identification\nProduct code 80565-082\nSECTION 16: Other information\nRevision date: 04/13/2023\n", "2023-04-13", "section 16"},
+		{"heading", heading + "Issue date: 2/1/2021\nSECTION 1: Identification\n", "2021-02-01", "heading"},
+		{"page header", "04/13/2023 EN\nSAFETY DATA SHEET\nSECTION 1: Identification\n", "2023-04-13", "page header"},
+		{"another section", heading + "SECTION 1: Identification\nSECTION 2: Hazards\nDate of issue Jan. 5, 2022\n", "2022-01-05", "section 2"},
+		{"month first when ambiguous", heading + "Revision date: 03/04/2023\n", "2023-03-04", "heading"},
+		{"day first", heading + "Revision date: 13/04/2023\n", "2023-04-13", "heading"},
+		{"dotted", heading + "Revision date: 13.04.2023\n", "2023-04-13", "heading"},
+		{"year first with slashes", heading + "Revision date: 2023/04/13\n", "2023-04-13", "heading"},
+		{"ISO", heading + "Revision date: 2023-04-13\n", "2023-04-13", "heading"},
+		{"day month name year", heading + "Revision date: 13-Apr-2023\n", "2023-04-13", "heading"},
+		{"dotted year first without colon", heading + "Revision date 2019.05.14\n", "2019-05-14", "heading"},
+		{"two digit year", heading + "Revised on 4/13/23\n", "2023-04-13", "heading"},
+		{"doubled slash", heading + "Revision Date: 04/13//2023\n", "2023-04-13", "heading"},
+		{"month name", heading + "SECTION 16: Other information\nEffective date April 13, 2023\n", "2023-04-13", "section 16"},
+		{"invalid day", heading + "Revision date: 02/30/2023\n", "", ""},
+		{"no date", heading + "SECTION 1: Identification\n", "", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			date, foundIn, ok := locateRevisionDate(test.text)
+			got := ""
+			if ok {
+				got = date.Format("2006-01-02")
+			}
+			if got != test.want || (ok && foundIn != test.foundIn) {
+				t.Errorf("got %q in %q, want %q in %q", got, foundIn, test.want, test.foundIn)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// StoreProduct is one product as the WooCommerce Store API lists it
+type StoreProduct struct {
+	ID         int      `json:"id"`
+	Name       string   `json:"name"`
+	Slug       string   `json:"slug"`
+	SKU        string   `json:"sku,omitempty"`
+	Permalink  string   `json:"permalink"`
+	Categories []string `json:"categories,omitempty"`
+}
+
+// wcStoreProduct is the part of a /wp-json/wc/store/products entry the
+// crawler reads
+type wcStoreProduct struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	Slug       string `json:"slug"`
+	SKU        string `json:"sku"`
+	Permalink  string `json:"permalink"`
+	Categories []struct {
+		Name string `json:"name"`
+	} `json:"categories"`
+}
+
+// storePageURL returns the URL of one page of the Store API product list
+func storePageURL(baseURL string, page int) string {
+	query := url.Values{}
+	query.Set("per_page", "100")
+	query.Set("page", strconv.Itoa(page))
+	query.Set("orderby", "id")
+	query.Set("order", "asc")
+	return strings.TrimSuffix(baseURL, "/") + "/wp-json/wc/store/products?" + query.Encode()
+}
+
+// discoverStoreProducts lists the products of the WooCommerce shop at
+// baseURL. It returns false when the Store API is disabled or unreachable,
+// in which case the crawler falls back to what the product pages say.
+func discoverStoreProducts(baseURL string) ([]StoreProduct, bool) {
+	client := &http.Client{Timeout: time.Minute, Transport: httpTransport}
+	var products []StoreProduct
+	pageURL := func(page int) string { return storePageURL(baseURL, page) }
+	err := listWordPress(client, "store products", pageURL, func(decoder *json.Decoder) (int, error) {
+		var entries []wcStoreProduct
+		if err := decoder.Decode(&entries); err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			product := StoreProduct{
+				ID:        entry.ID,
+				Name:      stripHTML(entry.Name),
+				Slug:      entry.Slug,
+				SKU:       strings.TrimSpace(entry.SKU),
+				Permalink: entry.Permalink,
+			}
+			for _, category := range entry.Categories {
+				product.Categories = appendUnique(product.Categories, stripHTML(category.Name))
+			}
+			products = append(products, product)
+		}
+		return len(entries), nil
+	})
+	if err != nil {
+		// A partial list would make missing products look unlisted
+		log.Println("Store API unavailable, using product pages only:", err)
+		return nil, false
+	}
+	return products, true
+}
+
+// storeSlug returns the product page slug of a store product
+func (p StoreProduct) storeSlug() string {
+	if slug := productSlug(p.Permalink); slug != "" {
+		return slug
+	}
+	return p.Slug
+}
+
+// newStorePages returns the product pages the store lists that are not
+// among the seeds
+func newStorePages(products []StoreProduct, seeds []string) []string {
+	seeded := map[string]bool{}
+	for _, seed := range seeds {
+		seeded[productSlug(seed)] = true
+	}
+	var pages []string
+	for _, product := range products {
+		if product.Permalink != "" && !seeded[product.storeSlug()] && isUrlValid(product.Permalink) {
+			pages = append(pages, product.Permalink)
+			seeded[product.storeSlug()] = true
+		}
+	}
+	return pages
+}
+
+// knownStoreProducts returns the store products recorded by earlier crawls
+func (m *Manifest) knownStoreProducts() []StoreProduct {
+	var products []StoreProduct
+	for _, product := range m.Products {
+		if product.StoreID != 0 && product.Withdrawn == "" {
+			products = append(products, StoreProduct{ID: product.StoreID, Name: product.Title, Slug: product.Slug,
+				SKU: product.SKU, Permalink: product.URL, Categories: product.StoreCategories})
+		}
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	return products
+}
+
+// recordStoreProducts stores the Store API's name, SKU and categories of
+// each product. They replace what was scraped from the product page.
+func (m *Manifest) recordStoreProducts(products []StoreProduct) {
+	for _, storeProduct := range products {
+		slug := storeProduct.storeSlug()
+		if slug == "" {
+			continue
+		}
+		product := m.Products[slug]
+		if product == nil {
+			product = &Product{Slug: slug}
+			m.Products[slug] = product
+		}
+		if product.URL == "" {
+			product.URL = storeProduct.Permalink
+		}
+		product.StoreID = storeProduct.ID
+		if storeProduct.Name != "" {
+			product.Title = storeProduct.Name
+		}
+		if storeProduct.SKU != "" {
+			product.SKU = storeProduct.SKU
+		}
+		product.StoreCategories = storeProduct.Categories
+	}
+}
+
+// storeCommand implements "store": list the products of the Store API and
+// whether the crawler seeds their pages
+func storeCommand(args []string) {
+	flags := flag.NewFlagSet("store", flag.ExitOnError)
+	baseURL := flags.String("base", "https://cam2.com", "WooCommerce site to list, e.g. a local stub")
+	asJSON := flags.Bool("json", false, "print the products as JSON")
+	unseeded := flags.Bool("unseeded", false, "only list products whose page is not a seed")
+	flags.Parse(args)
+
+	products, ok := discoverStoreProducts(*baseURL)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "The Store API is not available; the crawler will use the product pages only.")
+		os.Exit(1)
+	}
+	seeded := map[string]bool{}
+	for _, seed := range remoteURL {
+		seeded[productSlug(seed)] = true
+	}
+	if *unseeded {
+		var filtered []StoreProduct
+		for _, product := range products {
+			if !seeded[product.storeSlug()] {
+				filtered = append(filtered, product)
+			}
+		}
+		products = filtered
+	}
+	sort.SliceStable(products, func(i, j int) bool { return products[i].Name < products[j].Name })
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(products)
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tSKU\tCATEGORIES\tSEEDED\tURL")
+	for _, product := range products {
+		isSeeded := "no"
+		if seeded[product.storeSlug()] {
+			isSeeded = "yes"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", product.Name, product.SKU, strings.Join(product.Categories, ", "), isSeeded, product.Permalink)
+	}
+	writer.Flush()
+	fmt.Printf("%d products in the store.\n", len(products))
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// substituteProfile is what the substitute search knows about one product,
+// or about one grade of a data sheet that no product page links to
+type substituteProfile struct {
+	product    *Product
+	label      string
+	category   string
+	grades     Grades             // Viscosity grades only
+	specs      []string           // API and ILSAC categories and OEM approvals
+	properties map[string]float64 // Typical properties from the TDS
+}
+
+// Substitute is one ranked alternative for a product
+type Substitute struct {
+	Product     string   `json:"product"`
+	Slug        string   `json:"slug"`
+	Category    string   `json:"category"`
+	Score       float64  `json:"score"` // 100 for an identical profile
+	Differences []string `json:"differences"`
+}
+
+// Midpoints of the SAE J300 and J306 kinematic viscosity ranges at 100 °C,
+// used when a product has a grade but no published viscosity
+var (
+	j300NominalKV100 = map[string]float64{
+		"0W": 4.5, "5W": 4.5, "10W": 5.5, "15W": 6.5, "20W": 7, "25W": 10,
+		"8": 5, "12": 6, "16": 7.1, "20": 8.1, "30": 10.9, "40": 14.4, "50": 19.1, "60": 24,
+	}
+	j306NominalKV100 = map[string]float64{
+		"70W": 4.5, "75W": 5, "80W": 8, "85W": 12,
+		"80": 9, "85": 12.2, "90": 16, "110": 21.2, "140": 28.2, "190": 36.7, "250": 45,
+	}
+)
+
+// nominalViscosity returns the viscosity implied by a grade: at 40 °C for an
+// ISO VG grade, at 100 °C for SAE grades, zero when unknown
+func nominalViscosity(grades Grades) (kv40, kv100 float64) {
+	if len(grades.ISOVG) > 0 {
+		fmt.Sscan(grades.ISOVG[0], &kv40)
+	}
+	highTemperatureGrade := func(grade string) string {
+		if index := strings.Index(grade, "-"); index >= 0 {
+			return grade[index+1:]
+		}
+		return grade
+	}
+	if len(grades.SAEJ300) > 0 {
+		kv100 = j300NominalKV100[highTemperatureGrade(grades.SAEJ300[0])]
+	} else if len(grades.SAEJ306) > 0 {
+		kv100 = j306NominalKV100[highTemperatureGrade(grades.SAEJ306[0])]
+	}
+	return kv40, kv100
+}
+
+// winterGrade returns the W number of the first SAE J300 grade, 0 for a
+// monograde without one and -1 without an SAE J300 grade
+func winterGrade(grades Grades) int {
+	if len(grades.SAEJ300) == 0 {
+		return -1
+	}
+	winter := 0
+	if index := strings.Index(grades.SAEJ300[0], "W"); index > 0 {
+		fmt.Sscan(grades.SAEJ300[0][:index], &winter)
+	}
+	return winter
+}
+
+// viscosities returns a profile's viscosity at 40 and 100 °C, published
+// values first and nominal values for its grade otherwise
+func (p substituteProfile) viscosities() (kv40, kv100 float64) {
+	kv40, kv100 = p.properties[propertyKV40], p.properties[propertyKV100]
+	nominal40, nominal100 := nominalViscosity(p.grades)
+	if kv40 == 0 {
+		kv40 = nominal40
+	}
+	if kv100 == 0 {
+		kv100 = nominal100
+	}
+	return kv40, kv100
+}
+
+// viscosityGrades keeps only the viscosity grades of g
+func viscosityGrades(g Grades) Grades {
+	return Grades{SAEJ300: g.SAEJ300, SAEJ306: g.SAEJ306, ISOVG: g.ISOVG, NLGI: g.NLGI}
+}
+
+// specificationLabels lists the API and ILSAC categories and OEM approvals
+func specificationLabels(g Grades, approvals []string) []string {
+	specs := Grades{API: g.API, ILSAC: g.ILSAC}.labels()
+	for _, approval := range approvals {
+		specs = appendUnique(specs, approval)
+	}
+	return specs
+}
+
+// productProfile builds the profile of a product from its data sheets
+func productProfile(product *Product, manifest *Manifest, dir string) substituteProfile {
+	details := loadProductDetails(product, manifest, dir)
+	profile := substituteProfile{
+		product:  product,
+		label:    product.name(),
+		category: product.Category,
+		grades:   viscosityGrades(product.Grades),
+		specs:    specificationLabels(product.Grades, details.approvals),
+	}
+	if details.column != nil {
+		profile.properties = details.column.Properties
+	}
+	return profile
+}
+
+// unlinkedSheetProfiles builds one profile per grade of every technical data
+// sheet in dir that none of the catalog products links to and that is not
+// withdrawn
+func unlinkedSheetProfiles(catalog []*Product, manifest *Manifest, dir string) []substituteProfile {
+	linked := map[string]bool{}
+	for _, product := range catalog {
+		for _, filename := range product.Documents {
+			linked[filename] = true
+		}
+	}
+	var profiles []substituteProfile
+	for _, sheet := range loadTDSSheets(dir) {
+		if document := manifest.Documents[sheet.File]; linked[sheet.File] || (document != nil && document.Withdrawn != "") {
+			continue
+		}
+		text, err := pdfText(filepath.Join(dir, sheet.File))
+		if err != nil {
+			continue
+		}
+		product := &Product{Slug: partNumberFromFilename(sheet.File), Documents: []string{sheet.File}}
+		sheetGrades := parseGrades(text, false)
+		heading := text
+		if len(heading) > 300 {
+			heading = heading[:300]
+		}
+		for _, column := range sheet.Columns {
+			profile := substituteProfile{
+				product:    product,
+				label:      strings.TrimSpace(product.Slug + " " + column.Grade),
+				category:   productCategory(heading),
+				grades:     viscosityGrades(parseGrades(column.Grade, true)),
+				specs:      specificationLabels(sheetGrades, parseApprovals(text)),
+				properties: column.Properties,
+			}
+			if len(sheet.Columns) == 1 && !profile.grades.hasViscosityGrade() {
+				profile.grades = viscosityGrades(sheetGrades)
+			}
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles
+}
+
+// compareProfiles measures how far a candidate is from the target and
+// explains each difference; a distance of zero means an identical profile
+func compareProfiles(target, candidate substituteProfile) (float64, []string) {
+	distance := 0.0
+	var notes []string
+	if candidate.category != target.category {
+		distance += 3
+		notes = append(notes, "different category ("+candidate.category+")")
+	}
+
+	targetGrades := strings.Join(target.grades.labels(), ", ")
+	candidateGrades := strings.Join(candidate.grades.labels(), ", ")
+	if candidateGrades != targetGrades && candidateGrades != "" {
+		notes = append(notes, "grade "+candidateGrades)
+	}
+	targetKV40, targetKV100 := target.viscosities()
+	candidateKV40, candidateKV100 := candidate.viscosities()
+	viscosityNote := func(temperature string, want, have float64) {
+		ratio := math.Log(have / want)
+		distance += 4 * math.Abs(ratio)
+		if change := (have/want - 1) * 100; math.Abs(change) >= 3 {
+			direction := "higher"
+			if change < 0 {
+				direction = "lower"
+			}
+			notes = append(notes, fmt.Sprintf("viscosity at %s %.0f%% %s (%.4g vs %.4g cSt)", temperature, math.Abs(change), direction, have, want))
+		}
+	}
+	if targetWinter, candidateWinter := winterGrade(target.grades), winterGrade(candidate.grades); targetWinter >= 0 && candidateWinter >= 0 {
+		// A lower winter grade flows better in the cold, so it costs less
+		steps := float64(candidateWinter-targetWinter) / 5
+		distance += math.Max(0, steps)*0.5 + math.Max(0, -steps)*0.15
+	}
+	switch {
+	case targetKV100 > 0 && candidateKV100 > 0:
+		viscosityNote("100 °C", targetKV100, candidateKV100)
+	case targetKV40 > 0 && candidateKV40 > 0:
+		viscosityNote("40 °C", targetKV40, candidateKV40)
+	case candidateGrades != targetGrades:
+		distance += 1.5
+		notes = append(notes, "viscosity not comparable")
+	}
+	if want, have, ok := bothProperties(target, candidate, propertyVI); ok && want != have {
+		distance += math.Abs(have-want) / 40
+		notes = append(notes, fmt.Sprintf("viscosity index %s (%.0f vs %.0f)", higherOrLower(have-want, ""), have, want))
+	}
+	if want, have, ok := bothProperties(target, candidate, propertyPourPoint); ok && math.Abs(have-want) >= 1 {
+		// A higher pour point can make a substitute unusable in the cold; a
+		// lower one rarely matters
+		distance += math.Max(0, have-want)/10 + math.Max(0, want-have)/40
+		notes = append(notes, fmt.Sprintf("pour point %s (%.0f vs %.0f °C)", higherOrLower(have-want, " °C"), have, want))
+	}
+	if want, have, ok := bothProperties(target, candidate, propertyFlashPoint); ok && math.Abs(have-want) >= 5 {
+		distance += math.Max(0, want-have) / 50
+		notes = append(notes, fmt.Sprintf("flash point %s (%.0f vs %.0f °C)", higherOrLower(have-want, " °C"), have, want))
+	}
+
+	var missing, extra []string
+	for _, spec := range target.specs {
+		if !containsString(candidate.specs, spec) {
+			missing = append(missing, spec)
+		}
+	}
+	for _, spec := range candidate.specs {
+		if !containsString(target.specs, spec) {
+			extra = append(extra, spec)
+		}
+	}
+	distance += 0.6*float64(len(missing)) + 0.05*float64(len(extra))
+	if len(missing) > 0 {
+		notes = append(notes, "lacks "+strings.Join(missing, ", "))
+	}
+	if len(extra) > 0 {
+		notes = append(notes, "also meets "+strings.Join(extra, ", "))
+	}
+	return distance, notes
+}
+
+// bothProperties returns a property of the target and the candidate when
+// both data sheets publish it
+func bothProperties(target, candidate substituteProfile, key string) (want, have float64, ok bool) {
+	want, wantOK := target.properties[key]
+	have, haveOK := candidate.properties[key]
+	return want, have, wantOK && haveOK
+}
+
+// higherOrLower describes a difference such as "6 °C lower"
+func higherOrLower(difference float64, unit string) string {
+	if difference < 0 {
+		return fmt.Sprintf("%.0f%s lower", -difference, unit)
+	}
+	return fmt.Sprintf("%.0f%s higher", difference, unit)
+}
+
+// containsString reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, existing := range list {
+		if existing == value {
+			return true
+		}
+	}
+	return false
+}
+
+// findSubstitutes ranks the candidates by similarity to the target; a
+// withdrawn product is no substitute
+func findSubstitutes(target substituteProfile, candidates []substituteProfile, sameCategory bool) []Substitute {
+	var substitutes []Substitute
+	for _, candidate := range candidates {
+		if candidate.product == target.product || candidate.product.Slug == target.product.Slug || candidate.product.Withdrawn != "" {
+			continue
+		}
+		if sameCategory && target.category != "other" && candidate.category != target.category {
+			continue
+		}
+		distance, notes := compareProfiles(target, candidate)
+		if len(notes) == 0 {
+			notes = []string{"no differences found in the data sheets"}
+		}
+		substitutes = append(substitutes, Substitute{
+			Product:     candidate.label,
+			Slug:        candidate.product.Slug,
+			Category:    candidate.category,
+			Score:       math.Round(100/(1+distance)*10) / 10,
+			Differences: notes,
+		})
+	}
+	sort.SliceStable(substitutes, func(i, j int) bool { return substitutes[i].Score > substitutes[j].Score })
+	return substitutes
+}
+
+// substituteCommand implements "substitute": the closest alternatives to an
+// out-of-stock or discontinued product
+func substituteCommand(args []string) {
+	flags := flag.NewFlagSet("substitute", flag.ExitOnError)
+	limit := flags.Int("n", 10, "number of substitutes to show")
+	grade := flags.String("grade", "", "grade to compare when the product's data sheet covers several, e.g. 15W-40")
+	allCategories := flags.Bool("all", false, "include products from other categories")
+	asJSON := flags.Bool("json", false, "print substitutes as JSON")
+	dir := flags.String("dir", "PDFs/", "directory of downloaded PDFs")
+	manifestFile := flags.String("manifest", manifestPath, "manifest written by the crawler")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: go run . substitute [flags] <slug or part number>")
+		os.Exit(2)
+	}
+
+	manifest := loadManifest(*manifestFile)
+	catalog := loadCatalog(manifest, *dir)
+	product := findProduct(catalog, manifest, *dir, flags.Arg(0))
+	if product == nil {
+		fmt.Fprintf(os.Stderr, "no product or document matches %q\n", flags.Arg(0))
+		os.Exit(1)
+	}
+	if *grade != "" {
+		narrowed := *product
+		narrowed.Grades = viscosityGrades(parseGrades(*grade, true))
+		narrowed.Grades.API, narrowed.Grades.ILSAC = product.Grades.API, product.Grades.ILSAC
+		product = &narrowed
+	}
+	target := productProfile(product, manifest, *dir)
+
+	var candidates []substituteProfile
+	for _, candidate := range catalog {
+		candidates = append(candidates, productProfile(candidate, manifest, *dir))
+	}
+	candidates = append(candidates, unlinkedSheetProfiles(catalog, manifest, *dir)...)
+	substitutes := findSubstitutes(target, candidates, !*allCategories)
+	if len(substitutes) > *limit {
+		substitutes = substitutes[:*limit]
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(substitutes)
+		return
+	}
+	fmt.Printf("Substitutes for %s (%s, %s)\n\n", target.label, target.category, strings.Join(append(target.grades.labels(), target.specs...), ", "))
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "RANK\tSCORE\tPRODUCT\tDIFFERENCES")
+	for i, substitute := range substitutes {
+		fmt.Fprintf(writer, "%d\t%.1f\t%s\t%s\n", i+1, substitute.Score, substitute.Product, strings.Join(substitute.Differences, "; "))
+	}
+	writer.Flush()
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestFindSubstitutesSkipsWithdrawn(t *testing.T) {
+	profile := func(product *Product, kv100 float64, specs ...string) substituteProfile {
+		return substituteProfile{
+			product:    product,
+			label:      product.Slug,
+			category:   "motor oil",
+			grades:     viscosityGrades(parseGrades("15W-40", true)),
+			specs:      specs,
+			properties: map[string]float64{propertyKV100: kv100},
+		}
+	}
+	target := profile(&Product{Slug: "cam2-super-hd-15w-40", Withdrawn: "2026-09-01"}, 15.2, "API CK-4")
+	candidates := []substituteProfile{
+		// Identical, but discontinued as well
+		profile(&Product{Slug: "cam2-magnum-15w-40", Withdrawn: "2026-10-18"}, 15.2, "API CK-4"),
+		profile(&Product{Slug: "cam2-blue-blood-15w-40"}, 14.1, "API CK-4"),
+		profile(&Product{Slug: "cam2-promax-15w-40"}, 13.0),
+	}
+	substitutes := findSubstitutes(target, candidates, true)
+	if len(substitutes) != 2 {
+		t.Fatalf("got %d substitutes, want 2: %+v", len(substitutes), substitutes)
+	}
+	if substitutes[0].Slug != "cam2-blue-blood-15w-40" || substitutes[1].Slug != "cam2-promax-15w-40" {
+		t.Errorf("ranked %s, %s", substitutes[0].Slug, substitutes[1].Slug)
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+// taxonomyOverridesPath is the hand-maintained file that corrects products
+// the rules below put in the wrong place
+const taxonomyOverridesPath = "taxonomy_overrides.json"
+
+// Taxonomy places a product in the catalog; in the overrides file empty
+// fields keep the classified value
+type Taxonomy struct {
+	Category    string `json:"category,omitempty"`
+	BrandLine   string `json:"brand_line,omitempty"`
+	Application string `json:"application,omitempty"`
+}
+
+// categoryRules map words in a product's slug or title to its category; the
+// first rule with a matching phrase wins, so "gear oil" beats "oil" and
+// "tractor hydraulic fluid" is a tractor fluid rather than a hydraulic fluid
+var categoryRules = []struct {
+	category string
+	phrases  []string
+}{
+	{"grease", []string{"grease"}},
+	{"2-cycle oil", []string{"2 cycle", "tc w3", "outboard"}},
+	{"motorcycle oil", []string{"motorcycle", "4t"}},
+	{"gear oil", []string{"gear oil"}},
+	{"tractor fluid", []string{"tractor", "torque fluid", "to 4"}},
+	{"transmission fluid", []string{"atf", "transmission", "trans fluid", "cvt"}},
+	{"hydraulic fluid", []string{"hydraulic"}},
+	{"engine oil", []string{"engine oil", "motor oil", "ck 4", "ci 4", "ch 4", "cj 4", "fa 4"}},
+	{"industrial oil", []string{"compressor", "turbine", "way lube", "rock drill", "heat transfer", "wireseal", "cherry picker", "saw guide", "drip oil", "form oil", "transformer", "bar chain", "smoke oil"}},
+	{"base oil", []string{"base oil", "pale oil"}},
+	{"antifreeze", []string{"antifreeze", "coolant", "radiator"}},
+	{"brake fluid", []string{"brake fluid"}},
+	{"power steering fluid", []string{"power steering fluid"}},
+	{"diesel exhaust fluid", []string{"def"}},
+	{"fuel additive", []string{"octane", "gas treatment", "fuel", "diesel conditioner", "injector"}},
+	{"oil additive", []string{"oil treatment", "motor sealer"}},
+	{"aerosols & chemicals", []string{"cleaner", "degreaser", "penetrating", "de icer", "starting fluid", "sealant", "washer", "mineral spirits", "lighter fluid", "brightener", "sanitizer"}},
+	{"fuel", []string{"kerosene"}},
+}
+
+// brandLineRules map words in a product's slug or title to its CAM2 brand
+// line; longer names come first so "SuperPro Max" is not read as "ProMax"
+var brandLineRules = []struct {
+	brandLine string
+	phrases   []string
+}{
+	{"SuperPro Max", []string{"superpro max", "superpro"}},
+	{"SuperLife", []string{"superlife"}},
+	{"Synavex", []string{"synavex"}},
+	{"Blue Blood", []string{"blue blood"}},
+	{"Protect75", []string{"protect75", "protect 75"}},
+	{"ProMax", []string{"promax", "pro max"}},
+	{"Super HD", []string{"super hd"}},
+	{"Magnum", []string{"magnum"}},
+	{"Ultraplex", []string{"ultraplex"}},
+	{"Ultra 580", []string{"ultra580", "ultra 580"}},
+	{"Ultra Turbine", []string{"ultra turbine"}},
+	{"NGEO", []string{"ngeo", "geo"}},
+	{"ND", []string{"nd"}},
+	{"MPT", []string{"mpt"}},
+	{"Hydra-Cat", []string{"hydra cat"}},
+	{"Logger's Pride", []string{"loggers pride"}},
+}
+
+// containsPhrase reports whether the words of text include one of phrases,
+// ignoring case and punctuation
+func containsPhrase(text string, phrases ...string) bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	padded := " " + strings.Join(words, " ") + " "
+	for _, phrase := range phrases {
+		if strings.Contains(padded, " "+phrase+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// productCategory returns the category of a product from the words of its
+// slug and title, or "other"
+func productCategory(text string) string {
+	for _, rule := range categoryRules {
+		if containsPhrase(text, rule.phrases...) {
+			return rule.category
+		}
+	}
+	return "other"
+}
+
+// productBrandLine returns the brand line of a product, or "CAM2" for
+// products sold under the company name only
+func productBrandLine(text string) string {
+	for _, rule := range brandLineRules {
+		if containsPhrase(text, rule.phrases...) {
+			return rule.brandLine
+		}
+	}
+	return "CAM2"
+}
+
+// productApplication returns where a product is used: "automotive",
+// "HD diesel", "industrial", "marine", "2-cycle" or "general"
+func productApplication(category, text string, grades Grades) string {
+	dieselCategory := false
+	for _, api := range grades.API {
+		if strings.HasPrefix(api, "C") || strings.HasPrefix(api, "FA") {
+			dieselCategory = true
+		}
+	}
+	switch {
+	case containsPhrase(text, "marine", "outboard", "tc w3"):
+		return "marine"
+	case category == "2-cycle oil":
+		return "2-cycle"
+	// Natural gas engine oils are for stationary engines and compressors
+	case containsPhrase(text, "industrial", "natural gas", "ngeo", "geo", "mining"):
+		return "industrial"
+	case dieselCategory, category == "tractor fluid", category == "diesel exhaust fluid",
+		containsPhrase(text, "heavy duty", "hd truck", "fleet", "diesel", "railroad", "turbo d"):
+		return "HD diesel"
+	case category == "gear oil" && len(grades.ISOVG) > 0:
+		return "industrial"
+	}
+	switch category {
+	case "hydraulic fluid", "industrial oil", "base oil", "grease":
+		return "industrial"
+	case "engine oil", "motorcycle oil", "transmission fluid", "gear oil", "antifreeze", "brake fluid",
+		"power steering fluid", "fuel additive", "oil additive", "aerosols & chemicals":
+		return "automotive"
+	}
+	return "general"
+}
+
+// loadTaxonomyOverrides reads the overrides file, keyed by product slug or
+// part number; a missing file means no overrides
+func loadTaxonomyOverrides(path string) map[string]Taxonomy {
+	overrides := map[string]Taxonomy{}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Println(err)
+		}
+		return overrides
+	}
+	if err := json.Unmarshal(content, &overrides); err != nil {
+		log.Printf("Failed to parse %s: %v", path, err)
+	}
+	return overrides
+}
+
+// classifyProduct fills in the category, brand line and application of a
+// product from text describing it, then applies any override for its slug
+func classifyProduct(product *Product, text string, overrides map[string]Taxonomy) {
+	product.Category = productCategory(text)
+	product.BrandLine = productBrandLine(text)
+	product.Application = productApplication(product.Category, text, product.Grades)
+	override := overrides[product.Slug]
+	if override.Category != "" {
+		product.Category = override.Category
+	}
+	if override.BrandLine != "" {
+		product.BrandLine = override.BrandLine
+	}
+	if override.Application != "" {
+		product.Application = override.Application
+	}
+}
+
+// documentHeading returns the text that names a product known only by its
+// data sheets: the product name on its SDS, or the top of its TDS
+func documentHeading(product *Product, manifest *Manifest, dir string) string {
+	for _, filename := range product.documentsOfKind(manifest, "sds") {
+		if sheet, err := loadSafetyDataSheet(filepath.Join(dir, filename)); err == nil && sheet.ProductName != "" {
+			return sheet.ProductName
+		}
+	}
+	for _, filename := range product.documentsOfKind(manifest, "tds") {
+		if text, err := pdfText(filepath.Join(dir, filename)); err == nil {
+			if len(text) > 300 {
+				text = text[:300]
+			}
+			return text
+		}
+	}
+	return ""
+}
+
+// taxonomy returns the classification of a product
+func (p *Product) taxonomy() Taxonomy {
+	return Taxonomy{Category: p.Category, BrandLine: p.BrandLine, Application: p.Application}
+}
+
+// matchesTaxonomy reports whether a product is in the given category, brand
+// line and application; empty filters match everything
+func (p *Product) matchesTaxonomy(filter Taxonomy) bool {
+	return (filter.Category == "" || strings.EqualFold(filter.Category, p.Category)) &&
+		(filter.BrandLine == "" || strings.EqualFold(filter.BrandLine, p.BrandLine)) &&
+		(filter.Application == "" || strings.EqualFold(filter.Application, p.Application))
+}
+
+// categoriesCommand implements "categories": the product catalog grouped by
+// category, brand line and application
+func categoriesCommand(args []string) {
+	flags := flag.NewFlagSet("categories", flag.ExitOnError)
+	listProducts := flags.Bool("products", false, "list every product with its classification")
+	asJSON := flags.Bool("json", false, "print every product with its classification as JSON")
+	dir := flags.String("dir", "PDFs/", "directory of downloaded PDFs")
+	manifestFile := flags.String("manifest", manifestPath, "manifest written by the crawler")
+	flags.Parse(args)
+
+	catalog := loadCatalog(loadManifest(*manifestFile), *dir)
+	if *asJSON {
+		type classified struct {
+			Slug string `json:"slug"`
+			Taxonomy
+		}
+		var output []classified
+		for _, product := range catalog {
+			output = append(output, classified{Slug: product.Slug, Taxonomy: product.taxonomy()})
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(output)
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if *listProducts {
+		sort.SliceStable(catalog, func(i, j int) bool {
+			a, b := catalog[i].taxonomy(), catalog[j].taxonomy()
+			if a.Category != b.Category {
+				return a.Category < b.Category
+			}
+			return a.BrandLine < b.BrandLine
+		})
+		fmt.Fprintln(writer, "CATEGORY\tBRAND LINE\tAPPLICATION\tPRODUCT")
+		for _, product := range catalog {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", product.Category, product.BrandLine, product.Application, product.name())
+		}
+		writer.Flush()
+		return
+	}
+	counts := map[Taxonomy]int{}
+	for _, product := range catalog {
+		counts[product.taxonomy()]++
+	}
+	var groups []Taxonomy
+	for group := range counts {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		if a.BrandLine != b.BrandLine {
+			return a.BrandLine < b.BrandLine
+		}
+		return a.Application < b.Application
+	})
+	fmt.Fprintln(writer, "CATEGORY\tBRAND LINE\tAPPLICATION\tPRODUCTS")
+	for _, group := range groups {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%d\n", group.Category, group.BrandLine, group.Application, counts[group])
+	}
+	writer.Flush()
+}
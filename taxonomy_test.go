@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProductTaxonomy(t *testing.T) {
+	tests := []struct {
+		slug        string
+		category    string
+		brandLine   string
+		application string
+	}{
+		{"cam2-synavex-5w-30-sp-gf-6a-full-synthetic-engine-oil", "engine oil", "Synavex", "automotive"},
+		{"cam2-superpro-max-5w-30-sp-synthetic-blend-motor-oil", "engine oil", "SuperPro Max", "automotive"},
+		// An API C category makes it a diesel oil
+		{"cam2-super-hd-15w-40-performance-driven-ck-4-sn-synthetic-blend-engine-oil", "engine oil", "Super HD", "HD diesel"},
+		{"cam2-ngeo-sae-15w-40-ces-20074-engine-oil", "engine oil", "NGEO", "industrial"},
+		{"cam2-magnum-gear-oil-sae-80w-90-gl-5", "gear oil", "Magnum", "automotive"},
+		{"cam2-ep-220-synthetic-industrial-gear-oil", "gear oil", "CAM2", "industrial"},
+		{"cam-2-promax-aw-100-hydraulic-oil", "hydraulic fluid", "ProMax", "industrial"},
+		{"cam2-mining-hydraulic-68-fluid", "hydraulic fluid", "CAM2", "industrial"},
+		{"cam2-blue-blood-marine-2-cycle-oil-tc-w3", "2-cycle oil", "Blue Blood", "marine"},
+		{"cam2-magnum-economy-2-cycle-engine-oil", "2-cycle oil", "Magnum", "2-cycle"},
+		{"cam2-blue-blood-elite-4t-10w-40-synthetic-motorcycle-oil", "motorcycle oil", "Blue Blood", "automotive"},
+		{"cam2-dexron-vi-multi-vehicle-full-synthetic-atf", "transmission fluid", "CAM2", "automotive"},
+		{"cam2-blue-blood-def", "diesel exhaust fluid", "Blue Blood", "HD diesel"},
+		{"cam2-hi-temp-red-lithium-complex-grease", "grease", "CAM2", "industrial"},
+		{"cam2-global-pre-mix-50-50-antifreeze", "antifreeze", "CAM2", "automotive"},
+		{"cam2-carb-fuel-injector-cleaner", "fuel additive", "CAM2", "automotive"},
+		{"cam2-aluminum-brightener-fiberglass-cleaner", "aerosols & chemicals", "CAM2", "automotive"},
+		{"cam2-k-1-kerosene", "fuel", "CAM2", "general"},
+		{"cam2-nitrile-gloves-8mil-black-medium", "other", "CAM2", "general"},
+	}
+	for _, test := range tests {
+		category := productCategory(test.slug)
+		brandLine := productBrandLine(test.slug)
+		application := productApplication(category, test.slug, parseGrades(test.slug, true))
+		if category != test.category || brandLine != test.brandLine || application != test.application {
+			t.Errorf("%s: got %q, %q, %q; want %q, %q, %q", test.slug,
+				category, brandLine, application, test.category, test.brandLine, test.application)
+		}
+	}
+}
+
+func TestClassifyProductOverrides(t *testing.T) {
+	classify := func(slug string, overrides map[string]Taxonomy) Taxonomy {
+		product := &Product{Slug: slug, Grades: parseGrades(slug, true)}
+		classifyProduct(product, slug, overrides)
+		return product.taxonomy()
+	}
+
+	// The overrides kept in the repository
+	overrides := loadTaxonomyOverrides(taxonomyOverridesPath)
+	tests := []struct {
+		slug string
+		want Taxonomy
+	}{
+		// The rules alone would say automotive
+		{"cam2-super-hd-sae-10w-engine-oil", Taxonomy{"engine oil", "Super HD", "HD diesel"}},
+		{"cam2-cotton-picker-spindle-cleaner", Taxonomy{"aerosols & chemicals", "CAM2", "industrial"}},
+		{"cam2-nitrile-gloves-8mil-black-medium", Taxonomy{"shop supplies", "CAM2", "general"}},
+		// No override
+		{"cam2-synavex-5w-30-sp-gf-6a-full-synthetic-engine-oil", Taxonomy{"engine oil", "Synavex", "automotive"}},
+	}
+	for _, test := range tests {
+		if got := classify(test.slug, overrides); got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.slug, got, test.want)
+		}
+	}
+
+	// Empty fields of an override keep the classified value
+	path := filepath.Join(t.TempDir(), "taxonomy_overrides.json")
+	os.WriteFile(path, []byte(`{"cam2-magnum-gear-oil-sae-80w-90-gl-5": {"brand_line": "Magnum HD"}}`), 0o644)
+	if got, want := classify("cam2-magnum-gear-oil-sae-80w-90-gl-5", loadTaxonomyOverrides(path)), (Taxonomy{"gear oil", "Magnum HD", "automotive"}); got != want {
+		t.Errorf("partial override: got %+v, want %+v", got, want)
+	}
+	if overrides := loadTaxonomyOverrides(filepath.Join(t.TempDir(), "missing.json")); len(overrides) != 0 {
+		t.Errorf("missing file gave %v", overrides)
+	}
+}
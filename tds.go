@@ -0,0 +1,297 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Property keys used for values parsed from technical data sheets
+const (
+	propertyKV40       = "kv40"          // Kinematic viscosity at 40 °C, cSt
+	propertyKV100      = "kv100"         // Kinematic viscosity at 100 °C, cSt
+	propertyVI         = "vi"            // Viscosity index
+	propertyFlashPoint = "flash_point_c" // Flash point, °C
+	propertyPourPoint  = "pour_point_c"  // Pour point, °C
+)
+
+// TDSColumn is one product (one grade) in a technical data sheet table
+type TDSColumn struct {
+	Grade       string             `json:"grade,omitempty"`
+	ProductCode string             `json:"product_code,omitempty"`
+	Properties  map[string]float64 `json:"properties"`
+}
+
+// TDSSheet holds everything parsed from one technical data sheet
+type TDSSheet struct {
+	File    string      `json:"file"`
+	Columns []TDSColumn `json:"columns"`
+}
+
+// documentKind guesses whether a downloaded PDF is a safety data sheet
+// ("sds"), a technical data sheet or product bulletin ("tds") or something
+// else, based on its sanitized filename
+func documentKind(filename string) string {
+	name := strings.TrimSuffix(strings.ToLower(getFilename(filename)), ".pdf")
+	tokens := strings.Split(name, "_")
+	for _, token := range tokens {
+		switch token {
+		case "sds", "msds":
+			return "sds"
+		}
+	}
+	for _, token := range tokens {
+		switch token {
+		case "tds", "tsd", "pds", "bulletin", "pb":
+			return "tds"
+		}
+	}
+	return "other"
+}
+
+// tdsPropertyPatterns recognise the row labels of the typical properties
+// tables; the order matters because the first match wins
+var tdsPropertyPatterns = []struct {
+	key     string
+	pattern *regexp.Regexp
+}{
+	{propertyKV40, regexp.MustCompile(`(?i)^(kinematic\s+)?visc[^@]*?(@|at)?\s*40\s*[°º˚]?\s*C\b`)},
+	{propertyKV100, regexp.MustCompile(`(?i)^(kinematic\s+)?visc[^@]*?(@|at)?\s*100\s*[°º˚]?\s*C\b`)},
+	{propertyVI, regexp.MustCompile(`(?i)^visc\w*\s+index\b|^v\.?i\.?\b`)},
+	{propertyFlashPoint, regexp.MustCompile(`(?i)^flash\s*point\b`)},
+	{propertyPourPoint, regexp.MustCompile(`(?i)^pour\s*point\b`)},
+}
+
+// Header rows that name the columns of a properties table
+var (
+	tdsGradeRowPattern   = regexp.MustCompile(`(?i)^((sae|iso|nlgi)(\s+viscosity)?\s+grade|iso\s+vg)\b[:\s]*`)
+	tdsCodeRowPattern    = regexp.MustCompile(`(?i)^product\s+codes?\b[:\s]*`)
+	tdsProductNumberLine = regexp.MustCompile(`(?i)product\s*#\s*(\d+)\s*$`)
+)
+
+// Pieces of a row that are not values
+var (
+	tdsMethodPattern      = regexp.MustCompile(`(?i)(test\s+method|(ASTM|ISO|DIN)\s*D?[-\s]*\d{2,5}[A-Z]?|\bD[-\s]?\d{3,4}[A-Z]?\b)`)
+	tdsParenthesesPattern = regexp.MustCompile(`\([^)]*\)`)
+	tdsSpacedMinus        = regexp.MustCompile(`(^|\s)[-–−]\s+(\d)`)
+	tdsUnitsPattern       = regexp.MustCompile(`(?i)(@|\bat\b)?\s*-?\d*\s*[°º˚]\s*[CF]\b|cSt|mm²/s|mm2/s|\bCOC\b|\bPMCC\b|\bmin\.?|\bmax\.?|[,:]`)
+	tdsValuePattern       = regexp.MustCompile(`^[<>≥≤~+]?(-?\d+(?:\.\d+)?)`)
+)
+
+// splitTDSRow separates a table row into its label and its value tokens
+func splitTDSRow(line string) (string, []string) {
+	line = strings.NewReplacer("−", "-", "–", "-", "ºC", "°C", "˚C", "°C", "ºF", "°F", "˚F", "°F").Replace(line)
+	line = tdsSpacedMinus.ReplaceAllString(line, "$1-$2")
+	stripped := tdsMethodPattern.ReplaceAllString(line, " ")
+	stripped = tdsParenthesesPattern.ReplaceAllString(stripped, " ")
+	stripped = tdsUnitsPattern.ReplaceAllString(stripped, " ")
+	fields := strings.Fields(stripped)
+	// Values are the trailing run of numeric tokens
+	start := len(fields)
+	for start > 0 && tdsValuePattern.MatchString(fields[start-1]) {
+		start--
+	}
+	return line, fields[start:]
+}
+
+// parseTDSValue reads the leading number of a token such as ">90", "-10/14"
+// or "11.5-12.5"
+func parseTDSValue(token string) (float64, bool) {
+	match := tdsValuePattern.FindStringSubmatch(token)
+	if match == nil {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	return value, err == nil
+}
+
+// fahrenheitFirst reports whether a flash or pour point label gives °F as
+// its primary unit
+func fahrenheitFirst(label string) bool {
+	celsius := strings.Index(label, "°C")
+	fahrenheit := strings.Index(label, "°F")
+	if fahrenheit < 0 {
+		fahrenheit = strings.Index(label, "° F")
+	}
+	return fahrenheit >= 0 && (celsius < 0 || fahrenheit < celsius)
+}
+
+// tdsTable collects the columns of one properties table while parsing
+type tdsTable struct {
+	columns []TDSColumn
+}
+
+// ensureColumns sizes the table, reporting false if count does not match
+func (t *tdsTable) ensureColumns(count int) bool {
+	if len(t.columns) == 0 {
+		for i := 0; i < count; i++ {
+			t.columns = append(t.columns, TDSColumn{Properties: map[string]float64{}})
+		}
+		return true
+	}
+	return len(t.columns) == count
+}
+
+// hasProperties reports whether any column already has a value
+func (t *tdsTable) hasProperties() bool {
+	for _, column := range t.columns {
+		if len(column.Properties) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// gradeLabels splits the values of an SAE or ISO grade row into labels,
+// keeping "SAE 30" or "NITRO 70" together
+func gradeLabels(rest string) []string {
+	var labels []string
+	fields := strings.Fields(tdsMethodPattern.ReplaceAllString(rest, " "))
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if _, err := strconv.Atoi(field); err != nil && i+1 < len(fields) && !strings.ContainsAny(field, "0123456789") {
+			if _, err := strconv.Atoi(fields[i+1]); err == nil { // "SAE 30"
+				field += " " + fields[i+1]
+				i++
+			}
+		}
+		labels = append(labels, field)
+	}
+	return labels
+}
+
+// parseTDSText extracts typical property tables from the text of a TDS
+func parseTDSText(text string) []TDSColumn {
+	var columns []TDSColumn
+	table := &tdsTable{}
+	flush := func() {
+		if table.hasProperties() {
+			columns = append(columns, table.columns...)
+		}
+		table = &tdsTable{}
+	}
+	singleCode := ""
+	pendingKey, pendingLabel, pendingLines := "", "", 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if match := tdsProductNumberLine.FindStringSubmatch(line); match != nil {
+			singleCode = match[1]
+		}
+		if tdsGradeRowPattern.MatchString(line) {
+			labels := gradeLabels(tdsGradeRowPattern.ReplaceAllString(line, ""))
+			if len(labels) > 0 && strings.ContainsAny(strings.Join(labels, ""), "0123456789") {
+				if table.hasProperties() || len(table.columns) > 0 && table.columns[0].Grade != "" {
+					flush()
+				}
+				if table.ensureColumns(len(labels)) {
+					for i := range labels {
+						table.columns[i].Grade = labels[i]
+					}
+				}
+				pendingKey = ""
+				continue
+			}
+		}
+		if tdsCodeRowPattern.MatchString(line) {
+			codes := strings.Fields(strings.NewReplacer(",", " ").Replace(tdsCodeRowPattern.ReplaceAllString(line, "")))
+			if len(codes) > 0 && !strings.EqualFold(codes[0], "see") {
+				if table.hasProperties() {
+					flush()
+				}
+				if table.ensureColumns(len(codes)) {
+					for i := range codes {
+						table.columns[i].ProductCode = codes[i]
+					}
+				}
+			}
+			pendingKey = ""
+			continue
+		}
+		key := ""
+		for _, candidate := range tdsPropertyPatterns {
+			if candidate.pattern.MatchString(line) {
+				key = candidate.key
+				break
+			}
+		}
+		label, values := splitTDSRow(line)
+		if key == "" && pendingKey != "" {
+			// Continuation of a label that wrapped onto several lines
+			if len(values) == 0 && pendingLines < 3 {
+				pendingLabel += " " + line
+				pendingLines++
+				if pendingKey == "visc" {
+					for _, candidate := range tdsPropertyPatterns[:2] {
+						if candidate.pattern.MatchString(pendingLabel) {
+							pendingKey = candidate.key
+						}
+					}
+				}
+				continue
+			}
+			key, label = pendingKey, pendingLabel
+		}
+		pendingKey = ""
+		if key == "" {
+			if strings.HasPrefix(strings.ToLower(line), "viscosity") && len(values) == 0 {
+				pendingKey, pendingLabel, pendingLines = "visc", line, 0
+			}
+			continue
+		}
+		if len(values) == 0 {
+			pendingKey, pendingLabel, pendingLines = key, label, 0
+			continue
+		}
+		if key == "visc" || !table.ensureColumns(len(values)) {
+			continue
+		}
+		for i, token := range values {
+			value, ok := parseTDSValue(token)
+			if !ok {
+				continue
+			}
+			if (key == propertyFlashPoint || key == propertyPourPoint) && fahrenheitFirst(label) {
+				value = (value - 32) * 5 / 9
+			}
+			if _, exists := table.columns[i].Properties[key]; !exists {
+				table.columns[i].Properties[key] = value
+			}
+		}
+	}
+	flush()
+	if len(columns) == 1 && columns[0].ProductCode == "" {
+		columns[0].ProductCode = singleCode
+	}
+	return columns
+}
+
+// loadTDSSheets parses every technical data sheet in dir that contains a
+// properties table
+func loadTDSSheets(dir string) []TDSSheet {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pdf"))
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	sort.Strings(paths)
+	var sheets []TDSSheet
+	for _, path := range paths {
+		if documentKind(path) == "sds" {
+			continue
+		}
+		text, err := pdfText(path)
+		if err != nil {
+			log.Printf("Failed to read %s: %v", path, err)
+			continue
+		}
+		columns := parseTDSText(text)
+		if len(columns) == 0 {
+			continue
+		}
+		sheets = append(sheets, TDSSheet{File: getFilename(path), Columns: columns})
+	}
+	return sheets
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// The fixtures in testdata are the text pdfText gives for the common data
+// sheet layouts: a table with one column per grade and a product code row,
+// a single product whose labels wrap and whose temperatures are in °F, and
+// an ISO VG table with comma separated codes.
+func TestParseTDSText(t *testing.T) {
+	tests := []struct {
+		file string
+		want []TDSColumn
+	}{
+		{"super-hd-heavy-duty-engine-oil_tds.txt", []TDSColumn{
+			{Grade: "10W-30", ProductCode: "80570", Properties: map[string]float64{propertyKV40: 78.4, propertyKV100: 11.6, propertyVI: 141, propertyFlashPoint: 228, propertyPourPoint: -36}},
+			{Grade: "15W-40", ProductCode: "80571", Properties: map[string]float64{propertyKV40: 112.6, propertyKV100: 15.1, propertyVI: 139, propertyFlashPoint: 232, propertyPourPoint: -33}},
+			// "SAE 30" stays one label, and the spaced "− 21" is negative
+			{Grade: "SAE 30", ProductCode: "80572", Properties: map[string]float64{propertyKV40: 98.1, propertyKV100: 11.4, propertyVI: 104, propertyFlashPoint: 240, propertyPourPoint: -21}},
+		}},
+		{"protect75-high-mileage_tds.txt", []TDSColumn{
+			{ProductCode: "80565", Properties: map[string]float64{propertyKV40: 62.1, propertyKV100: 10.4, propertyVI: 158, propertyFlashPoint: 225, propertyPourPoint: -40}},
+		}},
+		{"aw-hydraulic-oil_tds.txt", []TDSColumn{
+			{Grade: "32", ProductCode: "80590", Properties: map[string]float64{propertyKV40: 32.4, propertyKV100: 5.4, propertyVI: 102, propertyFlashPoint: 200, propertyPourPoint: -30}},
+			{Grade: "46", ProductCode: "80591", Properties: map[string]float64{propertyKV40: 46.2, propertyKV100: 6.8, propertyVI: 101, propertyFlashPoint: 210, propertyPourPoint: -27}},
+			{Grade: "68", ProductCode: "80592", Properties: map[string]float64{propertyKV40: 67.8, propertyKV100: 8.7, propertyVI: 100, propertyFlashPoint: 220, propertyPourPoint: -24}},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			text, err := os.ReadFile(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := parseTDSText(string(text)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %+v\nwant %+v", got, test.want)
+			}
+		})
+	}
+
+	// Text without a properties table gives nothing
+	if got := parseTDSText("CAM2 Cotton Picker Spindle Cleaner\nDilute 1:40 with water.\n"); len(got) != 0 {
+		t.Errorf("no table: got %+v", got)
+	}
+}
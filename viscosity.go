@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Temperatures of the two standard viscosity measurements in kelvin
+const (
+	kelvin40  = 313.15
+	kelvin100 = 373.15
+)
+
+// waltherZ converts kinematic viscosity in cSt to the Z term of ASTM D341,
+// including the low viscosity correction from the 2017 revision
+func waltherZ(viscosity float64) float64 {
+	return viscosity + 0.7 + math.Exp(-1.47-1.84*viscosity-0.51*viscosity*viscosity)
+}
+
+// waltherViscosity is the inverse of waltherZ
+func waltherViscosity(z float64) float64 {
+	shifted := z - 0.7
+	return shifted - math.Exp(-0.7487-3.295*shifted+0.6119*shifted*shifted-0.3193*shifted*shifted*shifted)
+}
+
+// waltherLine returns the constants A and B of the ASTM D341 equation
+// log10(log10(Z)) = A - B*log10(T) through the two measured viscosities
+func waltherLine(kv40, kv100 float64) (float64, float64, error) {
+	if kv40 <= 0 || kv100 <= 0 || kv100 >= kv40 {
+		return 0, 0, errors.New("viscosity at 40 °C must be greater than at 100 °C and both positive")
+	}
+	y40 := math.Log10(math.Log10(waltherZ(kv40)))
+	y100 := math.Log10(math.Log10(waltherZ(kv100)))
+	b := (y40 - y100) / (math.Log10(kelvin100) - math.Log10(kelvin40))
+	a := y40 + b*math.Log10(kelvin40)
+	return a, b, nil
+}
+
+// viscosityAt estimates kinematic viscosity in cSt at tempC using ASTM D341
+func viscosityAt(kv40, kv100, tempC float64) (float64, error) {
+	a, b, err := waltherLine(kv40, kv100)
+	if err != nil {
+		return 0, err
+	}
+	z := math.Pow(10, math.Pow(10, a-b*math.Log10(tempC+273.15)))
+	return waltherViscosity(z), nil
+}
+
+// temperatureForViscosity returns the temperature in °C at which the oil
+// reaches the target viscosity in cSt
+func temperatureForViscosity(kv40, kv100, target float64) (float64, error) {
+	a, b, err := waltherLine(kv40, kv100)
+	if err != nil {
+		return 0, err
+	}
+	if target <= 0 {
+		return 0, errors.New("target viscosity must be positive")
+	}
+	logT := (a - math.Log10(math.Log10(waltherZ(target)))) / b
+	return math.Pow(10, logT) - 273.15, nil
+}
+
+// viscosityIndexReference returns L and H from ASTM D2270: the 40 °C
+// viscosities of the VI 0 and VI 100 reference oils that share the sample's
+// 100 °C viscosity. Below 70 cSt these are piecewise quadratic fits of the
+// standard's Table 1, above it the standard's own equations.
+func viscosityIndexReference(kv100 float64) (float64, float64) {
+	segments := []struct {
+		upTo       float64
+		l2, l1, l0 float64
+		h2, h1, h0 float64
+	}{
+		{3.8, 1.14673, 1.7576, -0.109, 0.84155, 1.5521, -0.077},
+		{4.4, 3.38095, -15.4952, 33.196, 0.78571, 1.7929, -0.183},
+		{5.0, 2.5, -7.2, 13.8, 0.82143, 1.5679, 0.119},
+		{6.4, 0.101, 16.635, -45.47, 0.04985, 9.1613, -18.557},
+		{7.0, 3.35714, -23.5643, 78.466, 0.22619, 7.7369, -16.665},
+		{7.7, 0.01191, 21.475, -72.87, 0.79762, -0.7321, 14.61},
+		{9.0, 0.41858, 16.1558, -56.04, 0.05794, 10.5156, -28.24},
+		{12, 0.88779, 7.5527, -16.6, 0.26665, 6.7015, -10.81},
+		{15, 0.7672, 10.7972, -38.18, 0.20073, 8.4658, -22.49},
+		{18, 0.97305, 5.3135, -2.2, 0.28889, 5.9741, -4.93},
+		{22, 0.97256, 5.25, -0.98, 0.24504, 7.416, -16.73},
+		{28, 0.91413, 7.4759, -21.82, 0.20323, 9.1267, -34.23},
+		{40, 0.87031, 9.7157, -50.77, 0.18411, 10.1015, -46.75},
+		{55, 0.84703, 12.6752, -133.31, 0.17029, 11.4866, -80.62},
+		{70, 0.85921, 11.1009, -83.19, 0.1713, 11.368, -76.94},
+		{math.Inf(1), 0.8353, 14.67, -216, 0.1684, 11.85, -97},
+	}
+	for _, s := range segments {
+		if kv100 <= s.upTo {
+			return s.l2*kv100*kv100 + s.l1*kv100 + s.l0, s.h2*kv100*kv100 + s.h1*kv100 + s.h0
+		}
+	}
+	return 0, 0
+}
+
+// viscosityIndex calculates the viscosity index per ASTM D2270
+func viscosityIndex(kv40, kv100 float64) (float64, error) {
+	if kv100 < 2 {
+		return 0, errors.New("ASTM D2270 is not defined below 2 cSt at 100 °C")
+	}
+	if kv40 <= kv100 {
+		return 0, errors.New("viscosity at 40 °C must be greater than at 100 °C")
+	}
+	l, h := viscosityIndexReference(kv100)
+	if kv40 >= h { // Procedure A, VI up to and including 100
+		return (l - kv40) / (l - h) * 100, nil
+	}
+	// Procedure B, VI above 100
+	n := (math.Log10(h) - math.Log10(kv40)) / math.Log10(kv100)
+	return (math.Pow(10, n)-1)/0.00715 + 100, nil
+}
+
+// parseTemperatures reads a comma separated list of temperatures
+func parseTemperatures(list string) ([]float64, error) {
+	var temperatures []float64
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid temperature %q", field)
+		}
+		temperatures = append(temperatures, value)
+	}
+	return temperatures, nil
+}
+
+// viscosityCommand implements "viscosity": a single calculation when -kv40
+// and -kv100 are given, otherwise a report over every parsed TDS
+func viscosityCommand(args []string) {
+	flags := flag.NewFlagSet("viscosity", flag.ExitOnError)
+	kv40 := flags.Float64("kv40", 0, "kinematic viscosity at 40 °C in cSt")
+	kv100 := flags.Float64("kv100", 0, "kinematic viscosity at 100 °C in cSt")
+	at := flags.String("at", "", "comma separated temperatures in °C to estimate viscosity at")
+	reach := flags.Float64("reach", 0, "with -kv40 and -kv100, the temperature in °C at which the oil reaches this viscosity in cSt")
+	minimum := flags.Float64("min", 0, "only list oils with at least this viscosity at the first -at temperature")
+	maximum := flags.Float64("max", 0, "only list oils with at most this viscosity at the first -at temperature")
+	tolerance := flags.Float64("tolerance", 3, "VI difference that counts as a mismatch")
+	mismatches := flags.Bool("mismatches", false, "only list sheets whose published VI disagrees with the calculation")
+	dir := flags.String("dir", "PDFs/", "directory of downloaded PDFs")
+	flags.Parse(args)
+
+	temperatures, err := parseTemperatures(*at)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if (*minimum > 0 || *maximum > 0) && len(temperatures) == 0 {
+		fmt.Fprintln(os.Stderr, "-min and -max filter on the first -at temperature, e.g. -at 0 -max 1000")
+		os.Exit(2)
+	}
+
+	if *kv40 > 0 || *kv100 > 0 {
+		index, err := viscosityIndex(*kv40, *kv100)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Viscosity index: %.0f\n", math.Round(index))
+		for _, temperature := range temperatures {
+			viscosity, err := viscosityAt(*kv40, *kv100, temperature)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Viscosity at %g °C: %.2f cSt\n", temperature, viscosity)
+		}
+		if *reach > 0 {
+			temperature, err := temperatureForViscosity(*kv40, *kv100, *reach)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Reaches %g cSt at %.1f °C\n", *reach, temperature)
+		}
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	header := "FILE\tGRADE\tCODE\tKV40\tKV100\tVI (TDS)\tVI (D2270)\tCHECK"
+	for _, temperature := range temperatures {
+		header += fmt.Sprintf("\tcSt @ %g°C", temperature)
+	}
+	fmt.Fprintln(writer, header)
+	for _, sheet := range loadTDSSheets(*dir) {
+		for _, column := range sheet.Columns {
+			k40, has40 := column.Properties[propertyKV40]
+			k100, has100 := column.Properties[propertyKV100]
+			if !has40 || !has100 {
+				continue
+			}
+			computed, err := viscosityIndex(k40, k100)
+			if err != nil {
+				continue
+			}
+			published, hasVI := column.Properties[propertyVI]
+			check := "ok"
+			switch {
+			case !hasVI:
+				check = "no VI published"
+			case math.Abs(published-computed) > *tolerance:
+				check = fmt.Sprintf("MISMATCH (%+.0f)", published-math.Round(computed))
+			}
+			if *mismatches && !strings.HasPrefix(check, "MISMATCH") {
+				continue
+			}
+			row := fmt.Sprintf("%s\t%s\t%s\t%g\t%g\t", sheet.File, column.Grade, column.ProductCode, k40, k100)
+			if hasVI {
+				row += fmt.Sprintf("%g", published)
+			} else {
+				row += "-"
+			}
+			row += fmt.Sprintf("\t%.0f\t%s", math.Round(computed), check)
+			keep := true
+			for i, temperature := range temperatures {
+				viscosity, err := viscosityAt(k40, k100, temperature)
+				if err != nil {
+					keep = false
+					break
+				}
+				if i == 0 && (*minimum > 0 && viscosity < *minimum || *maximum > 0 && viscosity > *maximum) {
+					keep = false
+					break
+				}
+				row += fmt.Sprintf("\t%.2f", viscosity)
+			}
+			if keep {
+				fmt.Fprintln(writer, row)
+			}
+		}
+	}
+	writer.Flush()
+}
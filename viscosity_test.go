@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// The worked examples of ASTM D2270
+func TestViscosityIndex(t *testing.T) {
+	tests := []struct {
+		name         string
+		kv40, kv100  float64
+		wantVI       float64
+		wantL, wantH float64 // From Table 1, 0 when the example does not give them
+	}{
+		{"procedure A", 73.30, 8.86, 92.43, 119.94, 69.48},
+		{"procedure B", 22.83, 5.05, 156.0, 0, 28.97},
+		{"table 1 at 2 cSt", 0, 2.0, 0, 7.994, 6.394},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l, h := viscosityIndexReference(test.kv100)
+			if test.wantL != 0 && math.Abs(l-test.wantL) > 0.05 {
+				t.Errorf("L = %.3f, want %.2f", l, test.wantL)
+			}
+			if math.Abs(h-test.wantH) > 0.05 {
+				t.Errorf("H = %.3f, want %.2f", h, test.wantH)
+			}
+			if test.wantVI == 0 {
+				return
+			}
+			index, err := viscosityIndex(test.kv40, test.kv100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The standard reports the index rounded to a whole number
+			if math.Round(index) != math.Round(test.wantVI) || math.Abs(index-test.wantVI) > 0.5 {
+				t.Errorf("VI = %.2f, want %.2f", index, test.wantVI)
+			}
+		})
+	}
+}
+
+func TestViscosityIndexErrors(t *testing.T) {
+	for _, test := range []struct{ kv40, kv100 float64 }{{10, 1.5}, {5, 5}, {4, 6}} {
+		if _, err := viscosityIndex(test.kv40, test.kv100); err == nil {
+			t.Errorf("viscosityIndex(%g, %g) gave no error", test.kv40, test.kv100)
+		}
+	}
+}
+
+func TestViscosityAt(t *testing.T) {
+	kv40, kv100 := 73.30, 8.86
+	// The ASTM D341 line passes through both measurements
+	for _, point := range []struct{ temperature, want float64 }{{40, kv40}, {100, kv100}} {
+		got, err := viscosityAt(kv40, kv100, point.temperature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-point.want) > 0.01 {
+			t.Errorf("viscosityAt(%g °C) = %.3f, want %.2f", point.temperature, got, point.want)
+		}
+	}
+	// Colder is thicker
+	previous := math.Inf(1)
+	for temperature := -20.0; temperature <= 150; temperature += 10 {
+		got, err := viscosityAt(kv40, kv100, temperature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got >= previous {
+			t.Errorf("viscosity at %g °C is %.2f, not below %.2f", temperature, got, previous)
+		}
+		previous = got
+	}
+	// temperatureForViscosity is the inverse
+	for _, temperature := range []float64{-10, 25, 80, 120} {
+		viscosity, _ := viscosityAt(kv40, kv100, temperature)
+		got, err := temperatureForViscosity(kv40, kv100, viscosity)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-temperature) > 0.01 {
+			t.Errorf("temperatureForViscosity(%.2f) = %.2f °C, want %g", viscosity, got, temperature)
+		}
+	}
+	if _, err := viscosityAt(8, 9, 50); err == nil {
+		t.Error("viscosityAt accepted a thinner oil at 40 °C than at 100 °C")
+	}
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// warcDir is where archiving crawls usually write their WARC files
+const warcDir = "warc"
+
+// httpTransport carries every request of the crawler; while crawling it
+// archives them and while replaying it answers from an archive
+var httpTransport http.RoundTripper = http.DefaultTransport
+
+// warcHeader is one named field of a WARC record header, kept in order
+type warcHeader struct {
+	Name, Value string
+}
+
+// warcRecord is one record of a WARC file
+type warcRecord struct {
+	Headers []warcHeader
+	Block   []byte
+}
+
+// header returns the value of a record header field
+func (r *warcRecord) header(name string) string {
+	for _, field := range r.Headers {
+		if strings.EqualFold(field.Name, name) {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// warcRecordID returns a new urn:uuid record ID
+func warcRecordID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	id[6] = id[6]&0x0f | 0x40 // Version 4
+	id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
+
+// warcDigest returns the SHA-1 digest of data in the base32 form WARC tools use
+func warcDigest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// warcWriter writes gzip-compressed records, one gzip member per record, so
+// that readers can seek to any record
+type warcWriter struct {
+	mu   sync.Mutex
+	file *os.File
+	path string
+}
+
+// createWARC starts a WARC file in dir named after the current time and
+// writes its warcinfo record
+func createWARC(dir string) (*warcWriter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := "cam2-" + time.Now().UTC().Format("20060102T150405Z") + ".warc.gz"
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	writer := &warcWriter{file: file, path: file.Name()}
+	info := "software: cam2-com-documentation\r\n" +
+		"format: WARC File Format 1.1\r\n" +
+		"conformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n" +
+		"description: Product pages and data sheets fetched from cam2.com\r\n"
+	err = writer.write(warcRecord{
+		Headers: []warcHeader{
+			{"WARC-Type", "warcinfo"},
+			{"WARC-Record-ID", warcRecordID()},
+			{"WARC-Date", time.Now().UTC().Format(time.RFC3339)},
+			{"WARC-Filename", name},
+			{"Content-Type", "application/warc-fields"},
+		},
+		Block: []byte(info),
+	})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return writer, nil
+}
+
+// write appends one record, adding its block digest and length
+func (w *warcWriter) write(record warcRecord) error {
+	var header bytes.Buffer
+	header.WriteString("WARC/1.1\r\n")
+	for _, field := range record.Headers {
+		fmt.Fprintf(&header, "%s: %s\r\n", field.Name, field.Value)
+	}
+	fmt.Fprintf(&header, "WARC-Block-Digest: %s\r\n", warcDigest(record.Block))
+	fmt.Fprintf(&header, "Content-Length: %d\r\n\r\n", len(record.Block))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	compressor := gzip.NewWriter(w.file)
+	compressor.Write(header.Bytes())
+	compressor.Write(record.Block)
+	compressor.Write([]byte("\r\n\r\n"))
+	return compressor.Close()
+}
+
+// close finishes the WARC file
+func (w *warcWriter) close() {
+	if err := w.file.Close(); err != nil {
+		log.Println(err)
+	}
+	log.Println("Archived the crawl in", w.path)
+}
+
+// warcTransport archives each request and response that passes through it
+// as request, response and metadata records
+type warcTransport struct {
+	next       http.RoundTripper
+	writer     *warcWriter
+	revalidate bool // Let conditional requests through, see -warc-incremental
+}
+
+func (t *warcTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	// A bodyless 304 proves nothing about what was served on its own, so
+	// archived requests ask for the whole response unless an earlier full
+	// archive holds it
+	if !t.revalidate && (request.Header.Get("If-None-Match") != "" || request.Header.Get("If-Modified-Since") != "") {
+		request = request.Clone(request.Context())
+		request.Header.Del("If-None-Match")
+		request.Header.Del("If-Modified-Since")
+	}
+	started := time.Now()
+	response, err := t.next.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = io.NopCloser(bytes.NewReader(body))
+
+	// The transport has already removed chunking, so the archived message
+	// is the identity encoded one
+	var requestBlock bytes.Buffer
+	fmt.Fprintf(&requestBlock, "%s %s HTTP/1.1\r\nHost: %s\r\n", request.Method, request.URL.RequestURI(), request.URL.Host)
+	request.Header.Write(&requestBlock)
+	requestBlock.WriteString("\r\n")
+	var responseBlock bytes.Buffer
+	fmt.Fprintf(&responseBlock, "HTTP/%d.%d %s\r\n", response.ProtoMajor, response.ProtoMinor, response.Status)
+	response.Header.Write(&responseBlock)
+	responseBlock.WriteString("\r\n")
+	responseBlock.Write(body)
+
+	date := started.UTC().Format(time.RFC3339)
+	target := request.URL.String()
+	responseID, requestID := warcRecordID(), warcRecordID()
+	records := []warcRecord{
+		{Headers: []warcHeader{
+			{"WARC-Type", "response"},
+			{"WARC-Record-ID", responseID},
+			{"WARC-Date", date},
+			{"WARC-Target-URI", target},
+			{"WARC-Payload-Digest", warcDigest(body)},
+			{"Content-Type", "application/http;msgtype=response"},
+		}, Block: responseBlock.Bytes()},
+		{Headers: []warcHeader{
+			{"WARC-Type", "request"},
+			{"WARC-Record-ID", requestID},
+			{"WARC-Date", date},
+			{"WARC-Target-URI", target},
+			{"WARC-Concurrent-To", responseID},
+			{"Content-Type", "application/http;msgtype=request"},
+		}, Block: requestBlock.Bytes()},
+	}
+	metadata := fmt.Sprintf("fetchTimeMs: %d\r\n", time.Since(started).Milliseconds())
+	if strings.Contains(response.Header.Get("Content-Type"), "html") {
+		for _, link := range removeDuplicatesFromSlice(extractPDFUrls(string(body))) {
+			metadata += "outlink: " + link + "\r\n"
+		}
+	}
+	records = append(records, warcRecord{Headers: []warcHeader{
+		{"WARC-Type", "metadata"},
+		{"WARC-Record-ID", warcRecordID()},
+		{"WARC-Date", date},
+		{"WARC-Target-URI", target},
+		{"WARC-Refers-To", responseID},
+		{"Content-Type", "application/warc-fields"},
+	}, Block: []byte(metadata)})
+	for _, record := range records {
+		if err := t.writer.write(record); err != nil {
+			log.Println("Failed to archive", target, err)
+			break
+		}
+	}
+	return response, nil
+}
+
+// readWARC reads every record of a WARC file, compressed or not
+func readWARC(path string) ([]warcRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var source io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		decompressor, err := gzip.NewReader(file) // Reads every member
+		if err != nil {
+			return nil, err
+		}
+		source = decompressor
+	}
+	reader := bufio.NewReader(source)
+	var records []warcRecord
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			continue // Blank lines between records
+		}
+		if !strings.HasPrefix(line, "WARC/") {
+			return records, fmt.Errorf("%s: expected a WARC record, found %q", path, line)
+		}
+		var record warcRecord
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return records, err
+			}
+			line = strings.TrimRight(line, "\r\n")
+			if line == "" {
+				break
+			}
+			name, value, _ := strings.Cut(line, ":")
+			record.Headers = append(record.Headers, warcHeader{strings.TrimSpace(name), strings.TrimSpace(value)})
+		}
+		length, err := strconv.Atoi(record.header("Content-Length"))
+		if err != nil {
+			return records, fmt.Errorf("%s: record without Content-Length", path)
+		}
+		record.Block = make([]byte, length)
+		if _, err := io.ReadFull(reader, record.Block); err != nil {
+			return records, err
+		}
+		if digest := record.header("WARC-Block-Digest"); digest != "" && strings.HasPrefix(digest, "sha1:") && digest != warcDigest(record.Block) {
+			log.Printf("%s: block digest mismatch for %s", path, record.header("WARC-Target-URI"))
+		}
+		records = append(records, record)
+	}
+}
+
+// warcReplayTransport answers requests with the responses archived for
+// their URL; URLs that were not archived fail as if the site were down
+type warcReplayTransport struct {
+	responses map[string][]byte // Target URI → HTTP response message
+}
+
+// newWARCReplayTransport indexes the responses of WARC files; later ones
+// replace earlier ones for the same URL. A 304 Not Modified, archived when
+// the page cache revalidated a page, keeps the earlier full response.
+func newWARCReplayTransport(paths []string) (*warcReplayTransport, error) {
+	transport := &warcReplayTransport{responses: map[string][]byte{}}
+	for _, path := range paths {
+		records, err := readWARC(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			uri := record.header("WARC-Target-URI")
+			if record.header("WARC-Type") != "response" {
+				continue
+			}
+			statusLine, _, _ := bytes.Cut(record.Block, []byte("\r\n"))
+			if bytes.Contains(statusLine, []byte(" 304 ")) && transport.responses[uri] != nil {
+				continue
+			}
+			transport.responses[uri] = record.Block
+		}
+	}
+	return transport, nil
+}
+
+func (t *warcReplayTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	message, found := t.responses[request.URL.String()]
+	if !found {
+		return nil, errors.New("not in the archive")
+	}
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(message)), request)
+}
+
+// replayCommand implements "replay": rerun the extraction on what WARC
+// files recorded instead of the live site
+func replayCommand(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	list := flags.Bool("list", false, "only list the archived responses")
+	dir := flags.String("dir", "", "directory for the PDFs, withdrawn/ and the manifest (default a new temporary directory)")
+	manifestFile := flags.String("manifest", "", "manifest to update (default manifest.json in -dir)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: replay [flags] file.warc.gz ...")
+		fmt.Fprintln(flags.Output(), "Reruns the crawl against the archived responses into a scratch directory,")
+		fmt.Fprintln(flags.Output(), "leaving manifest.json, PDFs/ and withdrawn/ of the mirror alone, and sends")
+		fmt.Fprintln(flags.Output(), "no notifications.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	transport, err := newWARCReplayTransport(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *list {
+		var uris []string
+		for uri := range transport.responses {
+			uris = append(uris, uri)
+		}
+		sort.Strings(uris)
+		for _, uri := range uris {
+			status := "?"
+			if line, _, found := bytes.Cut(transport.responses[uri], []byte("\r\n")); found {
+				status = string(line)
+			}
+			fmt.Printf("%s\t%s\n", status, uri)
+		}
+		return
+	}
+	if *dir == "" {
+		if *dir, err = os.MkdirTemp("", "cam2-replay-"); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *manifestFile == "" {
+		*manifestFile = filepath.Join(*dir, "manifest.json")
+	}
+	outputDir := filepath.Join(*dir, "PDFs")
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	withdrawnDir = filepath.Join(*dir, "withdrawn") + "/"
+	log.Printf("Replaying %d archived responses into %s", len(transport.responses), *dir)
+	httpTransport = transport
+	crawl(crawlOptions{replay: true, outputDir: outputDir, manifestPath: *manifestFile})
+	fmt.Printf("Replayed into %s (manifest %s)\n", *dir, *manifestFile)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWARCArchivesWholeResponses(t *testing.T) {
+	const page = "<html><body>CAM2 Synavex</body></html>"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		io.WriteString(w, page)
+	}))
+	defer server.Close()
+
+	archive, err := createWARC(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &warcTransport{next: http.DefaultTransport, writer: archive}}
+	request, _ := http.NewRequest("GET", server.URL+"/product/synavex/", nil)
+	request.Header.Set("If-None-Match", `"v1"`) // As the page cache revalidates
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	archive.close()
+	if response.StatusCode != http.StatusOK || string(body) != page {
+		t.Errorf("got %s %q, want the whole page", response.Status, body)
+	}
+
+	// Replaying the archive gives the page back
+	replay, err := newWARCReplayTransport([]string{archive.path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err = (&http.Client{Transport: replay}).Get(server.URL + "/product/synavex/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || string(body) != page {
+		t.Errorf("replayed %s %q, want the whole page", response.Status, body)
+	}
+}
+
+func TestWARCIncrementalKeepsRevalidation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		io.WriteString(w, "page")
+	}))
+	defer server.Close()
+
+	archive, err := createWARC(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &warcTransport{next: http.DefaultTransport, writer: archive, revalidate: true}}
+	request, _ := http.NewRequest("GET", server.URL+"/", nil)
+	request.Header.Set("If-None-Match", `"v1"`)
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	archive.close()
+	if response.StatusCode != http.StatusNotModified {
+		t.Errorf("got %s, want the 304 through", response.Status)
+	}
+	records, err := readWARC(archive.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archived := false
+	for _, record := range records {
+		if record.header("WARC-Type") == "response" && bytes.HasPrefix(record.Block, []byte("HTTP/1.1 304")) {
+			archived = true
+		}
+	}
+	if !archived {
+		t.Error("the 304 was not archived")
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// withdrawnDir keeps the files of withdrawn documents; nothing is ever
+// deleted from it automatically. A replay moves it into its scratch
+// directory.
+var withdrawnDir = "withdrawn/"
+
+// pageGone reports whether a status code means a page was removed, as
+// opposed to a temporary failure that says nothing about the product
+func pageGone(status int) bool {
+	return status == http.StatusNotFound || status == http.StatusGone
+}
+
+// withdrawProduct records that a product page is gone. Its documents are
+// unlinked from it, so the ones no other page links become withdrawn. Other
+// pages that are gone only unlink their documents.
+func (m *Manifest) withdrawProduct(pageURL, date string) {
+	slug := productSlug(pageURL)
+	if slug == "" {
+		for _, document := range m.Documents {
+			document.Pages = difference(document.Pages, []string{pageURL})
+		}
+		return
+	}
+	product := m.Products[slug]
+	if product == nil {
+		product = &Product{Slug: slug, URL: pageURL}
+		m.Products[slug] = product
+	}
+	if product.Withdrawn == "" {
+		product.Withdrawn = date
+		log.Printf("Product page gone, marking %s withdrawn", slug)
+	}
+	for _, filename := range product.Documents {
+		if document := m.Documents[filename]; document != nil {
+			document.Products = difference(document.Products, []string{slug})
+		}
+	}
+}
+
+// moveFile renames a file into another directory, refusing to replace a
+// file that is already there
+func moveFile(filename, fromDir, toDir string) bool {
+	from, to := filepath.Join(fromDir, filename), filepath.Join(toDir, filename)
+	if !fileExists(from) {
+		return false
+	}
+	if fileExists(to) {
+		log.Printf("Not moving %s: %s already exists", from, to)
+		return false
+	}
+	if err := os.MkdirAll(toDir, 0o755); err != nil {
+		log.Println(err)
+		return false
+	}
+	if err := os.Rename(from, to); err != nil {
+		log.Println(err)
+		return false
+	}
+	log.Printf("Moved %s → %s", from, to)
+	return true
+}
+
+// supersede moves the copy of a document that is about to be replaced in
+// place from dir to withdrawn/, named after the day it was replaced, and
+// returns its name there
+func supersede(filename, dir, date string) (string, error) {
+	if err := os.MkdirAll(withdrawnDir, 0o755); err != nil {
+		return "", err
+	}
+	extension := filepath.Ext(filename)
+	stem := strings.TrimSuffix(filename, extension)
+	name := stem + "." + date + extension
+	for attempt := 2; fileExists(filepath.Join(withdrawnDir, name)); attempt++ {
+		name = fmt.Sprintf("%s.%s-%d%s", stem, date, attempt, extension)
+	}
+	if err := os.Rename(filepath.Join(dir, filename), filepath.Join(withdrawnDir, name)); err != nil {
+		return "", err
+	}
+	log.Printf("Moved the replaced copy of %s to %s", filename, filepath.Join(withdrawnDir, name))
+	return name, nil
+}
+
+// applyWithdrawals marks the documents of withdrawn change events with the
+// date and moves their files from dir to withdrawn/; documents that are
+// linked again are reinstated and moved back. A document a revision
+// replaced is withdrawn too once nothing links it any more.
+func (m *Manifest) applyWithdrawals(changes []ChangeEvent, dir, date string) {
+	withdraw := func(document *Document) {
+		if document.Withdrawn == "" {
+			document.Withdrawn = date
+		}
+		moveFile(document.Filename, dir, withdrawnDir)
+	}
+	for _, change := range changes {
+		document := m.Documents[change.Filename]
+		if document == nil {
+			continue
+		}
+		switch change.Type {
+		case "withdrawn":
+			withdraw(document)
+		case "added", "revised":
+			if document.Withdrawn != "" {
+				document.Withdrawn = ""
+				moveFile(document.Filename, withdrawnDir, dir)
+			}
+			if replaced := m.Documents[change.Replaces]; replaced != nil && !replaced.linked() {
+				withdraw(replaced)
+			}
+		}
+	}
+}
+
+// supersededDate is the day a copy replaced in place was moved aside, from
+// the name supersede gave it
+func supersededDate(name string) string {
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if dot := strings.LastIndex(stem, "."); dot >= 0 && len(stem)-dot > 10 {
+		return stem[dot+1 : dot+11]
+	}
+	return ""
+}
+
+// withdrawal is one row of the withdrawn report
+type withdrawal struct {
+	Date     string `json:"date"`
+	Type     string `json:"type"` // "product", or the kind of document
+	Name     string `json:"name"`
+	Location string `json:"location,omitempty"` // Page URL or local file
+}
+
+// withdrawnCommand implements "withdrawn": the products and documents
+// CAM2 no longer publishes, newest first
+func withdrawnCommand(args []string) {
+	flags := flag.NewFlagSet("withdrawn", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	manifestFile := flags.String("manifest", manifestPath, "manifest written by the crawler")
+	flags.Parse(args)
+
+	manifest := loadManifest(*manifestFile)
+	var report []withdrawal
+	for _, product := range manifest.Products {
+		if product.Withdrawn != "" {
+			report = append(report, withdrawal{product.Withdrawn, "product", product.name(), product.URL})
+		}
+	}
+	for _, document := range manifest.Documents {
+		if document.Withdrawn == "" {
+			continue
+		}
+		location := filepath.Join(withdrawnDir, document.Filename)
+		if !fileExists(location) {
+			location = "missing (" + document.SourceURL + ")"
+		}
+		report = append(report, withdrawal{document.Withdrawn, document.Kind, document.Filename, location})
+	}
+	// Earlier copies of documents revised in place
+	for _, document := range manifest.Documents {
+		for _, name := range document.Superseded {
+			report = append(report, withdrawal{supersededDate(name), document.Kind + " (earlier copy)", document.Filename, filepath.Join(withdrawnDir, name)})
+		}
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Date != report[j].Date {
+			return report[i].Date > report[j].Date
+		}
+		return report[i].Name < report[j].Name
+	})
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+		return
+	}
+	if len(report) == 0 {
+		fmt.Println("Nothing has been withdrawn.")
+		return
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "WITHDRAWN\tTYPE\tNAME\tLOCATION")
+	for _, row := range report {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", row.Date, row.Type, row.Name, row.Location)
+	}
+	writer.Flush()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyWithdrawals(t *testing.T) {
+	t.Chdir(t.TempDir()) // withdrawn/ is relative to the working directory
+	dir := "PDFs"
+	os.Mkdir(dir, 0o755)
+	for _, name := range []string{"old_tds.pdf", "new_tds.pdf", "shared_sds.pdf", "gone_sds.pdf"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("%PDF-1.4"), 0o644)
+	}
+	manifest := &Manifest{Documents: map[string]*Document{
+		"old_tds.pdf":    {Filename: "old_tds.pdf"},
+		"new_tds.pdf":    {Filename: "new_tds.pdf", Products: []string{"oil"}},
+		"shared_sds.pdf": {Filename: "shared_sds.pdf", Products: []string{"oil"}},
+		"gone_sds.pdf":   {Filename: "gone_sds.pdf"},
+	}}
+	manifest.applyWithdrawals([]ChangeEvent{
+		{Type: "revised", Filename: "new_tds.pdf", Replaces: "old_tds.pdf"},
+		// Still linked from another product, so it stays
+		{Type: "revised", Filename: "shared_sds.pdf", Replaces: "new_tds.pdf"},
+		{Type: "withdrawn", Filename: "gone_sds.pdf"},
+	}, dir, "2026-10-18")
+
+	for _, test := range []struct {
+		name      string
+		withdrawn bool
+	}{{"old_tds.pdf", true}, {"new_tds.pdf", false}, {"shared_sds.pdf", false}, {"gone_sds.pdf", true}} {
+		document := manifest.Documents[test.name]
+		if (document.Withdrawn != "") != test.withdrawn {
+			t.Errorf("%s withdrawn on %q, want withdrawn %v", test.name, document.Withdrawn, test.withdrawn)
+		}
+		if fileExists(filepath.Join(withdrawnDir, test.name)) != test.withdrawn || fileExists(filepath.Join(dir, test.name)) == test.withdrawn {
+			t.Errorf("%s is in the wrong directory", test.name)
+		}
+	}
+}
+
+func TestSupersededDate(t *testing.T) {
+	for name, want := range map[string]string{
+		"80565_082_sds.2026-10-18.pdf":   "2026-10-18",
+		"80565_082_sds.2026-10-18-2.pdf": "2026-10-18",
+		"80565_082_sds.pdf":              "",
+	} {
+		if got := supersededDate(name); got != want {
+			t.Errorf("supersededDate(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// listWordPress pages through a list of the WordPress REST API, such as the
+// media library or the Store API products. Each page is requested from
+// pageURL and its body handed to decode, which returns how many entries it
+// held. The listing ends at the page count in X-WP-TotalPages, at an empty
+// page, or at the 400 rest_post_invalid_page_number WordPress answers past
+// the last page; what names the list in logs and errors.
+func listWordPress(client *http.Client, what string, pageURL func(page int) string, decode func(*json.Decoder) (int, error)) error {
+	for page := 1; ; page++ {
+		log.Println("Listing", what, pageURL(page))
+		request, err := http.NewRequest("GET", pageURL(page), nil)
+		if err != nil {
+			return err
+		}
+		request.Header.Set("User-Agent", userAgent)
+		request.Header.Set("Accept", "application/json")
+		response, err := client.Do(request)
+		if err != nil {
+			return err
+		}
+		if response.StatusCode == http.StatusBadRequest && page > 1 {
+			response.Body.Close()
+			return nil
+		}
+		if response.StatusCode != http.StatusOK {
+			response.Body.Close()
+			return fmt.Errorf("%s page %d: %s", what, page, response.Status)
+		}
+		count, err := decode(json.NewDecoder(response.Body))
+		response.Body.Close()
+		if err != nil {
+			return fmt.Errorf("%s page %d: %v", what, page, err)
+		}
+		totalPages, _ := strconv.Atoi(response.Header.Get("X-WP-TotalPages"))
+		if count == 0 || (totalPages > 0 && page >= totalPages) {
+			return nil
+		}
+	}
+}